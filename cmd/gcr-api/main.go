@@ -2,7 +2,6 @@ package main
 
 import (
 	"context"
-	"log"
 	"net/http"
 	"os"
 	"os/signal"
@@ -11,13 +10,26 @@ import (
 
 	"github.com/gorilla/mux"
 
+	"gcr-backend/internal/auth"
 	"gcr-backend/internal/bloom"
+	"gcr-backend/internal/bus"
+	"gcr-backend/internal/catalogquery"
 	"gcr-backend/internal/discovery"
-	"gcr-backend/internal/hudi"
 	"gcr-backend/internal/httpapi"
+	"gcr-backend/internal/idempotency"
+	"gcr-backend/internal/itemhash"
 	"gcr-backend/internal/jsonl"
 	"gcr-backend/internal/kstream"
+	"gcr-backend/internal/logging"
+	"gcr-backend/internal/metrics"
+	"gcr-backend/internal/outbox"
+	"gcr-backend/internal/policy"
 	"gcr-backend/internal/projections"
+	"gcr-backend/internal/ratelimit"
+	"gcr-backend/internal/readmodel"
+	"gcr-backend/internal/registry"
+	"gcr-backend/internal/signing"
+	"gcr-backend/internal/tracing"
 	"gcr-backend/internal/trino"
 )
 
@@ -25,21 +37,78 @@ func main() {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	// Initialise Redis Bloom filter (idempotent).
-	bloom.Init()
+	// Structured JSON logs with per-subsystem levels (LOG_LEVEL, LOG_LEVELS).
+	logging.Init()
+	logger := logging.Subsystem("main")
 
-	// Start Kafka consumers in background goroutines
+	// Tracing: W3C trace context across HTTP, bus headers and projectors.
+	tracing.Init()
+	defer func() {
+		shutdownCtx, stop := context.WithTimeout(context.Background(), 5*time.Second)
+		defer stop()
+		_ = tracing.Shutdown(shutdownCtx)
+	}()
+
+	// Read model store: one Redis connection pool (default) or an in-process
+	// substitute (READMODEL_BACKEND=memory), shared by every service below.
+	var store readmodel.Store
+	switch getEnv("READMODEL_BACKEND", "redis") {
+	case "memory":
+		logger.Info("read model: in-memory (no Redis)")
+		store = readmodel.NewMemory()
+	default:
+		store = readmodel.NewRedis(getEnv("REDIS_ADDR", "redis:6379"))
+	}
+	defer store.Close()
+
+	// Initialise Bloom filters (idempotent).
+	bloom.Init(store)
+
+	// Initialise idempotency store (message_id dedupe at edge and SchemaGate).
+	idempotency.Init(store)
+
+	// Initialise item content-hash store (change detection in SchemaGate).
+	itemhash.Init(store)
+
+	// Event bus: Kafka (default) or in-process memory for single-binary runs
+	// (local development, CI) where edge → SchemaGate → curated → projectors
+	// all execute in this process without a broker.
+	var (
+		pub      bus.Publisher
+		sub      bus.Subscriber
+		producer *kstream.Producer
+	)
+	switch getEnv("BUS_BACKEND", "kafka") {
+	case "memory":
+		logger.Info("event bus: in-memory (no Kafka)")
+		mem := bus.NewMemory()
+		defer mem.Close()
+		pub, sub = mem, mem
+	default:
+		// Create (or verify) Kafka topics incl. retry/DLQ with explicit partitions and retention.
+		if err := kstream.EnsureTopics(ctx, kstream.DefaultTopics()); err != nil {
+			logger.Warn("Kafka topic setup failed, continuing with broker auto-create", "error", err)
+		}
+
+		// One long-lived Kafka producer (pooled writer per topic) shared by the
+		// edge handlers and the consumers.
+		producer = kstream.NewProducer()
+		defer producer.Close()
+		pub, sub = producer, kstream.NewSubscriber()
+	}
+
+	// Start consumers in background goroutines
 	go func() {
-		log.Println("Starting SchemaGate consumer...")
-		if err := kstream.ConsumeIngestTopic(ctx); err != nil {
-			log.Printf("SchemaGate consumer error: %v", err)
+		logger.Info("starting SchemaGate consumer")
+		if err := kstream.ConsumeIngestTopic(ctx, sub, pub, store); err != nil {
+			logger.Error("SchemaGate consumer stopped", "error", err)
 		}
 	}()
 
 	go func() {
-		log.Println("Starting Projectors consumer...")
-		if err := projections.ConsumeAcceptedTopic(ctx); err != nil {
-			log.Printf("Projectors consumer error: %v", err)
+		logger.Info("starting Projectors consumer")
+		if err := projections.ConsumeAcceptedTopic(ctx, sub, pub, store); err != nil {
+			logger.Error("Projectors consumer stopped", "error", err)
 		}
 	}()
 
@@ -48,22 +117,86 @@ func main() {
 
 	// Setup HTTP routes
 	r := mux.NewRouter()
-	httpapi.RegisterRoutes(r) // Edge + ingest side
+	r.Use(logging.Middleware) // request_id per request (X-Request-Id)
+	r.Use(tracing.Middleware) // server span per request, continues incoming traceparent
+	// Durable edge outbox: payloads survive Kafka outages and are relayed later.
+	ob, err := outbox.Open(getEnv("OUTBOX_DIR", "./data/outbox"), 64<<20)
+	if err != nil {
+		logger.Error("outbox open failed", "error", err)
+		os.Exit(1)
+	}
+	defer ob.Close()
+	go ob.Relay(ctx, pub.PublishSync, 5*time.Second)
+
+	// Prometheus text exposition (ingest, SchemaGate, projectors, consumer lag, errors, discovery)
+	r.HandleFunc("/metrics", metrics.Handler).Methods(http.MethodGet)
+
+	// ONDC signer for everything we send to buyer/seller apps and the registry (ONDC_SIGNING_KEYS*).
+	signer, err := signing.LoadSigner()
+	if err != nil {
+		logger.Error("signing keys not loaded", "error", err)
+		os.Exit(1)
+	}
+	if signer == nil {
+		logger.Info("no ONDC signing keys configured, outbound messages are unsigned")
+	}
+
+	// Subscriber registry: remote /lookup with caching (ONDC_REGISTRY_URL) or a local file.
+	reg, regErr := registry.FromEnv(signer.Client(10 * time.Second))
+	// Signature verification on /ondc (ONDC_AUTH_MODE) and bap_id/bpp_id checks (ONDC_SUBSCRIBER_CHECK).
+	verifier := signing.NewVerifier(reg)
+	checker := registry.NewChecker(reg)
+	logger.Info("ONDC checks", "signatures", verifier.Mode(), "subscribers", checker.Mode())
+	if regErr != nil && (verifier.Mode() != signing.ModeOff || checker.Mode() != "off") {
+		logger.Warn("registry not available, every subscriber is unknown", "error", regErr)
+	}
+
+	// Per-subscriber quotas on /ondc (RATE_LIMIT_MODE, RATE_LIMIT_CONFIG), shared through the store.
+	limiter, err := ratelimit.New(store)
+	if err != nil {
+		logger.Error("rate limit config", "error", err)
+		os.Exit(1)
+	}
+
+	// API keys / JWTs and roles for /api/* and /admin/* (AUTH_MODE, AUTH_API_KEYS*, AUTH_JWT_*).
+	authn, err := auth.New()
+	if err != nil {
+		logger.Error("auth config", "error", err)
+		os.Exit(1)
+	}
+	logger.Info("internal API auth", "mode", authn.Mode())
+	if authn.Mode() == auth.ModeEnforce && !authn.Configured() {
+		logger.Warn("no API keys or JWT keys configured, /api and /admin reject every request")
+	}
+
+	httpapi.RegisterRoutes(r, pub, ob, verifier, checker, limiter) // Edge + ingest side
+	if producer != nil {
+		r.Handle("/admin/kafka/producer", authn.Require(auth.RoleAdmin)(http.HandlerFunc(producer.StatsHandler))).Methods(http.MethodGet)
+	}
 
 	// Discovery API (read side)
-	disc := discovery.NewService()
-	disc.RegisterRoutes(r)
+	disc := discovery.NewService(store, policy.NewService(store), checker, limiter)
+	disc.RegisterRoutes(r, signer, verifier)
 
 	// Trino Query API (requires Hudi tables setup)
 	trinoService := trino.NewService()
-	trinoService.RegisterRoutes(r)
+	trinoService.RegisterRoutes(r, authn)
 
 	// JSONL Query API (works with current data files)
-	jsonl.RegisterRoutes(r)
+	jsonl.RegisterRoutes(r, authn)
 
-	// Hudi Data API (dedicated API for Hudi data)
-	hudiService := hudi.NewService()
-	hudiService.RegisterRoutes(r)
+	// Bloom filter admin API (BF.INFO stats, rotation config)
+	bloom.RegisterRoutes(r, authn)
+
+	// Catalog query API (/api/v1/catalog) over JSONL or Trino (CATALOG_BACKEND);
+	// the catalog endpoints of /api/data, /api/hudi and /api/trino alias it.
+	catalog, err := catalogquery.NewService(trinoService.Client())
+	if err != nil {
+		logger.Error("catalog query config", "error", err)
+		os.Exit(1)
+	}
+	logger.Info("catalog query API", "default_backend", catalog.Default())
+	catalog.RegisterRoutes(r, authn)
 
 	addr := getEnv("GCR_HTTP_ADDR", ":8080")
 	server := &http.Server{
@@ -76,14 +209,15 @@ func main() {
 		sig := make(chan os.Signal, 1)
 		signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
 		<-sig
-		log.Println("Shutting down...")
+		logger.Info("shutting down")
 		cancel()
 		_ = server.Shutdown(context.Background())
 	}()
 
-	log.Printf("GCR API listening on %s", addr)
+	logger.Info("GCR API listening", "addr", addr)
 	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-		log.Fatalf("server error: %v", err)
+		logger.Error("server error", "error", err)
+		os.Exit(1)
 	}
 }
 
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"gcr-backend/internal/kstream"
+	"gcr-backend/internal/logging"
+)
+
+// gcr-replay re-drives messages from a dead-letter topic (catalog.ingest.dlq,
+// catalog.accepted.dlq) back to the topic they originally failed on.
+//
+//	go run ./cmd/gcr-replay -topic catalog.ingest.dlq -limit 100
+//	go run ./cmd/gcr-replay -topic catalog.accepted.dlq -dry-run
+func main() {
+	topic := flag.String("topic", "catalog.ingest.dlq", "dead-letter topic to replay")
+	limit := flag.Int("limit", 0, "maximum messages to replay (0 = all)")
+	idle := flag.Duration("idle", 10*time.Second, "stop after no message arrives for this long")
+	dryRun := flag.Bool("dry-run", false, "only log messages, do not republish or commit")
+	flag.Parse()
+
+	logging.Init()
+	logger := logging.Subsystem("replay")
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	producer := kstream.NewProducer()
+	defer producer.Close()
+
+	n, err := kstream.ReplayDLQ(ctx, kstream.NewSubscriber(), producer, *topic, *limit, *idle, *dryRun)
+	if err != nil {
+		logger.Error("replay stopped", "replayed", n, "error", err)
+		os.Exit(1)
+	}
+	logger.Info("replay done", "replayed", n, "topic", *topic, "dry_run", *dryRun)
+}
@@ -0,0 +1,281 @@
+// Package auth authenticates callers of the internal data and admin APIs
+// (/api/trino, /api/data, /api/hudi, /admin) with API keys or locally verified
+// JWTs, and authorizes them by role. The ONDC endpoints are authenticated by
+// request signatures instead (see package signing).
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"gcr-backend/internal/logging"
+	"gcr-backend/internal/metrics"
+)
+
+// Roles, from least to most privileged; each role includes the ones before it.
+const (
+	// RoleReader may read catalogs through the predefined data endpoints.
+	RoleReader = "reader"
+	// RoleAnalyst may additionally run raw SQL.
+	RoleAnalyst = "analyst"
+	// RoleAdmin may additionally use the /admin endpoints.
+	RoleAdmin = "admin"
+)
+
+var roleRank = map[string]int{RoleReader: 1, RoleAnalyst: 2, RoleAdmin: 3}
+
+// Modes (AUTH_MODE).
+const (
+	// ModeOff lets every request through as an anonymous admin (local development).
+	ModeOff = "off"
+	// ModeEnforce requires credentials with a sufficient role.
+	ModeEnforce = "enforce"
+)
+
+// Principal is an authenticated caller.
+type Principal struct {
+	Subject string   `json:"subject"`
+	Roles   []string `json:"roles"`
+	Method  string   `json:"method"` // api_key, jwt or none
+}
+
+// Has reports whether p holds role or a more privileged one.
+func (p Principal) Has(role string) bool {
+	for _, r := range p.Roles {
+		if roleRank[r] >= roleRank[role] {
+			return true
+		}
+	}
+	return false
+}
+
+type principalKey struct{}
+
+// PrincipalFrom returns the authenticated caller of the request, if any.
+func PrincipalFrom(ctx context.Context) (Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(Principal)
+	return p, ok
+}
+
+// APIKey is one configured API key. Only the SHA-256 of the key is kept.
+type APIKey struct {
+	Subject   string `json:"subject"`
+	Role      string `json:"role"`
+	KeySHA256 string `json:"key_sha256"`
+	Key       string `json:"key,omitempty"` // plaintext alternative to key_sha256
+}
+
+// Authenticator checks credentials and roles.
+type Authenticator struct {
+	mode    string
+	apiKeys map[string]Principal // hex SHA-256 of the key → principal
+	jwt     *jwtVerifier
+}
+
+// New creates the authenticator from the environment.
+//
+// Configuration (env):
+//   - AUTH_MODE: enforce (default) or off
+//   - AUTH_API_KEYS: "subject:role=key,..."
+//   - AUTH_API_KEYS_FILE: JSON array of {"subject", "role", "key_sha256"} (or "key")
+//   - AUTH_JWT_HS256_SECRET: shared secret for HS256 tokens
+//   - AUTH_JWT_RS256_PUBLIC_KEY_FILE: PEM public key for RS256 tokens
+//   - AUTH_JWT_ISSUER / AUTH_JWT_AUDIENCE: required iss / aud claims, if set
+//   - AUTH_JWT_ROLES_CLAIM: claim holding the role or roles (default roles)
+func New() (*Authenticator, error) {
+	mode := getenv("AUTH_MODE", ModeEnforce)
+	switch mode {
+	case ModeOff, ModeEnforce:
+	default:
+		return nil, fmt.Errorf("auth: unknown AUTH_MODE %q", mode)
+	}
+	a := &Authenticator{mode: mode, apiKeys: map[string]Principal{}}
+
+	var keys []APIKey
+	if path := os.Getenv("AUTH_API_KEYS_FILE"); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(data, &keys); err != nil {
+			return nil, fmt.Errorf("auth: parse %s: %w", path, err)
+		}
+	}
+	if list := os.Getenv("AUTH_API_KEYS"); list != "" {
+		for _, entry := range strings.Split(list, ",") {
+			who, key, ok := strings.Cut(strings.TrimSpace(entry), "=")
+			subject, role, ok2 := strings.Cut(who, ":")
+			if !ok || !ok2 || key == "" {
+				return nil, fmt.Errorf("auth: AUTH_API_KEYS entry for %q is not subject:role=key", who)
+			}
+			keys = append(keys, APIKey{Subject: subject, Role: role, Key: key})
+		}
+	}
+	for _, k := range keys {
+		if err := a.AddAPIKey(k); err != nil {
+			return nil, err
+		}
+	}
+
+	jwt, err := newJWTVerifier()
+	if err != nil {
+		return nil, err
+	}
+	a.jwt = jwt
+	return a, nil
+}
+
+// AddAPIKey registers k.
+func (a *Authenticator) AddAPIKey(k APIKey) error {
+	if _, ok := roleRank[k.Role]; !ok {
+		return fmt.Errorf("auth: API key of %s has unknown role %q", k.Subject, k.Role)
+	}
+	hash := strings.ToLower(k.KeySHA256)
+	if k.Key != "" {
+		hash = hashKey(k.Key)
+	}
+	if len(hash) != sha256.Size*2 {
+		return fmt.Errorf("auth: API key of %s has no key or key_sha256", k.Subject)
+	}
+	a.apiKeys[hash] = Principal{Subject: k.Subject, Roles: []string{k.Role}, Method: "api_key"}
+	return nil
+}
+
+// Mode returns the configured mode.
+func (a *Authenticator) Mode() string {
+	if a == nil {
+		return ModeOff
+	}
+	return a.mode
+}
+
+// Configured reports whether any credentials can be accepted.
+func (a *Authenticator) Configured() bool {
+	return a != nil && (len(a.apiKeys) > 0 || a.jwt != nil)
+}
+
+// Authenticate returns the caller of r from X-API-Key or Authorization: Bearer
+// (a JWT, or an API key).
+func (a *Authenticator) Authenticate(r *http.Request) (Principal, error) {
+	token := r.Header.Get("X-API-Key")
+	if token == "" {
+		scheme, value, _ := strings.Cut(r.Header.Get("Authorization"), " ")
+		if strings.EqualFold(scheme, "Bearer") {
+			token = strings.TrimSpace(value)
+		}
+	}
+	if token == "" {
+		return Principal{}, ErrMissing
+	}
+	if strings.Count(token, ".") == 2 {
+		if a.jwt == nil {
+			return Principal{}, fmt.Errorf("%w: JWTs are not accepted", ErrInvalid)
+		}
+		return a.jwt.verify(token, time.Now())
+	}
+	if p, ok := a.apiKeys[hashKey(token)]; ok {
+		return p, nil
+	}
+	return Principal{}, fmt.Errorf("%w: unknown API key", ErrInvalid)
+}
+
+// Require returns middleware that admits callers holding role, answering 401
+// without valid credentials and 403 without the role. A caller authenticated
+// by an outer Require is not checked again. Requests to admin routes are
+// audit-logged.
+func (a *Authenticator) Require(role string) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := r.Context()
+			p, ok := PrincipalFrom(ctx)
+			if !ok {
+				if a.Mode() == ModeOff {
+					p = Principal{Subject: "anonymous", Roles: []string{RoleAdmin}, Method: "none"}
+				} else {
+					var err error
+					if p, err = a.Authenticate(r); err != nil {
+						metrics.AuthRequests.Inc(resultOf(err))
+						logging.Sampled(logging.For(ctx, "auth")).Info("unauthenticated request", "path", r.URL.Path, "error", err)
+						w.Header().Set("WWW-Authenticate", `Bearer realm="gcr"`)
+						writeError(w, http.StatusUnauthorized, err.Error())
+						return
+					}
+				}
+				ctx = logging.With(context.WithValue(ctx, principalKey{}, p), "principal", p.Subject)
+			}
+			if !p.Has(role) {
+				metrics.AuthRequests.Inc("forbidden")
+				if role == RoleAdmin {
+					Audit(ctx, "denied", "method", r.Method, "path", r.URL.Path)
+				}
+				writeError(w, http.StatusForbidden, fmt.Sprintf("%s requires role %s", r.URL.Path, role))
+				return
+			}
+			metrics.AuthRequests.Inc("ok")
+
+			if role != RoleAdmin {
+				next.ServeHTTP(w, r.WithContext(ctx))
+				return
+			}
+			start := time.Now()
+			rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+			next.ServeHTTP(rec, r.WithContext(ctx))
+			Audit(ctx, "admin_request", "method", r.Method, "path", r.URL.Path,
+				"status", rec.status, "duration_ms", time.Since(start).Milliseconds())
+		})
+	}
+}
+
+// Audit writes an audit record of action by the request's principal. Audit
+// records are never sampled.
+func Audit(ctx context.Context, action string, kv ...any) {
+	p, _ := PrincipalFrom(ctx)
+	args := append([]any{"action", action, "subject", p.Subject, "roles", p.Roles, "auth_method", p.Method}, kv...)
+	logging.For(ctx, "audit").Info("audit", args...)
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": false,
+		"error":   msg,
+	})
+}
+
+// statusRecorder captures the response status for the audit log.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (s *statusRecorder) WriteHeader(code int) {
+	s.status = code
+	s.ResponseWriter.WriteHeader(code)
+}
+
+// Unwrap lets http.ResponseController reach Flush on streamed responses.
+func (s *statusRecorder) Unwrap() http.ResponseWriter {
+	return s.ResponseWriter
+}
+
+func hashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+func getenv(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
@@ -0,0 +1,206 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// Authentication errors.
+var (
+	ErrMissing = errors.New("auth: credentials missing")
+	ErrInvalid = errors.New("auth: invalid credentials")
+	ErrExpired = errors.New("auth: token expired")
+)
+
+// jwtLeeway is the clock skew tolerated for exp and nbf.
+const jwtLeeway = 30 * time.Second
+
+// jwtVerifier verifies compact JWS tokens locally with HS256 and/or RS256.
+type jwtVerifier struct {
+	hmacSecret []byte
+	rsaKey     *rsa.PublicKey
+	issuer     string
+	audience   string
+	rolesClaim string
+}
+
+// newJWTVerifier returns nil when no JWT key is configured.
+func newJWTVerifier() (*jwtVerifier, error) {
+	v := &jwtVerifier{
+		issuer:     os.Getenv("AUTH_JWT_ISSUER"),
+		audience:   os.Getenv("AUTH_JWT_AUDIENCE"),
+		rolesClaim: getenv("AUTH_JWT_ROLES_CLAIM", "roles"),
+	}
+	if secret := os.Getenv("AUTH_JWT_HS256_SECRET"); secret != "" {
+		v.hmacSecret = []byte(secret)
+	}
+	if path := os.Getenv("AUTH_JWT_RS256_PUBLIC_KEY_FILE"); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		key, err := parseRSAPublicKey(data)
+		if err != nil {
+			return nil, fmt.Errorf("auth: %s: %w", path, err)
+		}
+		v.rsaKey = key
+	}
+	if v.hmacSecret == nil && v.rsaKey == nil {
+		return nil, nil
+	}
+	return v, nil
+}
+
+func parseRSAPublicKey(data []byte) (*rsa.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block")
+	}
+	if key, err := x509.ParsePKCS1PublicKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	if cert, err := x509.ParseCertificate(block.Bytes); err == nil {
+		if key, ok := cert.PublicKey.(*rsa.PublicKey); ok {
+			return key, nil
+		}
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	rsaKey, ok := key.(*rsa.PublicKey)
+	if !ok {
+		return nil, errors.New("not an RSA public key")
+	}
+	return rsaKey, nil
+}
+
+// verify checks the signature and registered claims of token and returns its
+// principal. The algorithm must be one we hold a key for; "none" and
+// algorithm confusion (HS256 signed with the RSA public key) are rejected.
+func (v *jwtVerifier) verify(token string, now time.Time) (Principal, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return Principal{}, fmt.Errorf("%w: malformed token", ErrInvalid)
+	}
+	var header struct {
+		Alg string `json:"alg"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return Principal{}, err
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return Principal{}, fmt.Errorf("%w: malformed signature", ErrInvalid)
+	}
+	signed := []byte(parts[0] + "." + parts[1])
+
+	switch {
+	case header.Alg == "HS256" && v.hmacSecret != nil:
+		mac := hmac.New(sha256.New, v.hmacSecret)
+		mac.Write(signed)
+		if !hmac.Equal(sig, mac.Sum(nil)) {
+			return Principal{}, fmt.Errorf("%w: bad signature", ErrInvalid)
+		}
+	case header.Alg == "RS256" && v.rsaKey != nil:
+		digest := sha256.Sum256(signed)
+		if err := rsa.VerifyPKCS1v15(v.rsaKey, crypto.SHA256, digest[:], sig); err != nil {
+			return Principal{}, fmt.Errorf("%w: bad signature", ErrInvalid)
+		}
+	default:
+		return Principal{}, fmt.Errorf("%w: unsupported alg %q", ErrInvalid, header.Alg)
+	}
+
+	var claims map[string]any
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return Principal{}, err
+	}
+	exp, ok := claims["exp"].(float64)
+	if !ok {
+		return Principal{}, fmt.Errorf("%w: exp claim required", ErrInvalid)
+	}
+	if now.After(time.Unix(int64(exp), 0).Add(jwtLeeway)) {
+		return Principal{}, ErrExpired
+	}
+	if nbf, ok := claims["nbf"].(float64); ok && now.Add(jwtLeeway).Before(time.Unix(int64(nbf), 0)) {
+		return Principal{}, fmt.Errorf("%w: token not yet valid", ErrInvalid)
+	}
+	if v.issuer != "" && claims["iss"] != v.issuer {
+		return Principal{}, fmt.Errorf("%w: wrong issuer", ErrInvalid)
+	}
+	if v.audience != "" && !hasString(claims["aud"], v.audience) {
+		return Principal{}, fmt.Errorf("%w: wrong audience", ErrInvalid)
+	}
+
+	p := Principal{Method: "jwt"}
+	p.Subject, _ = claims["sub"].(string)
+	for _, role := range strings.Fields(strings.Join(stringsOf(claims[v.rolesClaim]), " ")) {
+		if _, known := roleRank[role]; known {
+			p.Roles = append(p.Roles, role)
+		}
+	}
+	if p.Subject == "" || len(p.Roles) == 0 {
+		return Principal{}, fmt.Errorf("%w: token needs sub and a known role in %s", ErrInvalid, v.rolesClaim)
+	}
+	return p, nil
+}
+
+func decodeSegment(seg string, v any) error {
+	data, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return fmt.Errorf("%w: malformed token", ErrInvalid)
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("%w: malformed token", ErrInvalid)
+	}
+	return nil
+}
+
+// stringsOf returns a claim that is a string (space-separated, as in OAuth
+// scope) or an array of strings.
+func stringsOf(claim any) []string {
+	switch c := claim.(type) {
+	case string:
+		return []string{c}
+	case []any:
+		out := make([]string, 0, len(c))
+		for _, v := range c {
+			if s, ok := v.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	}
+	return nil
+}
+
+func hasString(claim any, want string) bool {
+	for _, s := range stringsOf(claim) {
+		if s == want {
+			return true
+		}
+	}
+	return false
+}
+
+func resultOf(err error) string {
+	switch {
+	case errors.Is(err, ErrMissing):
+		return "missing"
+	case errors.Is(err, ErrExpired):
+		return "expired"
+	default:
+		return "invalid"
+	}
+}
@@ -0,0 +1,26 @@
+package bloom
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"gcr-backend/internal/auth"
+)
+
+// RegisterRoutes registers Bloom filter admin API routes (admin role, audit-logged).
+func RegisterRoutes(r *mux.Router, authn *auth.Authenticator) {
+	api := r.PathPrefix("/admin/bloom").Subrouter()
+	api.Use(authn.Require(auth.RoleAdmin))
+	api.HandleFunc("/stats", StatsHandler).Methods("GET")
+}
+
+// StatsHandler handles GET /admin/bloom/stats
+func StatsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    Stats(r.Context()),
+	})
+}
@@ -0,0 +1,68 @@
+package bloom
+
+import (
+	"context"
+	"time"
+
+	"gcr-backend/internal/readmodel"
+)
+
+// Backend is the storage behind the named Bloom filters. StoreBackend
+// implements it on a readmodel.Store (RedisBloom BF.* on Redis, an
+// in-process filter on the memory store).
+type Backend interface {
+	// Reserve creates filter name if it does not exist yet.
+	Reserve(ctx context.Context, name string, errorRate float64, capacity int64, ttl time.Duration) error
+	// Add inserts key and reports whether it was probably present already.
+	Add(ctx context.Context, name, key string) (existed bool, err error)
+	// Exists reports whether key is probably present, without inserting it.
+	Exists(ctx context.Context, name, key string) (bool, error)
+	// Info returns BF.INFO style statistics for filter name.
+	Info(ctx context.Context, name string) (Info, error)
+}
+
+// Info mirrors the fields reported by RedisBloom BF.INFO.
+type Info = readmodel.BloomInfo
+
+// StoreBackend stores filters in a readmodel.Store.
+type StoreBackend struct {
+	store     readmodel.Store
+	expansion int64
+}
+
+// NewStoreBackend returns a Bloom backend on store. expansion > 0 lets filters
+// scale (BF.RESERVE ... EXPANSION n); expansion == 0 reserves NONSCALING filters.
+func NewStoreBackend(store readmodel.Store, expansion int64) *StoreBackend {
+	return &StoreBackend{store: store, expansion: expansion}
+}
+
+// Reserve implements Backend.
+func (b *StoreBackend) Reserve(ctx context.Context, name string, errorRate float64, capacity int64, ttl time.Duration) error {
+	if err := b.store.BFReserve(ctx, name, errorRate, capacity, b.expansion); err != nil {
+		return err
+	}
+	if ttl > 0 {
+		// Rotated generations expire on their own once they are no longer "previous".
+		return b.store.Expire(ctx, name, ttl)
+	}
+	return nil
+}
+
+// Add implements Backend.
+func (b *StoreBackend) Add(ctx context.Context, name, key string) (bool, error) {
+	added, err := b.store.BFAdd(ctx, name, key)
+	if err != nil {
+		return false, err
+	}
+	return !added, nil
+}
+
+// Exists implements Backend.
+func (b *StoreBackend) Exists(ctx context.Context, name, key string) (bool, error) {
+	return b.store.BFExists(ctx, name, key)
+}
+
+// Info implements Backend.
+func (b *StoreBackend) Info(ctx context.Context, name string) (Info, error) {
+	return b.store.BFInfo(ctx, name)
+}
@@ -2,16 +2,22 @@ package bloom
 
 import (
 	"context"
-	"log"
 	"os"
+	"strconv"
 	"sync"
+	"time"
 
-	"github.com/redis/go-redis/v9"
+	"gcr-backend/internal/logging"
+	"gcr-backend/internal/readmodel"
 )
 
 var (
-	client *redis.Client
-	once   sync.Once
+	backend     Backend
+	providers   *filter
+	items       *filter
+	failClosed  bool
+	backendName string
+	once        sync.Once
 )
 
 const (
@@ -21,29 +27,57 @@ const (
 	bloomItemsKey = "gcr:items"
 )
 
-// Init sets up the Redis client and ensures the Bloom filter exists.
-// It is safe to call multiple times.
-func Init() {
+// Init sets up the Bloom filters on store and ensures they exist.
+// It is safe to call multiple times; only the first store is used.
+//
+// Configuration (env):
+//   - BLOOM_ERROR_RATE: false positive rate (default 0.001)
+//   - BLOOM_PROVIDER_CAPACITY / BLOOM_ITEM_CAPACITY: initial capacity (default 1M / 10M)
+//   - BLOOM_EXPANSION: RedisBloom scaling factor, 0 for non-scaling filters (default 2)
+//   - BLOOM_ROTATION_WINDOW: generation length, e.g. "168h"; 0 disables rotation (default)
+//   - BLOOM_FAILURE_MODE: "open" (default, errors count as not seen) or "closed" (errors count as seen)
+func Init(store readmodel.Store) {
 	once.Do(func() {
-		addr := getenv("REDIS_ADDR", "redis:6379")
-		client = redis.NewClient(&redis.Options{
-			Addr: addr,
-		})
-
-		// RedisBloom (redis/go-redis/v9): Reserve Bloom filter with error rate 0.001 and capacity 1M.
-		// BF.RESERVE creates a probabilistic data structure for duplicate detection.
-		// This uses RedisBloom module (via redis-stack-server) - not standard Redis commands.
-		ctx := context.Background()
-		if err := client.Do(ctx, "BF.RESERVE", bloomKey, 0.001, 1_000_000).Err(); err != nil {
-			log.Printf("bloom: reserve providers (may already exist): %v", err)
-		}
-		// Reserve Bloom filter for items with larger capacity (10M) to handle high item volume
-		if err := client.Do(ctx, "BF.RESERVE", bloomItemsKey, 0.001, 10_000_000).Err(); err != nil {
-			log.Printf("bloom: reserve items (may already exist): %v", err)
+		backendName = "redis"
+		if _, ok := store.(*readmodel.Memory); ok {
+			backendName = "memory"
 		}
+		setup(NewStoreBackend(store, getenvInt("BLOOM_EXPANSION", 2)))
 	})
 }
 
+// InitWithBackend sets up the filters on an explicit backend.
+// It replaces any previous configuration.
+func InitWithBackend(b Backend) {
+	once.Do(func() {})
+	backendName = "custom"
+	setup(b)
+}
+
+func setup(b Backend) {
+	backend = b
+	failClosed = getenv("BLOOM_FAILURE_MODE", "open") == "closed"
+
+	errorRate := getenvFloat("BLOOM_ERROR_RATE", 0.001)
+	window := getenvDuration("BLOOM_ROTATION_WINDOW", 0)
+	if window > 0 && window < time.Second {
+		// Generations are numbered in whole seconds.
+		logging.Subsystem("bloom").Warn("BLOOM_ROTATION_WINDOW under 1s, rotation disabled", "window", window.String())
+		window = 0
+	}
+	providers = newFilter(bloomKey, errorRate, getenvInt("BLOOM_PROVIDER_CAPACITY", 1_000_000), window)
+	items = newFilter(bloomItemsKey, errorRate, getenvInt("BLOOM_ITEM_CAPACITY", 10_000_000), window)
+
+	// Reserve the current generation up front so BF.INFO works before the first add.
+	ctx := context.Background()
+	if err := providers.ensure(ctx, providers.generation(time.Now())); err != nil {
+		logging.For(ctx, "bloom").Warn("reserve failed", "filter", "providers", "error", err)
+	}
+	if err := items.ensure(ctx, items.generation(time.Now())); err != nil {
+		logging.For(ctx, "bloom").Warn("reserve failed", "filter", "items", "error", err)
+	}
+}
+
 func getenv(key, def string) string {
 	if v := os.Getenv(key); v != "" {
 		return v
@@ -51,66 +85,172 @@ func getenv(key, def string) string {
 	return def
 }
 
+func getenvInt(key string, def int64) int64 {
+	if v, err := strconv.ParseInt(os.Getenv(key), 10, 64); err == nil && v >= 0 {
+		return v
+	}
+	return def
+}
+
+func getenvFloat(key string, def float64) float64 {
+	if v, err := strconv.ParseFloat(os.Getenv(key), 64); err == nil && v > 0 && v < 1 {
+		return v
+	}
+	return def
+}
+
+func getenvDuration(key string, def time.Duration) time.Duration {
+	if v, err := time.ParseDuration(os.Getenv(key)); err == nil && v >= 0 {
+		return v
+	}
+	return def
+}
+
+// filter is a named Bloom filter, optionally split into time-windowed
+// generations. Lookups consult the current and previous generation; adds
+// always go to the current one, so keys idle for two windows age out.
+type filter struct {
+	base      string
+	errorRate float64
+	capacity  int64
+	window    time.Duration
+
+	mu       sync.Mutex
+	reserved map[string]bool
+}
+
+func newFilter(base string, errorRate float64, capacity int64, window time.Duration) *filter {
+	return &filter{
+		base:      base,
+		errorRate: errorRate,
+		capacity:  capacity,
+		window:    window,
+		reserved:  map[string]bool{},
+	}
+}
+
+// generation returns the filter name for the window containing t.
+func (f *filter) generation(t time.Time) string {
+	if f.window <= 0 {
+		return f.base
+	}
+	return f.base + ":" + strconv.FormatInt(t.Unix()/int64(f.window.Seconds()), 10)
+}
+
+// ensure reserves generation name once per process and forgets generations
+// that have left the window (the backend expires them via their TTL).
+func (f *filter) ensure(ctx context.Context, name string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.reserved[name] {
+		return nil
+	}
+
+	// Each generation lives for two windows: one as current, one as previous.
+	if err := backend.Reserve(ctx, name, f.errorRate, f.capacity, 2*f.window); err != nil {
+		return err
+	}
+	f.reserved[name] = true
+
+	if f.window > 0 {
+		prev := f.generation(time.Now().Add(-f.window))
+		for old := range f.reserved {
+			if old == name || old == prev {
+				continue
+			}
+			delete(f.reserved, old)
+		}
+	}
+	return nil
+}
+
+// seen adds key to the current generation and reports whether it was
+// probably present in the current or previous generation.
+func (f *filter) seen(ctx context.Context, key string) (bool, error) {
+	now := time.Now()
+	cur := f.generation(now)
+	if err := f.ensure(ctx, cur); err != nil {
+		return false, err
+	}
+	existed, err := backend.Add(ctx, cur, key)
+	if err != nil || existed || f.window <= 0 {
+		return existed, err
+	}
+	return backend.Exists(ctx, f.generation(now.Add(-f.window)), key)
+}
+
+// stats returns BF.INFO for the current and (if rotating) previous generation.
+func (f *filter) stats(ctx context.Context) []Info {
+	now := time.Now()
+	names := []string{f.generation(now)}
+	if f.window > 0 {
+		names = append(names, f.generation(now.Add(-f.window)))
+	}
+
+	out := []Info{}
+	for _, name := range names {
+		info, err := backend.Info(ctx, name)
+		if err != nil {
+			// Previous generation may not exist yet (first window after start).
+			continue
+		}
+		out = append(out, info)
+	}
+	return out
+}
+
+// onError applies the configured failure mode: fail open treats the key as
+// new (never drop data), fail closed treats it as seen (never double-process).
+func onError(op string, err error) bool {
+	logging.Subsystem("bloom").Error("store error", "op", op, "fail_closed", failClosed, "error", err)
+	return failClosed
+}
+
 // SeenProvider returns true if this provider key looks like a duplicate
 // according to the Bloom filter. It also adds the key if not seen before.
 func SeenProvider(ctx context.Context, providerKey string) bool {
-	if client == nil {
-		return false
-	}
-	// RedisBloom (redis/go-redis/v9): BF.ADD adds item to Bloom filter.
-	// Returns 1 if item was new, 0 if it probably existed (false positive possible).
-	// Uses RedisBloom module commands via client.Do().
-	res := client.Do(ctx, "BF.ADD", bloomKey, providerKey)
-	if res.Err() != nil {
-		log.Printf("bloom: BF.ADD error: %v", res.Err())
+	if providers == nil {
 		return false
 	}
-	// BF.ADD can return either int (0/1) or bool (true/false) depending on Redis version
-	// Try int first, then bool
-	val, err := res.Int()
+	seen, err := providers.seen(ctx, providerKey)
 	if err != nil {
-		// Try as bool instead
-		boolVal, boolErr := res.Bool()
-		if boolErr != nil {
-			log.Printf("bloom: BF.ADD type error (not int or bool): %v", err)
-			return false
-		}
-		// Bool: true means item was new (not seen), false means probably existed
-		return !boolVal
+		return onError("provider add", err)
 	}
-	// Int: 1 means item was new (not seen), 0 means probably existed
-	return val == 0
+	return seen
 }
 
 // SeenItem returns true if this item key looks like a duplicate
 // according to the Bloom filter. It also adds the key if not seen before.
 // Item key format: "domain:city:provider_id:item_id"
 func SeenItem(ctx context.Context, itemKey string) bool {
-	if client == nil {
+	if items == nil {
 		return false
 	}
-	// RedisBloom (redis/go-redis/v9): BF.ADD adds item to Bloom filter.
-	// Returns 1 if item was new, 0 if it probably existed (false positive possible).
-	res := client.Do(ctx, "BF.ADD", bloomItemsKey, itemKey)
-	if res.Err() != nil {
-		log.Printf("bloom: BF.ADD item error: %v", res.Err())
-		return false
-	}
-	// BF.ADD can return either int (0/1) or bool (true/false) depending on Redis version
-	// Try int first, then bool
-	val, err := res.Int()
+	seen, err := items.seen(ctx, itemKey)
 	if err != nil {
-		// Try as bool instead
-		boolVal, boolErr := res.Bool()
-		if boolErr != nil {
-			log.Printf("bloom: BF.ADD item type error (not int or bool): %v", err)
-			return false
-		}
-		// Bool: true means item was new (not seen), false means probably existed
-		return !boolVal
+		return onError("item add", err)
 	}
-	// Int: 1 means item was new (not seen), 0 means probably existed
-	return val == 0
+	return seen
 }
 
-
+// Stats reports the configuration and BF.INFO of every filter generation.
+func Stats(ctx context.Context) map[string]any {
+	if providers == nil {
+		return map[string]any{"initialised": false}
+	}
+	failureMode := "open"
+	if failClosed {
+		failureMode = "closed"
+	}
+	return map[string]any{
+		"initialised":     true,
+		"backend":         backendName,
+		"failure_mode":    failureMode,
+		"error_rate":      providers.errorRate,
+		"rotation_window": providers.window.String(),
+		"filters": map[string]any{
+			"providers": providers.stats(ctx),
+			"items":     items.stats(ctx),
+		},
+	}
+}
@@ -0,0 +1,77 @@
+// Package bus abstracts the message broker behind the pipeline stages.
+//
+// Two implementations exist: the Kafka one in internal/kstream (Producer and
+// Subscriber) and the in-process Memory bus in this package, which lets the
+// whole edge → SchemaGate → curated → projectors pipeline run in one binary
+// without a broker (BUS_BACKEND=memory, for local development and CI).
+package bus
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// Header is a message header (same shape as a Kafka record header).
+type Header struct {
+	Key   string
+	Value []byte
+}
+
+// Message is a broker-neutral record. Topic, Partition and Offset are set
+// on consumed messages; Key picks the partition on publish.
+type Message struct {
+	Topic     string
+	Partition int
+	Offset    int64
+	Key       []byte
+	Value     []byte
+	Headers   []Header
+	Time      time.Time
+}
+
+// Header returns the value of the header key ("" if absent).
+func (m Message) Header(key string) string {
+	for _, h := range m.Headers {
+		if h.Key == key {
+			return string(h.Value)
+		}
+	}
+	return ""
+}
+
+// Publisher delivers messages to a topic. Messages with the same key keep
+// their relative order.
+type Publisher interface {
+	// Publish delivers msgs using the publisher's default delivery mode
+	// (it may return before the broker acknowledged them).
+	Publish(ctx context.Context, topic string, msgs ...Message) error
+	// PublishSync returns only once msgs are durably accepted by the broker.
+	PublishSync(ctx context.Context, topic string, msgs ...Message) error
+}
+
+// Subscriber opens consumer-group subscriptions. Members of the same group
+// share the topic's messages; each group sees every message.
+type Subscriber interface {
+	Subscribe(ctx context.Context, topic, groupID string) (Subscription, error)
+}
+
+// Subscription is one consumer-group member. Offsets are only committed
+// explicitly, so a message fetched but not committed is redelivered after
+// a restart (where the implementation is durable).
+type Subscription interface {
+	// Fetch blocks until the next message is available or ctx is done.
+	Fetch(ctx context.Context) (Message, error)
+	// Commit marks msg (and everything before it on its partition) as processed.
+	Commit(ctx context.Context, msg Message) error
+	Close() error
+}
+
+// LagReporter is implemented by subscriptions that know how many messages
+// their group is behind the end of the topic.
+type LagReporter interface {
+	Lag() int64
+}
+
+// ErrClosed is returned by operations on a closed bus or subscription.
+var ErrClosed = errors.New("bus: closed")
@@ -0,0 +1,230 @@
+package bus
+
+import (
+	"context"
+	"hash/fnv"
+	"sync"
+	"time"
+)
+
+// memoryPartitions is the number of partitions per in-memory topic, so
+// partition-parallel stages behave as they do on Kafka.
+const memoryPartitions = 6
+
+// Memory is an in-process Publisher and Subscriber. Every topic is an
+// append-only log per partition; each consumer group keeps its own read
+// position. Nothing survives a restart, and messages are dropped once every
+// group that subscribed to the topic has committed past them.
+type Memory struct {
+	mu     sync.Mutex
+	topics map[string]*memTopic
+	closed bool
+}
+
+type memTopic struct {
+	partitions [memoryPartitions]*memPartition
+	groups     map[string]*memGroup
+	notify     chan struct{} // closed and replaced on every append
+}
+
+type memPartition struct {
+	base int64 // offset of log[0]
+	head int   // index in log of the oldest retained message
+	log  []Message
+}
+
+// oldest returns the offset of the oldest retained message.
+func (p *memPartition) oldest() int64 { return p.base + int64(p.head) }
+
+type memGroup struct {
+	next      [memoryPartitions]int64 // next offset to hand out
+	committed [memoryPartitions]int64 // first offset not yet committed
+	rr        int                     // partition to look at first (round-robin)
+}
+
+// NewMemory creates an empty in-memory bus.
+func NewMemory() *Memory {
+	return &Memory{topics: map[string]*memTopic{}}
+}
+
+func (m *Memory) topic(name string) *memTopic {
+	t, ok := m.topics[name]
+	if !ok {
+		t = &memTopic{groups: map[string]*memGroup{}, notify: make(chan struct{})}
+		for i := range t.partitions {
+			t.partitions[i] = &memPartition{}
+		}
+		m.topics[name] = t
+	}
+	return t
+}
+
+// Publish appends msgs to topic. Delivery is immediate, so it is the same as PublishSync.
+func (m *Memory) Publish(ctx context.Context, topic string, msgs ...Message) error {
+	return m.PublishSync(ctx, topic, msgs...)
+}
+
+// PublishSync appends msgs to topic; messages with a key go to the partition
+// of its hash, others round-robin.
+func (m *Memory) PublishSync(ctx context.Context, topic string, msgs ...Message) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return ErrClosed
+	}
+
+	t := m.topic(topic)
+	for i, msg := range msgs {
+		p := i % memoryPartitions
+		if len(msg.Key) > 0 {
+			h := fnv.New32a()
+			h.Write(msg.Key)
+			p = int(h.Sum32() % memoryPartitions)
+		}
+		part := t.partitions[p]
+		msg.Topic = topic
+		msg.Partition = p
+		msg.Offset = part.base + int64(len(part.log))
+		if msg.Time.IsZero() {
+			msg.Time = time.Now()
+		}
+		part.log = append(part.log, msg)
+	}
+	close(t.notify)
+	t.notify = make(chan struct{})
+	return nil
+}
+
+// Subscribe joins groupID on topic. A new group starts at the oldest
+// retained message, like a Kafka group with FirstOffset.
+func (m *Memory) Subscribe(ctx context.Context, topic, groupID string) (Subscription, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return nil, ErrClosed
+	}
+
+	t := m.topic(topic)
+	if _, ok := t.groups[groupID]; !ok {
+		g := &memGroup{}
+		for i, part := range t.partitions {
+			g.next[i] = part.oldest()
+			g.committed[i] = part.oldest()
+		}
+		t.groups[groupID] = g
+	}
+	return &memSubscription{bus: m, topic: topic, group: groupID}, nil
+}
+
+// Close wakes up all blocked fetches; later calls fail with ErrClosed.
+func (m *Memory) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.closed {
+		m.closed = true
+		for _, t := range m.topics {
+			close(t.notify)
+		}
+	}
+	return nil
+}
+
+type memSubscription struct {
+	bus    *Memory
+	topic  string
+	group  string
+	closed bool
+}
+
+func (s *memSubscription) Fetch(ctx context.Context) (Message, error) {
+	for {
+		s.bus.mu.Lock()
+		if s.bus.closed || s.closed {
+			s.bus.mu.Unlock()
+			return Message{}, ErrClosed
+		}
+		t := s.bus.topics[s.topic]
+		g := t.groups[s.group]
+		for i := 0; i < memoryPartitions; i++ {
+			p := (g.rr + i) % memoryPartitions
+			part := t.partitions[p]
+			if idx := g.next[p] - part.base; idx < int64(len(part.log)) {
+				msg := part.log[idx]
+				g.next[p]++
+				g.rr = (p + 1) % memoryPartitions
+				s.bus.mu.Unlock()
+				return msg, nil
+			}
+		}
+		notify := t.notify
+		s.bus.mu.Unlock()
+
+		select {
+		case <-notify:
+		case <-ctx.Done():
+			return Message{}, ctx.Err()
+		}
+	}
+}
+
+func (s *memSubscription) Commit(ctx context.Context, msg Message) error {
+	s.bus.mu.Lock()
+	defer s.bus.mu.Unlock()
+	if s.bus.closed {
+		return ErrClosed
+	}
+	t := s.bus.topics[s.topic]
+	g := t.groups[s.group]
+	if next := msg.Offset + 1; next > g.committed[msg.Partition] {
+		g.committed[msg.Partition] = next
+	}
+	t.trim(msg.Partition)
+	return nil
+}
+
+// trim drops messages of partition p that every group has committed. It
+// only advances head; the log is copied once at least half of it is
+// dropped, so copying costs amortised O(1) per message.
+func (t *memTopic) trim(p int) {
+	part := t.partitions[p]
+	low := part.base + int64(len(part.log))
+	for _, g := range t.groups {
+		if g.committed[p] < low {
+			low = g.committed[p]
+		}
+	}
+	head := int(low - part.base)
+	if head <= part.head {
+		return
+	}
+	clear(part.log[part.head:head]) // release the payloads until the next copy
+	part.head = head
+	if part.head >= len(part.log)/2 {
+		part.log = append([]Message(nil), part.log[part.head:]...)
+		part.base = low
+		part.head = 0
+	}
+}
+
+// Lag implements LagReporter: messages not yet handed out to the group.
+func (s *memSubscription) Lag() int64 {
+	s.bus.mu.Lock()
+	defer s.bus.mu.Unlock()
+	t := s.bus.topics[s.topic]
+	g := t.groups[s.group]
+	var lag int64
+	for i, part := range t.partitions {
+		lag += part.base + int64(len(part.log)) - g.next[i]
+	}
+	return lag
+}
+
+func (s *memSubscription) Close() error {
+	s.bus.mu.Lock()
+	defer s.bus.mu.Unlock()
+	s.closed = true
+	return nil
+}
@@ -0,0 +1,192 @@
+package bus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// fetchN fetches n messages, failing the test if they do not arrive in time.
+func fetchN(t *testing.T, sub Subscription, n int) []Message {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	msgs := make([]Message, 0, n)
+	for len(msgs) < n {
+		msg, err := sub.Fetch(ctx)
+		if err != nil {
+			t.Fatalf("Fetch() after %d of %d messages: %v", len(msgs), n, err)
+		}
+		msgs = append(msgs, msg)
+	}
+	return msgs
+}
+
+func TestMemoryKeyOrdering(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemory()
+	defer m.Close()
+	sub, err := m.Subscribe(ctx, "t", "g")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keys := []string{"a", "b", "c", "d"}
+	var msgs []Message
+	for i := 0; i < 40; i++ {
+		msgs = append(msgs, Message{Key: []byte(keys[i%len(keys)]), Value: []byte(fmt.Sprint(i))})
+	}
+	if err := m.PublishSync(ctx, "t", msgs[:20]...); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Publish(ctx, "t", msgs[20:]...); err != nil {
+		t.Fatal(err)
+	}
+
+	partition := map[string]int{}
+	last := map[string]int{}
+	for _, msg := range fetchN(t, sub, len(msgs)) {
+		key := string(msg.Key)
+		if p, ok := partition[key]; ok && p != msg.Partition {
+			t.Fatalf("key %q on partitions %d and %d", key, p, msg.Partition)
+		}
+		partition[key] = msg.Partition
+		var n int
+		fmt.Sscan(string(msg.Value), &n)
+		if prev, ok := last[key]; ok && n <= prev {
+			t.Fatalf("key %q: message %d fetched after %d", key, n, prev)
+		}
+		last[key] = n
+		if msg.Topic != "t" {
+			t.Fatalf("Topic = %q, want t", msg.Topic)
+		}
+	}
+}
+
+func TestMemoryGroupOffsets(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemory()
+	defer m.Close()
+	a1, _ := m.Subscribe(ctx, "t", "a")
+	a2, _ := m.Subscribe(ctx, "t", "a")
+	b, _ := m.Subscribe(ctx, "t", "b")
+
+	for i := 0; i < 10; i++ {
+		if err := m.PublishSync(ctx, "t", Message{Key: []byte("k"), Value: []byte(fmt.Sprint(i))}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Members of one group share its position; another group sees everything.
+	seen := map[int64]bool{}
+	for _, msg := range append(fetchN(t, a1, 4), fetchN(t, a2, 6)...) {
+		if seen[msg.Offset] {
+			t.Fatalf("group a got offset %d twice", msg.Offset)
+		}
+		seen[msg.Offset] = true
+	}
+	if got := fetchN(t, b, 10); got[0].Offset != 0 || got[9].Offset != 9 {
+		t.Fatalf("group b offsets %d..%d, want 0..9", got[0].Offset, got[9].Offset)
+	}
+	if lag := a1.(LagReporter).Lag(); lag != 0 {
+		t.Fatalf("group a Lag() = %d, want 0", lag)
+	}
+
+	if err := m.PublishSync(ctx, "t", Message{Key: []byte("k")}); err != nil {
+		t.Fatal(err)
+	}
+	if lag := b.(LagReporter).Lag(); lag != 1 {
+		t.Fatalf("group b Lag() = %d, want 1", lag)
+	}
+}
+
+func TestMemoryCommitTrim(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemory()
+	defer m.Close()
+	a, _ := m.Subscribe(ctx, "t", "a")
+	b, _ := m.Subscribe(ctx, "t", "b")
+
+	const n = 1000
+	part := func() *memPartition {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		p := m.topics["t"].partitions[0]
+		return &memPartition{base: p.base, head: p.head, log: p.log}
+	}
+	// Interleave publishing and consuming so the log is trimmed and
+	// compacted while messages are still arriving.
+	for i := 0; i < n; i++ {
+		if err := m.PublishSync(ctx, "t", Message{Value: []byte(fmt.Sprint(i))}); err != nil {
+			t.Fatal(err)
+		}
+		for _, sub := range []Subscription{a, b} {
+			// Unkeyed messages of one call start at partition 0.
+			msg := fetchN(t, sub, 1)[0]
+			if msg.Partition != 0 || msg.Offset != int64(i) || string(msg.Value) != fmt.Sprint(i) {
+				t.Fatalf("message %d: got partition %d offset %d value %q", i, msg.Partition, msg.Offset, msg.Value)
+			}
+			if sub == a {
+				if err := sub.Commit(ctx, msg); err != nil {
+					t.Fatal(err)
+				}
+			}
+		}
+	}
+
+	// Group b has committed nothing: every message is still retained.
+	if p := part(); p.oldest() != 0 {
+		t.Fatalf("oldest offset = %d with group b uncommitted, want 0", p.oldest())
+	}
+
+	// Committing the last message commits everything before it.
+	if err := b.Commit(ctx, Message{Partition: 0, Offset: n - 2}); err != nil {
+		t.Fatal(err)
+	}
+	p := part()
+	if p.oldest() != n-1 || len(p.log)-p.head != 1 {
+		t.Fatalf("after commit: oldest %d, retained %d; want %d, 1", p.oldest(), len(p.log)-p.head, n-1)
+	}
+	if len(p.log) > 1 {
+		t.Fatalf("log not compacted: %d entries for 1 retained message", len(p.log))
+	}
+
+	// A new group starts at the oldest retained message.
+	c, _ := m.Subscribe(ctx, "t", "c")
+	if msg := fetchN(t, c, 1)[0]; msg.Offset != n-1 {
+		t.Fatalf("new group starts at offset %d, want %d", msg.Offset, n-1)
+	}
+}
+
+func TestMemoryCloseWakesFetch(t *testing.T) {
+	m := NewMemory()
+	sub, err := m.Subscribe(context.Background(), "t", "g")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	errc := make(chan error, 1)
+	go func() {
+		_, err := sub.Fetch(context.Background())
+		errc <- err
+	}()
+	time.Sleep(10 * time.Millisecond) // let Fetch block
+	m.Close()
+
+	select {
+	case err := <-errc:
+		if !errors.Is(err, ErrClosed) {
+			t.Fatalf("Fetch() error = %v, want ErrClosed", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Fetch() still blocked after Close")
+	}
+	if err := m.PublishSync(context.Background(), "t", Message{}); !errors.Is(err, ErrClosed) {
+		t.Fatalf("PublishSync() after Close error = %v, want ErrClosed", err)
+	}
+	if _, err := m.Subscribe(context.Background(), "t", "g"); !errors.Is(err, ErrClosed) {
+		t.Fatalf("Subscribe() after Close error = %v, want ErrClosed", err)
+	}
+}
@@ -0,0 +1,300 @@
+package catalogquery
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"gcr-backend/internal/auth"
+	"gcr-backend/internal/jsonl"
+	"gcr-backend/internal/logging"
+	"gcr-backend/internal/trino"
+)
+
+// Service serves /api/v1/catalog over the registered backends.
+type Service struct {
+	backends map[string]Backend
+	def      string
+}
+
+// NewService registers the JSONL and Trino backends and selects the default
+// one with CATALOG_BACKEND (jsonl or trino, default jsonl). Requests choose
+// another with ?backend= or the X-Catalog-Backend header.
+func NewService(trinoClient *trino.Client) (*Service, error) {
+	s := &Service{backends: map[string]Backend{}}
+	s.Register(NewJSONL(jsonl.NewQueryService()))
+	s.Register(NewTrino(trinoClient))
+	def := strings.ToLower(getEnv("CATALOG_BACKEND", "jsonl"))
+	if _, ok := s.backends[def]; !ok {
+		return nil, fmt.Errorf("CATALOG_BACKEND: unknown backend %q (have %s)", def, strings.Join(s.Names(), ", "))
+	}
+	s.def = def
+	return s, nil
+}
+
+// Register adds b, replacing a backend of the same name.
+func (s *Service) Register(b Backend) {
+	s.backends[b.Name()] = b
+}
+
+// Names returns the registered backend names, sorted.
+func (s *Service) Names() []string {
+	names := make([]string, 0, len(s.backends))
+	for name := range s.backends {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Default returns the name of the default backend.
+func (s *Service) Default() string { return s.def }
+
+// RegisterRoutes registers the catalog API (reader role) and the legacy
+// paths that alias it: /api/data and /api/hudi read the JSONL files and the
+// catalog endpoints of /api/trino read through Trino. The aliases answer
+// with the /api/v1/catalog envelope.
+func (s *Service) RegisterRoutes(r *mux.Router, authn *auth.Authenticator) {
+	v1 := r.PathPrefix("/api/v1/catalog").Subrouter()
+	v1.Use(authn.Require(auth.RoleReader))
+	s.routes(v1, "")
+
+	for _, alias := range []struct{ prefix, backend string }{
+		{"/api/data", "jsonl"},
+		{"/api/hudi", "jsonl"},
+		{"/api/trino", "trino"},
+	} {
+		api := r.PathPrefix(alias.prefix).Subrouter()
+		api.Use(authn.Require(auth.RoleReader))
+		s.routes(api, alias.backend)
+		if alias.prefix == "/api/hudi" {
+			// Hudi-only spellings kept for existing clients.
+			api.HandleFunc("/health", s.with(alias.backend, s.Health)).Methods("GET")
+			api.HandleFunc("/provider/{provider_id}/items", s.with(alias.backend, s.ProviderItems)).Methods("GET")
+		}
+	}
+}
+
+// routes registers the catalog endpoints on api. A non-empty backend pins
+// the routes to it; otherwise the request selects the backend.
+func (s *Service) routes(api *mux.Router, backend string) {
+	api.HandleFunc("/providers", s.with(backend, s.ListProviders)).Methods("GET")
+	api.HandleFunc("/providers/{provider_id}", s.with(backend, s.GetProvider)).Methods("GET")
+	api.HandleFunc("/providers/{provider_id}/items", s.with(backend, s.ProviderItems)).Methods("GET")
+	api.HandleFunc("/items", s.with(backend, s.ListItems)).Methods("GET")
+	api.HandleFunc("/stats", s.with(backend, s.Stats)).Methods("GET")
+	if backend == "" {
+		api.HandleFunc("/health", s.with(backend, s.Health)).Methods("GET")
+	}
+}
+
+// handlerFunc is a catalog handler bound to the backend chosen for the request.
+type handlerFunc func(w http.ResponseWriter, r *http.Request, b Backend)
+
+// with resolves the backend (pinned, ?backend=, X-Catalog-Backend, or the
+// default) and calls h with it.
+func (s *Service) with(pinned string, h handlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := pinned
+		if name == "" {
+			name = r.URL.Query().Get("backend")
+		}
+		if name == "" {
+			name = r.Header.Get("X-Catalog-Backend")
+		}
+		if name == "" {
+			name = s.def
+		}
+		b, ok := s.backends[strings.ToLower(name)]
+		if !ok {
+			writeJSON(w, http.StatusBadRequest, map[string]interface{}{
+				"success": false,
+				"error":   fmt.Sprintf("unknown backend %q (have %s)", name, strings.Join(s.Names(), ", ")),
+			})
+			return
+		}
+		h(w, r, b)
+	}
+}
+
+// Envelope is the successful response of every catalog endpoint. Count,
+// Limit, Offset and HasMore are set on listings. Failures answer
+// {"success": false, "backend": ..., "error": ...}.
+type Envelope struct {
+	Success bool        `json:"success"`
+	Backend string      `json:"backend"`
+	Data    interface{} `json:"data"`
+	Count   *int        `json:"count,omitempty"`
+	Limit   *int        `json:"limit,omitempty"`
+	Offset  *int        `json:"offset,omitempty"`
+	HasMore *bool       `json:"has_more,omitempty"`
+}
+
+// ListProviders handles GET /providers?city=&domain=&bpp_id=&limit=&offset=.
+func (s *Service) ListProviders(w http.ResponseWriter, r *http.Request, b Backend) {
+	q := r.URL.Query()
+	f := ProviderFilter{
+		City:   q.Get("city"),
+		Domain: q.Get("domain"),
+		BppID:  q.Get("bpp_id"),
+		Limit:  limitParam(r),
+		Offset: offsetParam(r),
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	providers, err := b.ListProviders(ctx, f)
+	if err != nil {
+		backendError(w, ctx, b, err)
+		return
+	}
+	writeList(w, b, providers, len(providers), f.Limit, f.Offset)
+}
+
+// GetProvider handles GET /providers/{provider_id}.
+func (s *Service) GetProvider(w http.ResponseWriter, r *http.Request, b Backend) {
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	provider, err := b.GetProvider(ctx, mux.Vars(r)["provider_id"])
+	if err != nil {
+		backendError(w, ctx, b, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, Envelope{Success: true, Backend: b.Name(), Data: provider})
+}
+
+// ListItems handles GET /items?provider_id=&category_id=&city=&limit=&offset=.
+func (s *Service) ListItems(w http.ResponseWriter, r *http.Request, b Backend) {
+	q := r.URL.Query()
+	s.items(w, r, b, ItemFilter{
+		ProviderID: q.Get("provider_id"),
+		CategoryID: q.Get("category_id"),
+		City:       q.Get("city"),
+		Limit:      limitParam(r),
+		Offset:     offsetParam(r),
+	})
+}
+
+// ProviderItems handles GET /providers/{provider_id}/items?category_id=&limit=&offset=.
+func (s *Service) ProviderItems(w http.ResponseWriter, r *http.Request, b Backend) {
+	s.items(w, r, b, ItemFilter{
+		ProviderID: mux.Vars(r)["provider_id"],
+		CategoryID: r.URL.Query().Get("category_id"),
+		Limit:      limitParam(r),
+		Offset:     offsetParam(r),
+	})
+}
+
+func (s *Service) items(w http.ResponseWriter, r *http.Request, b Backend, f ItemFilter) {
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	items, err := b.ListItems(ctx, f)
+	if err != nil {
+		backendError(w, ctx, b, err)
+		return
+	}
+	writeList(w, b, items, len(items), f.Limit, f.Offset)
+}
+
+// Stats handles GET /stats.
+func (s *Service) Stats(w http.ResponseWriter, r *http.Request, b Backend) {
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	stats, err := b.Stats(ctx)
+	if err != nil {
+		backendError(w, ctx, b, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, Envelope{Success: true, Backend: b.Name(), Data: stats})
+}
+
+// Health handles GET /health: 200 when the backend answers a stats query
+// within 5s, 503 otherwise.
+func (s *Service) Health(w http.ResponseWriter, r *http.Request, b Backend) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	stats, err := b.Stats(ctx)
+	if err != nil {
+		writeError(w, http.StatusServiceUnavailable, b, fmt.Sprintf("%s backend unavailable: %v", b.Name(), err))
+		return
+	}
+	writeJSON(w, http.StatusOK, Envelope{Success: true, Backend: b.Name(), Data: map[string]interface{}{
+		"status":          "ok",
+		"total_providers": stats.TotalProviders,
+		"total_items":     stats.TotalItems,
+	}})
+}
+
+// backendError answers 404 for ErrNotFound and 500 for other failures.
+func backendError(w http.ResponseWriter, ctx context.Context, b Backend, err error) {
+	status := http.StatusInternalServerError
+	if errors.Is(err, ErrNotFound) {
+		status = http.StatusNotFound
+	} else {
+		logging.For(ctx, "catalog").Error("query failed", "backend", b.Name(), "error", err)
+	}
+	writeError(w, status, b, err.Error())
+}
+
+func writeError(w http.ResponseWriter, status int, b Backend, msg string) {
+	writeJSON(w, status, map[string]interface{}{
+		"success": false,
+		"backend": b.Name(),
+		"error":   msg,
+	})
+}
+
+func writeList(w http.ResponseWriter, b Backend, data interface{}, count, limit, offset int) {
+	hasMore := count == limit
+	writeJSON(w, http.StatusOK, Envelope{
+		Success: true,
+		Backend: b.Name(),
+		Data:    data,
+		Count:   &count,
+		Limit:   &limit,
+		Offset:  &offset,
+		HasMore: &hasMore,
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// limitParam returns ?limit= in 1..1000 (default 100).
+func limitParam(r *http.Request) int {
+	if parsed, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && parsed > 0 && parsed <= 1000 {
+		return parsed
+	}
+	return 100
+}
+
+// offsetParam returns ?offset= (default 0).
+func offsetParam(r *http.Request) int {
+	if parsed, err := strconv.Atoi(r.URL.Query().Get("offset")); err == nil && parsed >= 0 {
+		return parsed
+	}
+	return 0
+}
+
+func getEnv(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
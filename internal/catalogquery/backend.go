@@ -0,0 +1,92 @@
+// Package catalogquery serves read queries over the stored catalog
+// (providers and their items) from interchangeable backends: the JSONL
+// files written by the projections, or the Hudi tables through Trino.
+package catalogquery
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrNotFound is returned by GetProvider for an unknown provider.
+var ErrNotFound = errors.New("provider not found")
+
+// Backend answers catalog queries. Implementations return empty slices,
+// not nil, when nothing matches.
+type Backend interface {
+	// Name identifies the backend in responses and in ?backend=.
+	Name() string
+	ListProviders(ctx context.Context, f ProviderFilter) ([]ProviderSummary, error)
+	GetProvider(ctx context.Context, providerID string) (*Provider, error)
+	ListItems(ctx context.Context, f ItemFilter) ([]Item, error)
+	Stats(ctx context.Context) (*Stats, error)
+}
+
+// ProviderFilter selects providers; empty fields match everything.
+type ProviderFilter struct {
+	City   string
+	Domain string
+	BppID  string
+	Limit  int
+	Offset int
+}
+
+// ItemFilter selects items; empty fields match everything.
+type ItemFilter struct {
+	ProviderID string
+	CategoryID string
+	City       string
+	Limit      int
+	Offset     int
+}
+
+// ProviderSummary is one provider record in a listing.
+type ProviderSummary struct {
+	ProviderID    string   `json:"provider_id"`
+	Domain        string   `json:"domain"`
+	City          string   `json:"city"`
+	BppID         string   `json:"bpp_id"`
+	BapID         string   `json:"bap_id,omitempty"`
+	Timestamp     string   `json:"timestamp"`
+	ProviderName  string   `json:"provider_name"`
+	ItemsCount    int      `json:"items_count"`
+	QualityScore  *float64 `json:"quality_score"` // null for records without a score
+	WarningsCount int      `json:"warnings_count"`
+}
+
+// Provider is the latest full record of a provider.
+type Provider struct {
+	ProviderID   string                 `json:"provider_id"`
+	Domain       string                 `json:"domain"`
+	City         string                 `json:"city"`
+	BapID        string                 `json:"bap_id"`
+	BppID        string                 `json:"bpp_id"`
+	Timestamp    string                 `json:"timestamp"`
+	Descriptor   map[string]interface{} `json:"descriptor"`
+	Categories   []interface{}          `json:"categories"`
+	Items        []interface{}          `json:"items"`
+	QualityScore *float64               `json:"quality_score,omitempty"`
+	Warnings     []interface{}          `json:"warnings,omitempty"`
+}
+
+// Item is one catalog item with the provider fields it is filtered by.
+type Item struct {
+	ProviderID    string `json:"provider_id"`
+	City          string `json:"city"`
+	Domain        string `json:"domain"`
+	ItemID        string `json:"item_id"`
+	ItemName      string `json:"item_name"`
+	CategoryID    string `json:"category_id"`
+	PriceValue    string `json:"price_value"`
+	PriceCurrency string `json:"price_currency"`
+}
+
+// Stats summarises the stored catalog.
+type Stats struct {
+	TotalProviders int64  `json:"total_providers"`
+	TotalRecords   int64  `json:"total_records"`
+	TotalItems     int64  `json:"total_items"`
+	TotalDomains   int64  `json:"total_domains"`
+	TotalCities    int64  `json:"total_cities"`
+	LatestUpdate   string `json:"latest_update"`
+}
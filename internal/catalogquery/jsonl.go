@@ -0,0 +1,154 @@
+package catalogquery
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"gcr-backend/internal/jsonl"
+)
+
+// JSONL serves the catalog from the provider JSONL files (DATA_DIR).
+type JSONL struct {
+	q *jsonl.QueryService
+}
+
+// NewJSONL returns a backend over q.
+func NewJSONL(q *jsonl.QueryService) *JSONL {
+	return &JSONL{q: q}
+}
+
+// Name implements Backend.
+func (b *JSONL) Name() string { return "jsonl" }
+
+// ListProviders implements Backend.
+func (b *JSONL) ListProviders(ctx context.Context, f ProviderFilter) ([]ProviderSummary, error) {
+	match := func(p *jsonl.Provider) bool {
+		return (f.City == "" || p.City == f.City) &&
+			(f.Domain == "" || p.Domain == f.Domain) &&
+			(f.BppID == "" || p.BppID == f.BppID)
+	}
+	providers, err := b.q.ListProviders(ctx, match, f.Limit, f.Offset)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]ProviderSummary, 0, len(providers))
+	for _, p := range providers {
+		name, _ := p.Descriptor["name"].(string)
+		out = append(out, ProviderSummary{
+			ProviderID:    p.ProviderID,
+			Domain:        p.Domain,
+			City:          p.City,
+			BppID:         p.BppID,
+			BapID:         p.BapID,
+			Timestamp:     p.Timestamp,
+			ProviderName:  name,
+			ItemsCount:    len(p.Items),
+			QualityScore:  p.QualityScore,
+			WarningsCount: len(p.Warnings),
+		})
+	}
+	return out, nil
+}
+
+// GetProvider implements Backend.
+func (b *JSONL) GetProvider(ctx context.Context, providerID string) (*Provider, error) {
+	// The ID names a file under DATA_DIR; never let it leave that directory.
+	if providerID == "" || strings.ContainsAny(providerID, `/\`) || strings.Contains(providerID, "..") {
+		return nil, ErrNotFound
+	}
+	p, err := b.q.GetProvider(ctx, providerID)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &Provider{
+		ProviderID:   p.ProviderID,
+		Domain:       p.Domain,
+		City:         p.City,
+		BapID:        p.BapID,
+		BppID:        p.BppID,
+		Timestamp:    p.Timestamp,
+		Descriptor:   p.Descriptor,
+		Categories:   p.Categories,
+		Items:        p.Items,
+		QualityScore: p.QualityScore,
+		Warnings:     p.Warnings,
+	}, nil
+}
+
+// ListItems implements Backend. The files are scanned from the start, so
+// Offset costs as much as reading that many items.
+func (b *JSONL) ListItems(ctx context.Context, f ItemFilter) ([]Item, error) {
+	rows, err := b.q.GetItems(ctx, f.ProviderID, f.CategoryID, f.City, f.Limit+f.Offset)
+	if err != nil {
+		return nil, err
+	}
+	if f.Offset >= len(rows) {
+		return []Item{}, nil
+	}
+	rows = rows[f.Offset:]
+	out := make([]Item, 0, len(rows))
+	for _, row := range rows {
+		out = append(out, Item{
+			ProviderID:    str(row["provider_id"]),
+			City:          str(row["city"]),
+			Domain:        str(row["domain"]),
+			ItemID:        str(row["item_id"]),
+			ItemName:      str(row["item_name"]),
+			CategoryID:    str(row["category_id"]),
+			PriceValue:    str(row["price_value"]),
+			PriceCurrency: str(row["price_currency"]),
+		})
+	}
+	return out, nil
+}
+
+// Stats implements Backend.
+func (b *JSONL) Stats(ctx context.Context) (*Stats, error) {
+	m, err := b.q.GetStats(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &Stats{
+		TotalProviders: num(m["total_providers"]),
+		TotalRecords:   num(m["total_records"]),
+		TotalItems:     num(m["total_items"]),
+		TotalDomains:   num(m["total_domains"]),
+		TotalCities:    num(m["total_cities"]),
+		LatestUpdate:   str(m["latest_update"]),
+	}, nil
+}
+
+// str renders a decoded JSON scalar as a string ("" for null).
+func str(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	}
+	return fmt.Sprint(v)
+}
+
+// num converts a count decoded from JSON (float64), Go (int) or Trino
+// (float64 or numeric string) to int64.
+func num(v interface{}) int64 {
+	switch v := v.(type) {
+	case int:
+		return int64(v)
+	case int64:
+		return v
+	case float64:
+		return int64(v)
+	case string:
+		var n int64
+		fmt.Sscan(v, &n)
+		return n
+	}
+	return 0
+}
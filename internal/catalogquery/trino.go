@@ -0,0 +1,221 @@
+package catalogquery
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+
+	"gcr-backend/internal/trino"
+)
+
+// Trino serves the catalog from the Hudi providers table through Trino
+// (TRINO_CATALOG / TRINO_SCHEMA select where the table lives).
+type Trino struct {
+	client *trino.Client
+}
+
+// NewTrino returns a backend over client.
+func NewTrino(client *trino.Client) *Trino {
+	return &Trino{client: client}
+}
+
+// Name implements Backend.
+func (b *Trino) Name() string { return "trino" }
+
+// ListProviders implements Backend.
+func (b *Trino) ListProviders(ctx context.Context, f ProviderFilter) ([]ProviderSummary, error) {
+	where, args := conditions(
+		"city = ?", f.City,
+		"domain = ?", f.Domain,
+		"bpp_id = ?", f.BppID,
+	)
+	sql, err := trino.Build(`
+		SELECT
+			provider_id,
+			domain,
+			city,
+			bpp_id,
+			bap_id,
+			CAST(timestamp AS varchar) AS timestamp,
+			json_extract_scalar(descriptor, '$.name') AS provider_name,
+			json_array_length(items) AS items_count,
+			quality_score,
+			json_array_length(warnings) AS warnings_count
+		FROM providers
+		`+where+`
+		ORDER BY timestamp DESC
+		OFFSET ? LIMIT ?
+	`, append(args, f.Offset, f.Limit)...)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := b.client.Query(ctx, sql)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]ProviderSummary, 0, len(rows))
+	for _, row := range rows {
+		out = append(out, ProviderSummary{
+			ProviderID:    str(row["provider_id"]),
+			Domain:        str(row["domain"]),
+			City:          str(row["city"]),
+			BppID:         str(row["bpp_id"]),
+			BapID:         str(row["bap_id"]),
+			Timestamp:     str(row["timestamp"]),
+			ProviderName:  str(row["provider_name"]),
+			ItemsCount:    int(num(row["items_count"])),
+			QualityScore:  score(row["quality_score"]),
+			WarningsCount: int(num(row["warnings_count"])),
+		})
+	}
+	return out, nil
+}
+
+// GetProvider implements Backend.
+func (b *Trino) GetProvider(ctx context.Context, providerID string) (*Provider, error) {
+	sql, err := trino.Build(`
+		SELECT *
+		FROM providers
+		WHERE provider_id = ?
+		ORDER BY timestamp DESC
+		LIMIT 1
+	`, providerID)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := b.client.Query(ctx, sql)
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, ErrNotFound
+	}
+	row := rows[0]
+	p := &Provider{
+		ProviderID: str(row["provider_id"]),
+		Domain:     str(row["domain"]),
+		City:       str(row["city"]),
+		BapID:      str(row["bap_id"]),
+		BppID:      str(row["bpp_id"]),
+		Timestamp:  str(row["timestamp"]),
+	}
+	// JSON columns arrive either decoded or as JSON text.
+	decode(row["descriptor"], &p.Descriptor)
+	decode(row["categories"], &p.Categories)
+	decode(row["items"], &p.Items)
+	decode(row["warnings"], &p.Warnings)
+	p.QualityScore = score(row["quality_score"])
+	return p, nil
+}
+
+// ListItems implements Backend.
+func (b *Trino) ListItems(ctx context.Context, f ItemFilter) ([]Item, error) {
+	where, args := conditions(
+		"provider_id = ?", f.ProviderID,
+		"json_extract_scalar(item, '$.category_id') = ?", f.CategoryID,
+		"city = ?", f.City,
+	)
+	sql, err := trino.Build(`
+		SELECT
+			provider_id,
+			city,
+			domain,
+			json_extract_scalar(item, '$.id') AS item_id,
+			json_extract_scalar(item, '$.descriptor.name') AS item_name,
+			json_extract_scalar(item, '$.category_id') AS category_id,
+			json_extract_scalar(item, '$.price.value') AS price_value,
+			json_extract_scalar(item, '$.price.currency') AS price_currency
+		FROM providers
+		CROSS JOIN UNNEST(CAST(json_extract(items, '$') AS array(json))) AS t(item)
+		`+where+`
+		OFFSET ? LIMIT ?
+	`, append(args, f.Offset, f.Limit)...)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := b.client.Query(ctx, sql)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]Item, 0, len(rows))
+	for _, row := range rows {
+		out = append(out, Item{
+			ProviderID:    str(row["provider_id"]),
+			City:          str(row["city"]),
+			Domain:        str(row["domain"]),
+			ItemID:        str(row["item_id"]),
+			ItemName:      str(row["item_name"]),
+			CategoryID:    str(row["category_id"]),
+			PriceValue:    str(row["price_value"]),
+			PriceCurrency: str(row["price_currency"]),
+		})
+	}
+	return out, nil
+}
+
+// Stats implements Backend.
+func (b *Trino) Stats(ctx context.Context) (*Stats, error) {
+	rows, err := b.client.Query(ctx, `
+		SELECT
+			COUNT(DISTINCT provider_id) AS total_providers,
+			COUNT(*) AS total_records,
+			SUM(json_array_length(items)) AS total_items,
+			COUNT(DISTINCT domain) AS total_domains,
+			COUNT(DISTINCT city) AS total_cities,
+			CAST(MAX(timestamp) AS varchar) AS latest_update
+		FROM providers
+	`)
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return &Stats{}, nil
+	}
+	row := rows[0]
+	return &Stats{
+		TotalProviders: num(row["total_providers"]),
+		TotalRecords:   num(row["total_records"]),
+		TotalItems:     num(row["total_items"]),
+		TotalDomains:   num(row["total_domains"]),
+		TotalCities:    num(row["total_cities"]),
+		LatestUpdate:   str(row["latest_update"]),
+	}, nil
+}
+
+// conditions turns (clause, value) pairs into a WHERE clause over the
+// non-empty values and the matching Build arguments.
+func conditions(pairs ...string) (string, []any) {
+	var where []string
+	var args []any
+	for i := 0; i+1 < len(pairs); i += 2 {
+		if pairs[i+1] != "" {
+			where = append(where, pairs[i])
+			args = append(args, pairs[i+1])
+		}
+	}
+	if len(where) == 0 {
+		return "", args
+	}
+	return "WHERE " + strings.Join(where, " AND "), args
+}
+
+// score returns a quality_score column value, or nil for records without a score.
+func score(v interface{}) *float64 {
+	if f, ok := v.(float64); ok {
+		return &f
+	}
+	return nil
+}
+
+// decode stores a JSON column value (text or already decoded) in dst.
+func decode(v interface{}, dst interface{}) {
+	var raw []byte
+	if s, ok := v.(string); ok {
+		raw = []byte(s)
+	} else if v != nil {
+		raw, _ = json.Marshal(v)
+	}
+	if len(raw) > 0 {
+		json.Unmarshal(raw, dst)
+	}
+}
@@ -2,37 +2,76 @@ package curated
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"time"
 
+	"gcr-backend/internal/itemhash"
+	"gcr-backend/internal/logging"
+	"gcr-backend/internal/metrics"
 	"gcr-backend/internal/model"
+	"gcr-backend/internal/schemagate"
 	"gcr-backend/internal/storage"
+	"gcr-backend/internal/tracing"
 )
 
 // WriteValidProviders writes curated provider rows to Hudi (stub) and returns
 // CatalogAccepted events for each provider+category combination.
-func WriteValidProviders(ctx context.Context, env *model.OnSearchEnvelope, providers []model.Provider) ([]model.CatalogAccepted, error) {
+// Quality reports from SchemaGate are stored with the record and carried on the event.
+// Every provider is written with all its items; events are only emitted for
+// providers whose items changed (see schemagate.Changes.Modified).
+// Events are returned for the providers that were written even if others failed.
+func WriteValidProviders(ctx context.Context, env *model.OnSearchEnvelope, providers []model.Provider, quality map[string]schemagate.QualityReport, changes schemagate.Changes) ([]model.CatalogAccepted, error) {
 	events := []model.CatalogAccepted{}
 	tC := time.Now().UTC().Format(time.RFC3339Nano)
+	var writeErrs []error
 
 	for _, provider := range providers {
+		report, ok := quality[provider.ID]
+		if !ok {
+			report = schemagate.QualityReport{ProviderID: provider.ID, Score: 1, Warnings: []schemagate.Warning{}}
+		}
+
 		// Write to Hudi stub (JSONL)
-		if err := storage.WriteProviderCatalog(ctx, env.Context, provider); err != nil {
-			continue // skip on error, but continue with others
+		start := time.Now()
+		_, span := tracing.Start(ctx, "curated.write", tracing.KindInternal)
+		span.SetAttr("provider_id", provider.ID)
+		span.SetAttr("items", len(provider.Items))
+		err := storage.WriteCuratedProvider(ctx, env.Context, provider, report)
+		span.RecordError(err)
+		span.End()
+		metrics.CuratedWriteDuration.Since(start)
+		if err != nil {
+			// Continue with the others, but report the failure so the message is retried.
+			writeErrs = append(writeErrs, fmt.Errorf("provider %s: %w", provider.ID, err))
+			continue
+		}
+
+		// Record content hashes only after the write, so a failed write is retried as changed.
+		if err := itemhash.Record(ctx, env.Context.Domain, env.Context.City, provider.ID, provider.Items); err != nil {
+			logging.For(ctx, "curated").Warn("failed to record item hashes", logging.FieldProviderID, provider.ID, "error", err)
+		}
+
+		if !changes.Modified(provider.ID) {
+			// Byte-identical re-ingest: the projections already hold this provider.
+			continue
 		}
 
 		// Extract categories and emit one event per category
 		for _, cat := range provider.Categories {
 			events = append(events, model.CatalogAccepted{
-				SellerID:   env.Context.BppID,
-				City:       env.Context.City,
-				Category:   cat.ID,
-				Timestamp:  tC,
-				ProviderID: provider.ID,
-				Domain:     env.Context.Domain,
+				SellerID:     env.Context.BppID,
+				City:         env.Context.City,
+				Category:     cat.ID,
+				Timestamp:    tC,
+				ProviderID:   provider.ID,
+				Domain:       env.Context.Domain,
+				QualityScore: report.Score,
+				WarningCount: len(report.Warnings),
 			})
 		}
 	}
 
-	return events, nil
+	return events, errors.Join(writeErrs...)
 }
 
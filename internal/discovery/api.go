@@ -4,46 +4,53 @@ import (
 	"compress/gzip"
 	"encoding/json"
 	"fmt"
-	"log"
 	"net/http"
-	"os"
+	"sort"
+	"time"
 
 	"github.com/gorilla/mux"
-	"github.com/redis/go-redis/v9"
 
+	"gcr-backend/internal/logging"
+	"gcr-backend/internal/metrics"
 	"gcr-backend/internal/model"
 	"gcr-backend/internal/policy"
+	"gcr-backend/internal/ratelimit"
+	"gcr-backend/internal/readmodel"
+	"gcr-backend/internal/registry"
+	"gcr-backend/internal/signing"
+	"gcr-backend/internal/tracing"
 )
 
-func getenv(key, def string) string {
-	if v := os.Getenv(key); v != "" {
-		return v
-	}
-	return def
-}
-
 // Service provides Discovery/Publisher functionality for buyer /search and /on_search.
 type Service struct {
-	rdb    *redis.Client
-	policy *policy.Service
+	store   readmodel.Store
+	policy  *policy.Service
+	checker *registry.Checker
+	limiter *ratelimit.Limiter
 }
 
-// NewService creates a new Discovery Service.
-func NewService() *Service {
-	rdb := redis.NewClient(&redis.Options{
-		Addr: getenv("REDIS_ADDR", "redis:6379"),
-	})
+// NewService creates a new Discovery Service reading the projections in store.
+// checker rejects searches from bap_ids that are not active registered buyer
+// apps; limiter applies the per-buyer /search quotas.
+func NewService(store readmodel.Store, pol *policy.Service, checker *registry.Checker, limiter *ratelimit.Limiter) *Service {
 	return &Service{
-		rdb:    rdb,
-		policy: policy.NewService(),
+		store:   store,
+		policy:  pol,
+		checker: checker,
+		limiter: limiter,
 	}
 }
 
 // RegisterRoutes wires Discovery API routes.
 // gorilla/mux: Router handles buyer-facing /search and /on_search endpoints.
-func (s *Service) RegisterRoutes(r *mux.Router) {
-	r.HandleFunc("/ondc/search", s.searchHandler).Methods("POST")
-	r.HandleFunc("/ondc/on_search", s.onSearchReadHandler).Methods("GET")
+// Responses are ONDC-signed by signer (nil: unsigned). /search requests are
+// verified by verifier, so the bap_id its quota is keyed on belongs to the
+// signer; with ONDC_AUTH_MODE=off anyone can send any bap_id and the quota is
+// only advisory.
+func (s *Service) RegisterRoutes(r *mux.Router, signer *signing.Signer, verifier *signing.Verifier) {
+	search := s.limiter.ClientMiddleware(verifier.Middleware(s.limiter.Middleware(signer.Middleware(http.HandlerFunc(s.searchHandler)))))
+	r.Handle("/ondc/search", search).Methods("POST").Name(ratelimit.EndpointSearch)
+	r.Handle("/ondc/on_search", signer.Middleware(http.HandlerFunc(s.onSearchReadHandler))).Methods("GET")
 }
 
 // searchHandler handles buyer /search requests.
@@ -56,15 +63,44 @@ func (s *Service) searchHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	ctx := r.Context()
+	if sub, ok := signing.SubscriberFrom(ctx); ok && sub.SubscriberID != req.Context.BapID {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnauthorized)
+		_ = json.NewEncoder(w).Encode(model.NACK("POLICY-ERROR", "10001", "signer "+sub.SubscriberID+" does not match bap_id "+req.Context.BapID))
+		return
+	}
+	if err := s.checker.Check(ctx, registry.TypeBAP, req.Context.BapID, req.Context.Domain, req.Context.City, req.Context.BapURI); err != nil {
+		status, nack := registry.NACK(err)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		_ = json.NewEncoder(w).Encode(nack)
+		return
+	}
+	if !s.limiter.Check(w, r, ratelimit.EndpointSearch, req.Context.BapID) {
+		return
+	}
+
 	city := req.Context.City
 	category := req.Message.Intent.Item.Category.ID
 	buyerID := req.Context.BapID
 	domain := req.Context.Domain
+	start := time.Now()
+	// city and category come from the request; only pairs the index knows
+	// become label values, so buyers cannot grow the metric without bound.
+	metricCity, metricCategory := "other", "other"
+	defer func() { metrics.SearchDuration.Since(start, metricCity, metricCategory) }()
 
-	// redis/go-redis/v9: SMembers retrieves all members from Redis Set.
-	// Returns list of seller IDs for given city:category from Index projection.
+	// SMEMBERS returns the seller IDs for city:category from the Index projection.
 	indexKey := fmt.Sprintf("idx:%s:%s", city, category)
-	sellers, err := s.rdb.SMembers(ctx, indexKey).Result()
+	_, span := tracing.Start(ctx, "discovery.index", tracing.KindClient)
+	span.SetAttr("key", indexKey)
+	sellers, err := s.store.SMembers(ctx, indexKey)
+	span.SetAttr("sellers", len(sellers))
+	span.RecordError(err)
+	span.End()
+	if len(sellers) > 0 {
+		metricCity, metricCategory = city, category
+	}
 	if err != nil {
 		http.Error(w, "index lookup failed", http.StatusInternalServerError)
 		return
@@ -75,7 +111,7 @@ func (s *Service) searchHandler(w http.ResponseWriter, r *http.Request) {
 	for _, sellerID := range sellers {
 		status, err := s.policy.CheckPolicy(ctx, buyerID, sellerID, domain, city)
 		if err != nil {
-			log.Printf("Policy check error: %v", err)
+			logging.For(ctx, "discovery").Warn("policy check failed", "seller_id", sellerID, "error", err)
 			continue
 		}
 		if status == policy.PolicyAllowed {
@@ -83,6 +119,9 @@ func (s *Service) searchHandler(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	// Rank by catalog quality score (best first); sellers with warnings are still returned.
+	allowedSellers = s.rankByQuality(r, city, category, allowedSellers)
+
 	w.Header().Set("Content-Type", "application/json")
 	w.Header().Set("Content-Encoding", "gzip")
 	gw := gzip.NewWriter(w)
@@ -94,8 +133,38 @@ func (s *Service) searchHandler(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// rankByQuality orders sellers by the quality:{city}:{category} sorted set
+// maintained by the Index Projector. Sellers without a score count as 0;
+// on store errors the input order is returned unchanged.
+func (s *Service) rankByQuality(r *http.Request, city, category string, sellers []string) []string {
+	if len(sellers) < 2 {
+		return sellers
+	}
+
+	// ZMSCORE returns scores for multiple members (0 for missing).
+	qualityKey := fmt.Sprintf("quality:%s:%s", city, category)
+	scores, err := s.store.ZMScore(r.Context(), qualityKey, sellers...)
+	if err != nil {
+		logging.For(r.Context(), "discovery").Warn("quality ranking failed", "key", qualityKey, "error", err)
+		return sellers
+	}
+
+	ranked := make([]string, len(sellers))
+	copy(ranked, sellers)
+	scoreOf := make(map[string]float64, len(sellers))
+	for i, sellerID := range sellers {
+		if i < len(scores) {
+			scoreOf[sellerID] = scores[i]
+		}
+	}
+	sort.SliceStable(ranked, func(i, j int) bool {
+		return scoreOf[ranked[i]] > scoreOf[ranked[j]]
+	})
+	return ranked
+}
+
 // onSearchReadHandler returns a ready-to-send /on_search JSON for a specific seller.
-// It reads from Redis Shard (overlay-first if exists).
+// It reads the Shard projection (overlay-first if exists).
 func (s *Service) onSearchReadHandler(w http.ResponseWriter, r *http.Request) {
 	sellerID := r.URL.Query().Get("seller_id")
 	city := r.URL.Query().Get("city")
@@ -107,15 +176,17 @@ func (s *Service) onSearchReadHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	ctx := r.Context()
+	ctx, span := tracing.Start(r.Context(), "discovery.shard", tracing.KindClient)
+	defer span.End()
+	span.SetAttr("seller_id", sellerID)
 
-	// redis/go-redis/v9: Get retrieves string value from Redis.
 	// First tries overlay shard (buyer-specific), then falls back to base shard.
 	var shardKey string
 	if buyerID != "" {
 		shardKey = fmt.Sprintf("overlay:%s:%s:%s:cat:%s", buyerID, sellerID, city, category)
-		val, err := s.rdb.Get(ctx, shardKey).Result()
+		val, err := s.store.Get(ctx, shardKey)
 		if err == nil {
+			span.SetAttr("overlay", true)
 			// Overlay found, return it
 			w.Header().Set("Content-Type", "application/json")
 			w.Header().Set("Content-Encoding", "gzip")
@@ -126,11 +197,12 @@ func (s *Service) onSearchReadHandler(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	// redis/go-redis/v9: Get retrieves base shard JSON (seller×city×category).
-	// This is the ready-to-send /on_search payload stored by Shard Projector.
+	// Base shard JSON (seller×city×category): the ready-to-send /on_search
+	// payload stored by Shard Projector.
 	shardKey = fmt.Sprintf("shard:%s:%s:cat:%s", sellerID, city, category)
-	val, err := s.rdb.Get(ctx, shardKey).Result()
+	val, err := s.store.Get(ctx, shardKey)
 	if err != nil {
+		span.SetAttr("found", false)
 		http.Error(w, "shard not found", http.StatusNotFound)
 		return
 	}
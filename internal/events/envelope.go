@@ -0,0 +1,122 @@
+package events
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Event types carried in an Envelope.
+const (
+	TypeCatalogAccepted = "CatalogAccepted"
+)
+
+// Schema is the version contract for one event type.
+//   - Version: what this build produces and fully understands.
+//   - MinReaderVersion: the oldest reader that can still decode what this build
+//     produces (additive changes keep it low; breaking changes raise it).
+type Schema struct {
+	Type             string `json:"type"`
+	Version          int    `json:"version"`
+	MinReaderVersion int    `json:"min_reader_version"`
+}
+
+// registry lists the event schemas known to this build.
+//
+// CatalogAccepted:
+//
+//	v1 – bare JSON (seller_id, city, category, timestamp, provider_id, domain), no envelope
+//	v2 – enveloped, adds quality_score and warning_count (additive, v1 readers ignore them)
+var registry = map[string]Schema{
+	TypeCatalogAccepted: {Type: TypeCatalogAccepted, Version: 2, MinReaderVersion: 1},
+}
+
+// Schemas returns the registered event schemas.
+func Schemas() []Schema {
+	out := make([]Schema, 0, len(registry))
+	for _, s := range registry {
+		out = append(out, s)
+	}
+	return out
+}
+
+// TraceContext is the W3C trace context carried with an event.
+type TraceContext struct {
+	TraceParent string `json:"traceparent,omitempty"`
+	TraceState  string `json:"tracestate,omitempty"`
+}
+
+// Envelope wraps every versioned event on Kafka.
+type Envelope struct {
+	Type             string          `json:"type"`
+	Version          int             `json:"version"`
+	MinReaderVersion int             `json:"min_reader_version"`
+	ProducedAt       string          `json:"produced_at"`
+	Trace            *TraceContext   `json:"trace,omitempty"`
+	Payload          json.RawMessage `json:"payload"`
+}
+
+// ErrIncompatible means the event requires a newer reader than this build.
+// Consumers should not drop such events: they belong in the retry/DLQ path
+// and can be replayed once every instance is upgraded.
+var ErrIncompatible = errors.New("events: incompatible event version")
+
+// Encode wraps payload in an Envelope at the registered version of eventType.
+func Encode(eventType string, payload any, trace *TraceContext) ([]byte, error) {
+	schema, ok := registry[eventType]
+	if !ok {
+		return nil, fmt.Errorf("events: unknown event type %q", eventType)
+	}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(Envelope{
+		Type:             schema.Type,
+		Version:          schema.Version,
+		MinReaderVersion: schema.MinReaderVersion,
+		ProducedAt:       time.Now().UTC().Format(time.RFC3339Nano),
+		Trace:            trace,
+		Payload:          data,
+	})
+}
+
+// Decode reads an event of eventType into out and returns its envelope.
+// Legacy messages without an envelope are treated as version 1. Newer
+// versions are accepted as long as their MinReaderVersion is not above the
+// version this build understands (unknown fields are ignored).
+func Decode(data []byte, eventType string, out any) (Envelope, error) {
+	schema, ok := registry[eventType]
+	if !ok {
+		return Envelope{}, fmt.Errorf("events: unknown event type %q", eventType)
+	}
+
+	var env Envelope
+	if err := json.Unmarshal(data, &env); err != nil {
+		return Envelope{}, err
+	}
+	if env.Type == "" && len(env.Payload) == 0 {
+		// Pre-envelope producer (v1): the message is the bare payload.
+		env = Envelope{Type: eventType, Version: 1, MinReaderVersion: 1, Payload: bytes.Clone(data)}
+	}
+
+	if env.Type != eventType {
+		return env, fmt.Errorf("events: expected %s, got %q", eventType, env.Type)
+	}
+	if env.Version > schema.Version && env.MinReaderVersion > schema.Version {
+		return env, fmt.Errorf("%w: %s v%d needs reader v%d, this build reads v%d",
+			ErrIncompatible, env.Type, env.Version, env.MinReaderVersion, schema.Version)
+	}
+
+	if err := json.Unmarshal(env.Payload, out); err != nil {
+		return env, err
+	}
+	return env, nil
+}
+
+// CurrentVersion returns the version this build produces for eventType, or 0 if unknown.
+func CurrentVersion(eventType string) int {
+	return registry[eventType].Version
+}
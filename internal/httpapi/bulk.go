@@ -0,0 +1,349 @@
+package httpapi
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"gcr-backend/internal/bus"
+	"gcr-backend/internal/kstream"
+	"gcr-backend/internal/logging"
+	"gcr-backend/internal/model"
+	"gcr-backend/internal/ratelimit"
+)
+
+// Bulk ingest limits (override via env). Each provider is published to Kafka
+// whole, as its own message, when the next provider starts; memory stays
+// bounded by one provider regardless of catalog size. A provider is never
+// split: downstream the latest record of a provider replaces the previous
+// one, so a partial record would drop the rest of its items. Providers over
+// either limit are rejected (the topics accept messages up to 100MB).
+var (
+	bulkMaxLineBytes     = getenvInt("BULK_MAX_LINE_BYTES", 16<<20)
+	bulkMaxProviderItems = getenvInt("BULK_MAX_PROVIDER_ITEMS", 200000)
+	bulkMaxProviderBytes = getenvInt("BULK_MAX_PROVIDER_BYTES", 64<<20)
+	bulkMaxLineErrors    = 20
+)
+
+func getenvInt(key string, def int) int {
+	if v, err := strconv.Atoi(os.Getenv(key)); err == nil && v > 0 {
+		return v
+	}
+	return def
+}
+
+// bulkHeader is the first NDJSON line: the on_search context plus the
+// catalog-level fields that are repeated on every per-provider message.
+type bulkHeader struct {
+	Context model.OnSearchContext `json:"context" validate:"required"`
+	Catalog struct {
+		BPPDescriptor   model.BPPDescriptor `json:"bpp/descriptor" validate:"required"`
+		BPPFulfillments []model.Fulfillment `json:"bpp/fulfillments" validate:"dive"`
+	} `json:"catalog" validate:"required"`
+}
+
+// bulkLine is every following NDJSON line: either a provider (optionally
+// with inline items) or a single item belonging to the current provider.
+type bulkLine struct {
+	Type       string          `json:"type"` // "provider" or "item"
+	Provider   *model.Provider `json:"provider,omitempty"`
+	ProviderID string          `json:"provider_id,omitempty"`
+	Item       *model.Item     `json:"item,omitempty"`
+}
+
+// BulkStats is returned to the client after a streaming ingest.
+type BulkStats struct {
+	Lines     int64 `json:"lines"`
+	Providers int64 `json:"providers"`
+	Items     int64 `json:"items"`
+	Messages  int64 `json:"messages"`
+	// Rejected counts providers over BULK_MAX_PROVIDER_ITEMS/BYTES.
+	Rejected int64 `json:"rejected_providers"`
+	// Queued counts messages stored in the outbox but not yet on Kafka.
+	Queued       int64    `json:"queued"`
+	InvalidLines int64    `json:"invalid_lines"`
+	Errors       []string `json:"errors,omitempty"`
+	// Failed counts providers that could not be stored; reading stops at
+	// the first one. Error says why the request stopped before the end of
+	// the body. Providers counted in Messages are stored either way, so a
+	// retry only needs to resend the providers after them.
+	Failed int64  `json:"failed_providers"`
+	Error  string `json:"error,omitempty"`
+	// DurationMillis is the end-to-end time to stream and publish the catalog.
+	DurationMillis int64 `json:"duration_ms"`
+}
+
+// bulkOnSearchHandler accepts very large catalogs as NDJSON (optionally gzip,
+// or split across the ordered parts of a multipart/form-data upload).
+//
+// Line 1:  {"context": {...}, "catalog": {"bpp/descriptor": {...}, "bpp/fulfillments": [...]}}
+// Line 2+: {"type": "provider", "provider": {...}}
+//
+//	{"type": "item", "provider_id": "...", "item": {...}}
+//
+// Each provider is published to catalog.ingest as its own message, so the
+// payload is never held in memory as a whole.
+func (e *Edge) bulkOnSearchHandler(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+
+	body, err := bulkBody(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	scanner := bufio.NewScanner(body)
+	// The scanner's limit is the larger of max and the buffer's capacity.
+	scanner.Buffer(make([]byte, 0, min(64*1024, bulkMaxLineBytes)), bulkMaxLineBytes)
+
+	// Header line
+	if !scanner.Scan() {
+		http.Error(w, "missing NDJSON header line", http.StatusBadRequest)
+		return
+	}
+	var header bulkHeader
+	if err := json.Unmarshal(scanner.Bytes(), &header); err != nil {
+		http.Error(w, "invalid NDJSON header line", http.StatusBadRequest)
+		return
+	}
+	if err := validate.Struct(header); err != nil {
+		http.Error(w, "schema validation failed: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if !signedBy(w, r, header.Context.BppID) || !e.registered(w, r, header.Context) ||
+		!e.limiter.Check(w, r, ratelimit.EndpointOnSearchBulk, header.Context.BppID) {
+		return
+	}
+
+	ctx := logging.With(r.Context(),
+		logging.FieldTransactionID, header.Context.TransactionID,
+		logging.FieldMessageID, header.Context.MessageID,
+		logging.FieldBppID, header.Context.BppID)
+	stats := &BulkStats{Lines: 1}
+	pub := &bulkPublisher{edge: e, header: &header, stats: stats}
+	publishFailed := false
+
+	for scanner.Scan() {
+		stats.Lines++
+		line := scanner.Bytes()
+		if len(strings.TrimSpace(string(line))) == 0 {
+			continue
+		}
+		if err := pub.handleLine(ctx, line); err != nil {
+			stats.lineError(stats.Lines, err)
+			if errors.Is(err, errPublish) {
+				publishFailed = true
+				break
+			}
+		}
+	}
+
+	// Every outcome reports the stats: providers already published stay
+	// published, and the seller needs the counts to avoid resending them.
+	status := http.StatusOK
+	if err := scanner.Err(); err != nil {
+		// The provider being read may be truncated: it is not published.
+		status = http.StatusBadRequest
+		stats.Error = "failed to read body: " + err.Error()
+		if errors.Is(err, bufio.ErrTooLong) {
+			status = http.StatusRequestEntityTooLarge
+			stats.Error = fmt.Sprintf("line %d exceeds %d bytes", stats.Lines+1, bulkMaxLineBytes)
+		}
+	} else if !publishFailed {
+		if err := pub.flush(ctx); err != nil {
+			stats.lineError(stats.Lines, err)
+			publishFailed = true
+		}
+	}
+	if publishFailed {
+		status = http.StatusBadGateway
+		stats.Failed++
+		stats.Error = "failed to store a provider for catalog.ingest, later providers were not read"
+	}
+
+	stats.DurationMillis = time.Since(start).Milliseconds()
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(stats)
+}
+
+var errPublish = errors.New("store for catalog.ingest failed")
+
+func (s *BulkStats) lineError(line int64, err error) {
+	s.InvalidLines++
+	if len(s.Errors) < bulkMaxLineErrors {
+		s.Errors = append(s.Errors, fmt.Sprintf("line %d: %v", line, err))
+	}
+}
+
+// bulkBody returns a sequential reader over the NDJSON payload, handling
+// gzip Content-Encoding and multipart uploads (parts are read in order).
+func bulkBody(r *http.Request) (io.Reader, error) {
+	var reader io.Reader = r.Body
+	if enc := r.Header.Get("Content-Encoding"); strings.EqualFold(enc, "gzip") {
+		gr, err := gzip.NewReader(r.Body)
+		if err != nil {
+			return nil, errors.New("failed to decompress gzip body")
+		}
+		reader = gr
+	}
+
+	mediaType, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err == nil && strings.HasPrefix(mediaType, "multipart/") {
+		return &multipartChain{mr: multipart.NewReader(reader, params["boundary"])}, nil
+	}
+	return reader, nil
+}
+
+// multipartChain concatenates the parts of a multipart body into one stream.
+type multipartChain struct {
+	mr   *multipart.Reader
+	part *multipart.Part
+}
+
+func (m *multipartChain) Read(p []byte) (int, error) {
+	for {
+		if m.part == nil {
+			part, err := m.mr.NextPart()
+			if err != nil {
+				return 0, err // io.EOF after the last part
+			}
+			m.part = part
+		}
+		n, err := m.part.Read(p)
+		if err == io.EOF {
+			m.part.Close()
+			m.part = nil
+			if n > 0 {
+				return n, nil
+			}
+			continue
+		}
+		return n, err
+	}
+}
+
+// bulkPublisher accumulates one provider at a time and publishes it as a
+// standalone on_search envelope when the next provider starts.
+type bulkPublisher struct {
+	edge    *Edge
+	header  *bulkHeader
+	stats   *BulkStats
+	current *model.Provider
+	bytes   int
+	// skipping is the ID of an oversized provider whose item lines are dropped.
+	skipping string
+
+	headerSent bool
+}
+
+func (p *bulkPublisher) handleLine(ctx context.Context, line []byte) error {
+	var bl bulkLine
+	if err := json.Unmarshal(line, &bl); err != nil {
+		return errors.New("invalid JSON")
+	}
+
+	switch bl.Type {
+	case "provider":
+		if bl.Provider == nil || bl.Provider.ID == "" {
+			return errors.New("provider line without provider.id")
+		}
+		if err := p.flush(ctx); err != nil {
+			return err
+		}
+		p.skipping = ""
+		p.current = bl.Provider
+		p.bytes = len(line)
+		p.stats.Providers++
+		p.stats.Items += int64(len(bl.Provider.Items))
+	case "item":
+		if bl.Item == nil {
+			return errors.New("item line without item")
+		}
+		if p.skipping != "" && bl.ProviderID == p.skipping {
+			return nil // already reported with the provider
+		}
+		if p.current == nil || bl.ProviderID != p.current.ID {
+			return fmt.Errorf("item %s does not follow its provider line %q", bl.Item.ID, bl.ProviderID)
+		}
+		p.current.Items = append(p.current.Items, *bl.Item)
+		p.bytes += len(line)
+		p.stats.Items++
+	default:
+		return fmt.Errorf("unknown line type %q", bl.Type)
+	}
+
+	if p.current != nil && (len(p.current.Items) > bulkMaxProviderItems || p.bytes > bulkMaxProviderBytes) {
+		// Too large to publish as one message: drop the whole provider
+		// rather than storing a part of it.
+		p.skipping = p.current.ID
+		p.current = nil
+		p.bytes = 0
+		p.stats.Rejected++
+		return fmt.Errorf("provider %s exceeds %d items / %d bytes, rejected", p.skipping, bulkMaxProviderItems, bulkMaxProviderBytes)
+	}
+	return nil
+}
+
+// flush publishes the current provider (if any) and clears it.
+func (p *bulkPublisher) flush(ctx context.Context) error {
+	if p.current == nil {
+		return nil
+	}
+	err := p.publish(ctx, *p.current)
+	p.current = nil
+	p.bytes = 0
+	return err
+}
+
+func (p *bulkPublisher) publish(ctx context.Context, provider model.Provider) error {
+	env := model.OnSearchEnvelope{
+		Context: p.header.Context,
+		Message: model.OnSearchMessage{
+			Catalog: model.Catalog{
+				BPPDescriptor:   p.header.Catalog.BPPDescriptor,
+				BPPFulfillments: p.header.Catalog.BPPFulfillments,
+			},
+		},
+	}
+
+	msgs := []bus.Message{}
+	// The envelope header goes out once, before the first provider; the
+	// provider count is unknown while streaming.
+	if !p.headerSent {
+		header, err := kstream.EnvelopeHeaderMessage(&env, -1)
+		if err != nil {
+			return err
+		}
+		msgs = append(msgs, header)
+	}
+	msg, err := kstream.ProviderMessage(&env, provider, int(p.stats.Providers-1))
+	if err != nil {
+		return err
+	}
+	msgs = append(msgs, msg)
+
+	delivery, err := p.edge.deliver(ctx, kstream.TopicCatalogIngest, msgs)
+	if err != nil {
+		logging.For(ctx, "edge").Error("bulk: failed to store provider", logging.FieldProviderID, provider.ID, "error", err)
+		return fmt.Errorf("%w: %v", errPublish, err)
+	}
+	p.headerSent = true
+	p.stats.Messages++
+	if delivery == DeliveryQueued {
+		p.stats.Queued++
+	}
+	return nil
+}
@@ -0,0 +1,180 @@
+package httpapi
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"gcr-backend/internal/bloom"
+	"gcr-backend/internal/bus"
+	"gcr-backend/internal/idempotency"
+	"gcr-backend/internal/itemhash"
+	"gcr-backend/internal/kstream"
+	"gcr-backend/internal/projections"
+	"gcr-backend/internal/ratelimit"
+	"gcr-backend/internal/readmodel"
+	"gcr-backend/internal/registry"
+	"gcr-backend/internal/signing"
+)
+
+const pipelinePayload = `{"context": {"domain": "ONDC:RET11", "country": "IND", "city": "std:020", "action": "on_search", "core_version": "1.2.0",
+  "bap_id": "buyer.example.com", "bap_uri": "https://buyer.example.com", "bpp_id": "seller.example.com", "bpp_uri": "https://seller.example.com",
+  "transaction_id": "TRANSACTION_ID", "message_id": "MESSAGE_ID", "timestamp": "2026-01-01T00:00:00Z"},
+ "message": {"catalog": {"bpp/descriptor": {"name": "S", "short_desc": "s", "long_desc": "l"}, "bpp/providers": [
+  {"id": "PROVIDER_ID", "descriptor": {"name": "P", "short_desc": "s", "long_desc": "l"},
+   "categories": [{"id": "grocery", "descriptor": {"name": "Grocery", "short_desc": "s", "long_desc": "l"}}],
+   "items": [{"id": "i1", "descriptor": {"name": "Rice"}, "category_id": "grocery", "price": {"currency": "INR", "value": "10"}},
+             {"id": "i2", "descriptor": {"name": "Dal"}, "category_id": "grocery", "price": {"currency": "INR", "value": "20"}}]}]}}}`
+
+// pipelineStore is shared by every run of the test: idempotency, itemhash and
+// bloom keep the first store they are initialised with.
+var pipelineStore = readmodel.NewMemory()
+
+// TestPipelineMemory runs edge → SchemaGate → curated → projectors in process,
+// on the memory bus and read model (BUS_BACKEND=memory, READMODEL_BACKEND=memory).
+func TestPipelineMemory(t *testing.T) {
+	// The curated store writes under ./data.
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+
+	store := pipelineStore
+	bloom.Init(store)
+	idempotency.Init(store)
+	itemhash.Init(store)
+	mem := bus.NewMemory()
+	defer mem.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go kstream.ConsumeIngestTopic(ctx, mem, mem, store)
+	go projections.ConsumeAcceptedTopic(ctx, mem, mem, store)
+
+	limiter, err := ratelimit.New(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := mux.NewRouter()
+	RegisterRoutes(r, mem, nil, signing.NewVerifier(nil), registry.NewChecker(nil), limiter)
+
+	// Fresh IDs, so that repeated runs (go test -count) start from scratch.
+	run := strconv.FormatInt(time.Now().UnixNano(), 36)
+	providerID := "p-" + run
+	post := func(messageID string) *httptest.ResponseRecorder {
+		body := strings.NewReplacer("TRANSACTION_ID", "t-"+run, "MESSAGE_ID", messageID, "PROVIDER_ID", providerID).Replace(pipelinePayload)
+		req := httptest.NewRequest(http.MethodPost, "/ondc/on_search", strings.NewReader(body))
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("on_search %s: status %d: %s", messageID, rec.Code, rec.Body)
+		}
+		return rec
+	}
+
+	rec := post("m1")
+	gr, err := gzip.NewReader(rec.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var stats struct {
+		Delivery string `json:"delivery"`
+	}
+	if err := json.NewDecoder(gr).Decode(&stats); err != nil {
+		t.Fatal(err)
+	}
+	if stats.Delivery != DeliveryPublished {
+		t.Fatalf("delivery = %q, want %q", stats.Delivery, DeliveryPublished)
+	}
+
+	// Projectors: the seller has a shard for the category and is indexed.
+	waitFor(t, "shard", func() bool {
+		shard, err := store.Get(ctx, "shard:seller.example.com:std:020:cat:grocery")
+		return err == nil && strings.Contains(shard, `"provider_id":"`+providerID+`"`)
+	})
+	sellers, err := store.SMembers(ctx, "idx:std:020:grocery")
+	if err != nil || len(sellers) != 1 || sellers[0] != "seller.example.com" {
+		t.Fatalf("index sellers = %v, %v; want [seller.example.com]", sellers, err)
+	}
+
+	// Curated: one complete, scored record for the provider, whose score the
+	// quality ranking carries.
+	records := curatedRecords(t, providerID)
+	if len(records) != 1 {
+		t.Fatalf("%d curated records, want 1", len(records))
+	}
+	if n := len(records[0]["items"].([]any)); n != 2 {
+		t.Fatalf("curated record has %d items, want 2", n)
+	}
+	score, ok := records[0]["quality_score"].(float64)
+	if !ok {
+		t.Fatal("curated record has no quality_score")
+	}
+	scores, err := store.ZMScore(ctx, "quality:std:020:grocery", "seller.example.com")
+	if err != nil || scores[0] != score {
+		t.Fatalf("quality ranking score = %v, %v; want %v", scores, err, score)
+	}
+
+	// A retry of the same message replays the result and is not published again.
+	if rec := post("m1"); rec.Header().Get("X-Idempotent-Replay") != "true" {
+		t.Fatal("retry of m1 was not replayed")
+	}
+
+	// A new message with the same catalog is written again as the complete provider.
+	post("m2")
+	waitFor(t, "second curated record", func() bool { return len(curatedRecords(t, providerID)) == 2 })
+	if n := len(curatedRecords(t, providerID)[1]["items"].([]any)); n != 2 {
+		t.Fatalf("unchanged re-ingest wrote %d items, want 2", n)
+	}
+}
+
+// waitFor polls cond until it holds, failing the test after a few seconds.
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// curatedRecords reads the curated JSONL records of providerID.
+func curatedRecords(t *testing.T, providerID string) []map[string]any {
+	t.Helper()
+	f, err := os.Open(filepath.Join("data", "hudi", "providers", providerID+".jsonl"))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var records []map[string]any
+	sc := bufio.NewScanner(f)
+	sc.Buffer(nil, 1<<20)
+	for sc.Scan() {
+		var rec map[string]any
+		if err := json.Unmarshal(sc.Bytes(), &rec); err != nil {
+			t.Fatal(err)
+		}
+		records = append(records, rec)
+	}
+	return records
+}
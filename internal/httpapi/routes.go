@@ -2,28 +2,91 @@ package httpapi
 
 import (
 	"compress/gzip"
+	"context"
 	"encoding/json"
+	"errors"
 	"io"
-	"log"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/go-playground/validator/v10"
 	"github.com/gorilla/mux"
 
+	"gcr-backend/internal/idempotency"
+	"gcr-backend/internal/bus"
 	"gcr-backend/internal/kstream"
+	"gcr-backend/internal/logging"
+	"gcr-backend/internal/metrics"
 	"gcr-backend/internal/model"
+	"gcr-backend/internal/outbox"
 	"gcr-backend/internal/processing"
+	"gcr-backend/internal/ratelimit"
+	"gcr-backend/internal/readmodel"
+	"gcr-backend/internal/registry"
+	"gcr-backend/internal/signing"
+	"gcr-backend/internal/tracing"
 )
 
 // go-playground/validator/v10: Struct validator for ONDC payload schema validation.
 var validate = validator.New()
 
+// Delivery outcomes reported to sellers.
+const (
+	// DeliveryPublished: the payload is on Kafka catalog.ingest.
+	DeliveryPublished = "published"
+	// DeliveryQueued: the payload is durably in the outbox; the relay will publish it.
+	DeliveryQueued = "queued"
+)
+
+// Edge holds the dependencies of the ingest-side HTTP handlers.
+type Edge struct {
+	publisher bus.Publisher
+	outbox   *outbox.Outbox
+	checker  *registry.Checker
+	limiter  *ratelimit.Limiter
+}
+
 // RegisterRoutes wires HTTP routes (Edge/ingest side only).
 // gorilla/mux: Router provides method-based routing and URL pattern matching.
-func RegisterRoutes(r *mux.Router) {
+// With a non-nil outbox, payloads are stored durably before they are acknowledged.
+// The /ondc routes verify the ONDC Authorization header with verifier, and
+// checker that bpp_id is a registered seller app at bpp_uri; limiter applies
+// the per-seller quotas.
+func RegisterRoutes(r *mux.Router, pub bus.Publisher, ob *outbox.Outbox, verifier *signing.Verifier, checker *registry.Checker, limiter *ratelimit.Limiter) {
+	edge := &Edge{publisher: pub, outbox: ob, checker: checker, limiter: limiter}
 	r.HandleFunc("/health", healthHandler).Methods(http.MethodGet)
-	r.HandleFunc("/ondc/on_search", onSearchHandler).Methods(http.MethodPost) // Seller ingest
+
+	// gorilla/mux: Subrouter scopes the middlewares to /ondc. The client quota
+	// runs first, so a flood is rejected before its body is buffered for the
+	// signature digest; the signer's quota only once the signature is verified.
+	ondc := r.PathPrefix("/ondc").Subrouter()
+	ondc.Use(limiter.ClientMiddleware, verifier.Middleware, limiter.Middleware)
+	ondc.HandleFunc("/on_search", instrument("on_search", edge.onSearchHandler)).Methods(http.MethodPost).Name(ratelimit.EndpointOnSearch)                // Seller ingest
+	ondc.HandleFunc("/on_search/bulk", instrument("on_search_bulk", edge.bulkOnSearchHandler)).Methods(http.MethodPost).Name(ratelimit.EndpointOnSearchBulk) // Seller ingest (NDJSON streaming)
+}
+
+// statusRecorder captures the response status for metrics.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (s *statusRecorder) WriteHeader(code int) {
+	s.status = code
+	s.ResponseWriter.WriteHeader(code)
+}
+
+// instrument records request count and latency of an ingest endpoint.
+func instrument(endpoint string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next(rec, r)
+		metrics.IngestRequests.Inc(endpoint, strconv.Itoa(rec.status))
+		metrics.IngestDuration.Since(start, endpoint)
+	}
 }
 
 func healthHandler(w http.ResponseWriter, r *http.Request) {
@@ -34,7 +97,7 @@ func healthHandler(w http.ResponseWriter, r *http.Request) {
 // onSearchHandler accepts large ONDC on_search payloads from sellers (Edge/ingest side).
 // It validates, then fans out work to a parallel processing pipeline so that
 // even large catalogs complete in a few seconds.
-func onSearchHandler(w http.ResponseWriter, r *http.Request) {
+func (e *Edge) onSearchHandler(w http.ResponseWriter, r *http.Request) {
 	var payload model.OnSearchEnvelope
 	reader := io.Reader(r.Body)
 	if enc := r.Header.Get("Content-Encoding"); strings.EqualFold(enc, "gzip") {
@@ -59,29 +122,159 @@ func onSearchHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	ctx := r.Context()
+	// Unsigned requests are limited here, on bpp_id, once it is known.
+	if !signedBy(w, r, payload.Context.BppID) || !e.registered(w, r, payload.Context) ||
+		!e.limiter.Check(w, r, ratelimit.EndpointOnSearch, payload.Context.BppID) {
+		return
+	}
+
+	// Every log line and bus message of this on_search carries its IDs, so
+	// SchemaGate and projector logs can be filtered by transaction or seller.
+	ctx := logging.With(r.Context(),
+		logging.FieldTransactionID, payload.Context.TransactionID,
+		logging.FieldMessageID, payload.Context.MessageID,
+		logging.FieldBppID, payload.Context.BppID)
+	logger := logging.For(ctx, "edge")
 
-	// Edge: Publish to Kafka catalog.ingest (as per sequence diagram)
-	if err := kstream.PublishOnSearchIngest(ctx, &payload); err != nil {
-		log.Printf("Edge: failed to publish to Kafka: %v", err)
-		// Continue anyway (fire-and-forget per architecture)
+	// Idempotency: a BPP retry carries the same transaction_id/message_id.
+	// Only the first attempt is processed; duplicates replay the original result.
+	idemKey := idempotency.Key(payload.Context.BppID, payload.Context.TransactionID, payload.Context.MessageID)
+	if !idempotency.Claim(ctx, "edge", idemKey) {
+		original, err := idempotency.Lookup(ctx, "edge", idemKey)
+		if errors.Is(err, idempotency.ErrInProgress) {
+			http.Error(w, "duplicate message_id: original request still in progress", http.StatusConflict)
+			return
+		}
+		if err == nil {
+			logger.Info("duplicate message, replaying original result")
+			w.Header().Set("X-Idempotent-Replay", "true")
+			writeGzipJSON(w, original)
+			return
+		}
+		// The claim expired between SETNX and GET: take it over, unless a
+		// concurrent retry got there first. (A store error fails open, as Claim does.)
+		if errors.Is(err, readmodel.ErrNotFound) && !idempotency.Claim(ctx, "edge", idemKey) {
+			http.Error(w, "duplicate message_id: original request still in progress", http.StatusConflict)
+			return
+		}
+	}
+
+	// Edge: Publish to Kafka catalog.ingest (as per sequence diagram), via the
+	// outbox so the catalog is never lost when Kafka is unavailable.
+	msgs, err := kstream.OnSearchIngestMessages(&payload)
+	if err != nil {
+		idempotency.Release(ctx, "edge", idemKey)
+		http.Error(w, "failed to encode payload", http.StatusInternalServerError)
+		return
+	}
+	delivery, err := e.deliver(ctx, kstream.TopicCatalogIngest, msgs)
+	if err != nil {
+		logger.Error("failed to store payload", "error", err)
+		idempotency.Release(ctx, "edge", idemKey)
+		http.Error(w, "ingest unavailable, retry later", http.StatusServiceUnavailable)
+		return
 	}
 
 	// Also process inline for immediate response (stats)
 	stats, err := processing.ProcessOnSearch(ctx, &payload)
 	if err != nil {
-		log.Printf("on_search processing error: %v", err)
+		logger.Error("on_search processing failed", "error", err)
+		idempotency.Release(ctx, "edge", idemKey)
 		http.Error(w, "processing failed", http.StatusInternalServerError)
 		return
 	}
 
+	stats.Delivery = delivery
+	body, _ := json.Marshal(stats)
+	if err := idempotency.SaveResult(ctx, "edge", idemKey, body); err != nil {
+		logger.Warn("failed to save idempotency result", "error", err)
+	}
+
+	writeGzipJSON(w, body)
+}
+
+// deliver stores msgs in the outbox (fsynced) and then tries to publish them
+// right away. It returns DeliveryPublished or DeliveryQueued; an error means
+// the payload is not durable and the seller must retry.
+func (e *Edge) deliver(ctx context.Context, topic string, msgs []bus.Message) (delivery string, err error) {
+	ctx, span := tracing.Start(ctx, "publish "+topic, tracing.KindProducer)
+	defer func() {
+		span.SetAttr("messages", len(msgs))
+		span.SetAttr("delivery", delivery)
+		span.RecordError(err)
+		span.End()
+	}()
+	// The trace context travels in the message headers (and through the outbox).
+	kstream.InjectContext(ctx, msgs)
+
+	if e.outbox == nil {
+		if err := e.publisher.PublishSync(ctx, topic, msgs...); err != nil {
+			return "", err
+		}
+		return DeliveryPublished, nil
+	}
+
+	// Older records still waiting for the relay must reach Kafka first, or a
+	// stale catalog would overwrite this one downstream: queue behind them.
+	backlog := e.outbox.Pending()
+	seq, err := e.outbox.Append(topic, msgs)
+	if err != nil {
+		return "", err
+	}
+	if backlog > 0 {
+		e.outbox.Release(seq)
+		return DeliveryQueued, nil
+	}
+	if err := e.publisher.PublishSync(ctx, topic, msgs...); err != nil {
+		logging.For(ctx, "edge").Warn("bus unavailable, queued for relay", "outbox_seq", seq, "error", err)
+		e.outbox.Release(seq)
+		return DeliveryQueued, nil
+	}
+	if err := e.outbox.Ack(seq); err != nil {
+		logging.For(ctx, "edge").Error("outbox ack failed, relay may publish a duplicate", "outbox_seq", seq, "error", err)
+		e.outbox.Release(seq)
+	}
+	return DeliveryPublished, nil
+}
+
+// signedBy rejects a signed request whose signer is not bppID (a seller may
+// only publish its own catalog). Unsigned requests pass; the signature
+// middleware decides whether they are allowed at all.
+func signedBy(w http.ResponseWriter, r *http.Request, bppID string) bool {
+	sub, ok := signing.SubscriberFrom(r.Context())
+	if !ok || sub.SubscriberID == bppID {
+		return true
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusUnauthorized)
+	_ = json.NewEncoder(w).Encode(model.NACK("POLICY-ERROR", "10001", "signer "+sub.SubscriberID+" does not match bpp_id "+bppID))
+	return false
+}
+
+// registered rejects a catalog whose bpp_id is not an active BPP for its
+// domain and city, or whose bpp_uri differs from the registry.
+func (e *Edge) registered(w http.ResponseWriter, r *http.Request, c model.OnSearchContext) bool {
+	err := e.checker.Check(r.Context(), registry.TypeBPP, c.BppID, c.Domain, c.City, c.BppURI)
+	if err == nil {
+		return true
+	}
+	status, nack := registry.NACK(err)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(nack)
+	return false
+}
+
+// writeGzipJSON writes an already encoded JSON body.
+// Always respond gzip-compressed per SNP requirement.
+func writeGzipJSON(w http.ResponseWriter, body []byte) {
 	w.Header().Set("Content-Type", "application/json")
-	// Always respond gzip-compressed per SNP requirement.
 	w.Header().Set("Content-Encoding", "gzip")
 
 	gw := gzip.NewWriter(w)
 	defer gw.Close()
-	_ = json.NewEncoder(gw).Encode(stats)
+	_, _ = gw.Write(body)
+	_, _ = gw.Write([]byte("\n"))
 }
 
 
@@ -0,0 +1,132 @@
+package idempotency
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sync"
+	"time"
+
+	"gcr-backend/internal/logging"
+	"gcr-backend/internal/readmodel"
+)
+
+var (
+	client readmodel.Store
+	ttl    time.Duration
+	lease  time.Duration
+	once   sync.Once
+)
+
+const (
+	// keyPrefix namespaces idempotency keys in Redis.
+	keyPrefix = "idem:"
+	// pendingMarker is stored by Claim until the first attempt saves its result.
+	pendingMarker = "__pending__"
+	// defaultTTL is how long a message_id is remembered (override with IDEMPOTENCY_TTL).
+	defaultTTL = 24 * time.Hour
+	// defaultLease is how long a claim without a result blocks retries
+	// (override with IDEMPOTENCY_LEASE). It should cover one processing
+	// attempt; after a crash the key becomes claimable again when it expires.
+	defaultLease = 2 * time.Minute
+)
+
+// ErrInProgress is returned by Lookup when the original attempt has claimed
+// the key but not yet stored a result.
+var ErrInProgress = errors.New("idempotency: original request still in progress")
+
+// Init sets up the store used for idempotency keys.
+// It is safe to call multiple times; only the first store is used.
+func Init(store readmodel.Store) {
+	once.Do(func() {
+		client = store
+		ttl = durationEnv("IDEMPOTENCY_TTL", defaultTTL)
+		lease = durationEnv("IDEMPOTENCY_LEASE", defaultLease)
+		if lease > ttl {
+			lease = ttl
+		}
+	})
+}
+
+func durationEnv(key string, def time.Duration) time.Duration {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	if d, err := time.ParseDuration(v); err == nil && d > 0 {
+		return d
+	}
+	logging.Subsystem("idempotency").Warn("invalid "+key+", using default", "value", v, "default", def.String())
+	return def
+}
+
+// Key builds the idempotency key for an ONDC message. message_id is only
+// unique per transaction and subscriber, so all three are included.
+func Key(subscriberID, transactionID, messageID string) string {
+	return subscriberID + ":" + transactionID + ":" + messageID
+}
+
+func redisKey(scope, key string) string {
+	return keyPrefix + scope + ":" + key
+}
+
+// Claim marks key as being processed within scope (e.g. "edge", "schemagate").
+// It returns true if this caller is the first to see the key and should process it.
+// If the store is unavailable, Claim fails open and returns true.
+func Claim(ctx context.Context, scope, key string) bool {
+	if client == nil {
+		return true
+	}
+	// SETNX sets the key only if it does not exist. Exactly one concurrent
+	// caller wins, so retries from a BPP are not reprocessed. The pending
+	// marker only lives for the processing lease, so an attempt that dies
+	// before SaveResult or Release does not block retries for the full TTL.
+	ok, err := client.SetNX(ctx, redisKey(scope, key), pendingMarker, lease)
+	if err != nil {
+		logging.For(ctx, "idempotency").Warn("SETNX failed, processing anyway", "scope", scope, "error", err)
+		return true
+	}
+	return ok
+}
+
+// SaveResult stores the outcome of the first attempt so duplicates can replay it.
+func SaveResult(ctx context.Context, scope, key string, result []byte) error {
+	if client == nil {
+		return nil
+	}
+	// SETXX overwrites the claim and extends it from the lease to the full TTL.
+	ok, err := client.SetXX(ctx, redisKey(scope, key), string(result), ttl)
+	if err != nil || ok {
+		return err
+	}
+	// The lease ran out before we finished; store the result unless a retry
+	// has claimed the key in the meantime.
+	_, err = client.SetNX(ctx, redisKey(scope, key), string(result), ttl)
+	return err
+}
+
+// Lookup returns the stored result for a duplicate key. It returns
+// ErrInProgress while the original attempt has not finished yet.
+func Lookup(ctx context.Context, scope, key string) ([]byte, error) {
+	if client == nil {
+		return nil, readmodel.ErrNotFound
+	}
+	val, err := client.Get(ctx, redisKey(scope, key))
+	if err != nil {
+		return nil, err
+	}
+	if val == pendingMarker {
+		return nil, ErrInProgress
+	}
+	return []byte(val), nil
+}
+
+// Release drops a claim so that a failed attempt can be retried.
+func Release(ctx context.Context, scope, key string) {
+	if client == nil {
+		return
+	}
+	if err := client.Del(ctx, redisKey(scope, key)); err != nil {
+		logging.For(ctx, "idempotency").Warn("DEL failed", "scope", scope, "error", err)
+	}
+}
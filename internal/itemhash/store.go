@@ -0,0 +1,122 @@
+package itemhash
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sync"
+
+	"gcr-backend/internal/logging"
+	"gcr-backend/internal/model"
+	"gcr-backend/internal/readmodel"
+)
+
+var (
+	client readmodel.Store
+	once   sync.Once
+)
+
+// Status is the outcome of comparing an item with its last committed hash.
+type Status int
+
+const (
+	// New means no hash was stored for this item.
+	New Status = iota
+	// Changed means the stored hash differs (price, stock, descriptor, ...).
+	Changed
+	// Unchanged means the item is byte-identical to the last committed version.
+	Unchanged
+)
+
+// Counts aggregates item statuses for reporting.
+type Counts struct {
+	New       int64 `json:"new"`
+	Changed   int64 `json:"changed"`
+	Unchanged int64 `json:"unchanged"`
+}
+
+// Add merges other into c.
+func (c *Counts) Add(other Counts) {
+	c.New += other.New
+	c.Changed += other.Changed
+	c.Unchanged += other.Unchanged
+}
+
+// Init sets up the store used for item content hashes.
+// It is safe to call multiple times; only the first store is used.
+func Init(store readmodel.Store) {
+	once.Do(func() {
+		client = store
+	})
+}
+
+// hashKey is one Redis hash per provider: field item_id → content hash.
+func hashKey(domain, city, providerID string) string {
+	return "itemhash:" + domain + ":" + city + ":" + providerID
+}
+
+// Hash returns the canonical content hash of an item. encoding/json emits
+// struct fields in declaration order, so equal items always hash equally.
+func Hash(item model.Item) string {
+	data, _ := json.Marshal(item)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// Compare returns the status of each item against the last committed hashes.
+// It does not record anything; call Record once the items are durably written.
+// If the store is unavailable every item is reported as Changed so nothing is dropped.
+func Compare(ctx context.Context, domain, city, providerID string, items []model.Item) []Status {
+	statuses := make([]Status, len(items))
+	if len(items) == 0 {
+		return statuses
+	}
+	if client == nil {
+		for i := range statuses {
+			statuses[i] = Changed
+		}
+		return statuses
+	}
+
+	fields := make([]string, len(items))
+	for i, item := range items {
+		fields[i] = item.ID
+	}
+
+	// HMGET fetches stored hashes for the whole batch in one round trip.
+	stored, err := client.HMGet(ctx, hashKey(domain, city, providerID), fields...)
+	if err != nil {
+		logging.For(ctx, "itemhash").Warn("HMGET failed, treating items as changed", logging.FieldProviderID, providerID, "error", err)
+		for i := range statuses {
+			statuses[i] = Changed
+		}
+		return statuses
+	}
+
+	for i, item := range items {
+		prev := stored[i]
+		switch {
+		case prev == "":
+			statuses[i] = New
+		case prev == Hash(item):
+			statuses[i] = Unchanged
+		default:
+			statuses[i] = Changed
+		}
+	}
+	return statuses
+}
+
+// Record stores the current hashes of items after they were written to the curated store.
+func Record(ctx context.Context, domain, city, providerID string, items []model.Item) error {
+	if client == nil || len(items) == 0 {
+		return nil
+	}
+	values := make(map[string]string, len(items))
+	for _, item := range items {
+		values[item.ID] = Hash(item)
+	}
+	// HSET with field/value pairs upserts all hashes at once.
+	return client.HSet(ctx, hashKey(domain, city, providerID), values)
+}
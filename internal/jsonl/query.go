@@ -45,10 +45,27 @@ type Provider struct {
 	Descriptor map[string]interface{} `json:"descriptor"`
 	Categories []interface{}          `json:"categories"`
 	Items      []interface{}          `json:"items"`
+	// QualityScore and Warnings come from SchemaGate soft checks (absent on older records).
+	QualityScore *float64     `json:"quality_score,omitempty"`
+	Warnings     []interface{} `json:"warnings,omitempty"`
+}
+
+// SellerQuality is the catalog quality aggregated over a seller's providers.
+type SellerQuality struct {
+	BppID        string  `json:"bpp_id"`
+	Providers    int     `json:"providers"`
+	Warnings     int     `json:"warnings"`
+	QualityScore float64 `json:"quality_score"`
 }
 
 // GetAllProviders returns all providers from JSONL files
 func (s *QueryService) GetAllProviders(ctx context.Context, limit, offset int) ([]Provider, error) {
+	return s.ListProviders(ctx, nil, limit, offset)
+}
+
+// ListProviders returns the provider records for which match returns true
+// (all records for a nil match), skipping offset matches.
+func (s *QueryService) ListProviders(ctx context.Context, match func(*Provider) bool, limit, offset int) ([]Provider, error) {
 	// Try multiple possible paths
 	possibleDirs := []string{
 		s.dataDir,
@@ -108,6 +125,9 @@ func (s *QueryService) GetAllProviders(ctx context.Context, limit, offset int) (
 			if err := json.Unmarshal([]byte(line), &provider); err != nil {
 				continue
 			}
+			if match != nil && !match(&provider) {
+				continue
+			}
 
 			if skipped < offset {
 				skipped++
@@ -295,6 +315,48 @@ func (s *QueryService) GetStats(ctx context.Context) (map[string]interface{}, er
 	return stats, nil
 }
 
+// GetSellerQuality aggregates the latest record of every provider into a
+// per-seller quality score (mean of provider scores). Records written before
+// quality checks existed are skipped. An empty bppID returns all sellers.
+func (s *QueryService) GetSellerQuality(ctx context.Context, bppID string) ([]SellerQuality, error) {
+	files, err := filepath.Glob(filepath.Join(s.dataDir, "*.jsonl"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list files: %w", err)
+	}
+
+	bySeller := map[string]*SellerQuality{}
+	order := []string{}
+
+	for _, file := range files {
+		providerID := strings.TrimSuffix(filepath.Base(file), ".jsonl")
+		provider, err := s.GetProvider(ctx, providerID)
+		if err != nil || provider.QualityScore == nil {
+			continue
+		}
+		if bppID != "" && provider.BppID != bppID {
+			continue
+		}
+
+		agg, ok := bySeller[provider.BppID]
+		if !ok {
+			agg = &SellerQuality{BppID: provider.BppID}
+			bySeller[provider.BppID] = agg
+			order = append(order, provider.BppID)
+		}
+		agg.Providers++
+		agg.Warnings += len(provider.Warnings)
+		agg.QualityScore += *provider.QualityScore
+	}
+
+	result := []SellerQuality{}
+	for _, id := range order {
+		agg := bySeller[id]
+		agg.QualityScore = agg.QualityScore / float64(agg.Providers)
+		result = append(result, *agg)
+	}
+	return result, nil
+}
+
 // Helper function to get nested string value
 func getNestedString(m map[string]interface{}, keys ...string) string {
 	current := interface{}(m)
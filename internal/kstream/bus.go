@@ -0,0 +1,93 @@
+package kstream
+
+import (
+	"context"
+
+	"github.com/segmentio/kafka-go"
+
+	"gcr-backend/internal/bus"
+	"gcr-backend/internal/metrics"
+)
+
+// Subscriber is the Kafka bus.Subscriber: every subscription is a
+// consumer-group kafka.Reader with manual commits (see KafkaReader).
+type Subscriber struct{}
+
+// NewSubscriber creates a Subscriber for KAFKA_BROKER.
+func NewSubscriber() *Subscriber {
+	return &Subscriber{}
+}
+
+// Subscribe joins groupID on topic.
+func (s *Subscriber) Subscribe(ctx context.Context, topic, groupID string) (bus.Subscription, error) {
+	return &kafkaSubscription{topic: topic, reader: KafkaReader(topic, groupID)}, nil
+}
+
+type kafkaSubscription struct {
+	topic  string
+	reader *kafka.Reader
+}
+
+func (s *kafkaSubscription) Fetch(ctx context.Context) (bus.Message, error) {
+	// segmentio/kafka-go: FetchMessage does not commit; Commit is explicit.
+	msg, err := s.reader.FetchMessage(ctx)
+	if err != nil {
+		if ctx.Err() == nil {
+			metrics.KafkaErrors.Inc("fetch", s.topic)
+		}
+		return bus.Message{}, err
+	}
+	return fromKafka(msg), nil
+}
+
+func (s *kafkaSubscription) Commit(ctx context.Context, msg bus.Message) error {
+	// segmentio/kafka-go: only Topic, Partition and Offset are used to commit.
+	err := s.reader.CommitMessages(ctx, kafka.Message{Topic: msg.Topic, Partition: msg.Partition, Offset: msg.Offset})
+	if err != nil {
+		metrics.KafkaErrors.Inc("commit", s.topic)
+	}
+	return err
+}
+
+// Lag implements bus.LagReporter.
+func (s *kafkaSubscription) Lag() int64 {
+	// segmentio/kafka-go: Stats().Lag is the group's lag as of the last fetch.
+	return s.reader.Stats().Lag
+}
+
+func (s *kafkaSubscription) Close() error {
+	return s.reader.Close()
+}
+
+func toKafka(msgs []bus.Message) []kafka.Message {
+	out := make([]kafka.Message, len(msgs))
+	for i, m := range msgs {
+		headers := make([]kafka.Header, len(m.Headers))
+		for j, h := range m.Headers {
+			headers[j] = kafka.Header{Key: h.Key, Value: h.Value}
+		}
+		out[i] = kafka.Message{Key: m.Key, Value: m.Value, Headers: headers, Time: m.Time}
+	}
+	return out
+}
+
+func fromKafka(m kafka.Message) bus.Message {
+	headers := make([]bus.Header, len(m.Headers))
+	for i, h := range m.Headers {
+		headers[i] = bus.Header{Key: h.Key, Value: h.Value}
+	}
+	return bus.Message{
+		Topic:     m.Topic,
+		Partition: m.Partition,
+		Offset:    m.Offset,
+		Key:       m.Key,
+		Value:     m.Value,
+		Headers:   headers,
+		Time:      m.Time,
+	}
+}
+
+var (
+	_ bus.Publisher  = (*Producer)(nil)
+	_ bus.Subscriber = (*Subscriber)(nil)
+)
@@ -2,20 +2,28 @@ package kstream
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
-	"log"
-	"time"
+	"errors"
+	"fmt"
 
 	"github.com/segmentio/kafka-go"
 
+	"gcr-backend/internal/bus"
 	"gcr-backend/internal/curated"
+	"gcr-backend/internal/idempotency"
+	"gcr-backend/internal/logging"
 	"gcr-backend/internal/model"
+	"gcr-backend/internal/readmodel"
 	"gcr-backend/internal/rejections"
 	"gcr-backend/internal/schemagate"
+	"gcr-backend/internal/tracing"
 )
 
 // KafkaReader creates a Kafka consumer using segmentio/kafka-go library.
-// kafka.Reader provides consumer group functionality with automatic offset management.
+// kafka.Reader provides consumer group functionality; offsets are committed
+// explicitly (CommitMessages) and synchronously once processing succeeded.
 func KafkaReader(topic, groupID string) *kafka.Reader {
 	broker := getenv("KAFKA_BROKER", "kafka:9092")
 	return kafka.NewReader(kafka.ReaderConfig{
@@ -24,82 +32,137 @@ func KafkaReader(topic, groupID string) *kafka.Reader {
 		GroupID:        groupID,                   // segmentio/kafka-go: Consumer group ID (enables load balancing)
 		MinBytes:       10e3,                      // segmentio/kafka-go: Min bytes to fetch per request (10KB)
 		MaxBytes:       104857600,                 // segmentio/kafka-go: Max bytes per message (100MB) to handle large payloads
-		CommitInterval: time.Second,               // segmentio/kafka-go: Auto-commit interval for offsets
+		CommitInterval: 0,                         // segmentio/kafka-go: 0 = CommitMessages commits synchronously
 	})
 }
 
-// kafkaReader is an alias for internal use.
-func kafkaReader(topic, groupID string) *kafka.Reader {
-	return KafkaReader(topic, groupID)
-}
-
 // ConsumeIngestTopic runs SchemaGate consumer that reads from catalog.ingest,
 // validates providers, writes rejects, and forwards valid rows to Curated Writer.
-func ConsumeIngestTopic(ctx context.Context) error {
-	reader := kafkaReader("catalog.ingest", "schemagate-group")
-	defer reader.Close()
-
-	log.Println("SchemaGate: consuming from catalog.ingest")
+//
+// Providers arrive as separate messages keyed by bpp_id:provider_id, so they
+// are spread across partitions and consumer group members. Within a member,
+// INGEST_WORKERS partitions are processed in parallel, each one in order, so a
+// provider's messages are never reordered, and a retried message is dropped
+// once a newer one for the same provider has been applied. Offsets are committed only after
+// the Curated Writer and CatalogAccepted publish succeeded; failures go to
+// catalog.ingest.retry and finally catalog.ingest.dlq.
+func ConsumeIngestTopic(ctx context.Context, sub bus.Subscriber, pub bus.Publisher, store readmodel.Store) error {
+	return Stage{
+		Name:       "SchemaGate",
+		Topic:      TopicCatalogIngest,
+		GroupID:    "schemagate-group",
+		Workers:    ingestWorkerCount(),
+		Subscriber: sub,
+		Publisher:  pub,
+		Order:      store,
+		Handler: func(ctx context.Context, msg bus.Message) error {
+			return processIngestMessage(ctx, pub, msg)
+		},
+	}.Run(ctx)
+}
 
-	for {
-		// segmentio/kafka-go: ReadMessage blocks until a message is available from Kafka topic.
-		// Automatically handles consumer group coordination and offset commits.
-		msg, err := reader.ReadMessage(ctx)
-		if err != nil {
-			return err
-		}
+func ingestWorkerCount() int {
+	return getenvInt("INGEST_WORKERS", 4)
+}
 
-		var env model.OnSearchEnvelope
-		if err := json.Unmarshal(msg.Value, &env); err != nil {
-			log.Printf("SchemaGate: failed to unmarshal: %v", err)
-			continue
-		}
+// processIngestMessage runs SchemaGate and the Curated Writer for one
+// catalog.ingest message (a single provider, or a legacy full envelope).
+// A returned error sends the message to the retry topic.
+func processIngestMessage(ctx context.Context, pub bus.Publisher, msg bus.Message) error {
+	logger := logging.For(ctx, "schemagate")
+	if messageType(msg) == MessageTypeEnvelope {
+		// Envelope header: nothing to validate, providers follow as their own messages.
+		logger.Debug("on_search header", "key", string(msg.Key), "providers", msg.Header(HeaderProviderCount))
+		return nil
+	}
 
-		// Step-2: Provider/Item validation with partial acceptance
-		validProviders, rejectionsList := schemagate.ProcessCatalog(ctx, &env)
+	var env model.OnSearchEnvelope
+	if err := json.Unmarshal(msg.Value, &env); err != nil {
+		return Permanent(fmt.Errorf("SchemaGate: failed to unmarshal: %w", err))
+	}
 
-		// Write rejections to durable store
-		envMeta := map[string]string{
-			"transaction_id": env.Context.TransactionID,
-			"message_id":     env.Context.MessageID,
+	// Correlate everything below (and the CatalogAccepted events) with the
+	// on_search; a legacy full envelope carries no single provider.
+	providerID := ""
+	if providers := env.Message.Catalog.BPPProviders; len(providers) == 1 {
+		providerID = providers[0].ID
+	}
+	ctx = logging.With(ctx,
+		logging.FieldTransactionID, env.Context.TransactionID,
+		logging.FieldMessageID, env.Context.MessageID,
+		logging.FieldBppID, env.Context.BppID,
+		logging.FieldProviderID, providerID)
+	logger = logging.For(ctx, "schemagate")
+
+	// Idempotency: skip messages already processed by SchemaGate (edge retries,
+	// duplicate publishes, or redelivery after a rebalance). One on_search is
+	// split into per-provider messages, so the payload digest is part of the key.
+	digest := sha256.Sum256(msg.Value)
+	idemKey := idempotency.Key(env.Context.BppID, env.Context.TransactionID, env.Context.MessageID) + ":" + hex.EncodeToString(digest[:8])
+	if !idempotency.Claim(ctx, "schemagate", idemKey) {
+		_, err := idempotency.Lookup(ctx, "schemagate", idemKey)
+		if err == nil {
+			logger.Info("duplicate message, skipping", "idempotency_key", idemKey)
+			return nil
 		}
-		for _, rej := range rejectionsList {
-			_ = rejections.WriteRejection(ctx, envMeta, rej)
+		// No saved result: another attempt is still running, or crashed
+		// holding the claim. Only skip once a result exists; until then the
+		// message goes to the retry topic instead of being committed.
+		// (The claim may also have expired right after Claim: take it over.)
+		if !errors.Is(err, readmodel.ErrNotFound) || !idempotency.Claim(ctx, "schemagate", idemKey) {
+			return fmt.Errorf("SchemaGate: message not yet processed by the claiming attempt: %w", err)
 		}
+	}
 
-		// Forward valid providers to Curated Writer
-		if len(validProviders) > 0 {
-			events, err := curated.WriteValidProviders(ctx, &env, validProviders)
-			if err != nil {
-				log.Printf("Curated Writer: error: %v", err)
-				continue
-			}
-
-			// Publish CatalogAccepted events
-			for _, evt := range events {
-				if err := PublishCatalogAccepted(ctx, evt); err != nil {
-					log.Printf("Failed to publish CatalogAccepted: %v", err)
-				}
-			}
+	// Step-2: Provider/Item validation with partial acceptance
+	// Accepted providers may carry quality warnings (soft acceptance)
+	vctx, span := tracing.Start(ctx, "SchemaGate.validate", tracing.KindInternal)
+	validProviders, rejectionsList, quality, changes := schemagate.ProcessCatalog(vctx, &env)
+	itemChanges := changes.Total()
+	span.SetAttr("bpp_id", env.Context.BppID)
+	span.SetAttr("providers.accepted", len(validProviders))
+	span.SetAttr("rejections", len(rejectionsList))
+	span.End()
+	logger.Info("provider validated",
+		"providers_accepted", len(validProviders), "rejections", len(rejectionsList),
+		"items_new", itemChanges.New, "items_changed", itemChanges.Changed, "items_unchanged", itemChanges.Unchanged)
+
+	// Write rejections to durable store
+	envMeta := map[string]string{
+		"transaction_id": env.Context.TransactionID,
+		"message_id":     env.Context.MessageID,
+	}
+	for _, rej := range rejectionsList {
+		if err := rejections.WriteRejection(ctx, envMeta, rej); err != nil {
+			idempotency.Release(ctx, "schemagate", idemKey)
+			return fmt.Errorf("rejections store: %w", err)
 		}
 	}
-}
 
-// PublishCatalogAccepted publishes a CatalogAccepted event to topic.catalog.accepted.
-func PublishCatalogAccepted(ctx context.Context, evt model.CatalogAccepted) error {
-	w := kafkaWriter("catalog.accepted")
-	defer w.Close()
+	// Forward valid providers to Curated Writer
+	if len(validProviders) > 0 {
+		events, err := curated.WriteValidProviders(ctx, &env, validProviders, quality, changes)
+		if err != nil {
+			idempotency.Release(ctx, "schemagate", idemKey)
+			return fmt.Errorf("Curated Writer: %w", err)
+		}
 
-	data, err := json.Marshal(evt)
-	if err != nil {
-		return err
+		// Publish CatalogAccepted events
+		for _, evt := range events {
+			if err := PublishCatalogAccepted(ctx, pub, evt); err != nil {
+				idempotency.Release(ctx, "schemagate", idemKey)
+				return fmt.Errorf("publish CatalogAccepted: %w", err)
+			}
+		}
 	}
 
-	msg := kafka.Message{
-		Key:   []byte(evt.SellerID + ":" + evt.City + ":" + evt.Category),
-		Value: data,
-		Time:  time.Now(),
+	result, _ := json.Marshal(map[string]any{
+		"accepted_providers": len(validProviders),
+		"rejections":         len(rejectionsList),
+		"items":              itemChanges,
+	})
+	if err := idempotency.SaveResult(ctx, "schemagate", idemKey, result); err != nil {
+		logger.Warn("failed to save idempotency result", "error", err)
 	}
-	return w.WriteMessages(ctx, msg)
+	return nil
 }
-
@@ -0,0 +1,474 @@
+package kstream
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gcr-backend/internal/bus"
+	"gcr-backend/internal/logging"
+	"gcr-backend/internal/metrics"
+	"gcr-backend/internal/readmodel"
+	"gcr-backend/internal/tracing"
+)
+
+// Kafka message headers added when a message fails and is moved to the
+// retry or dead-letter topic of its stage.
+const (
+	HeaderAttempt           = "gcr-attempt"
+	HeaderError             = "gcr-error"
+	HeaderOriginalTopic     = "gcr-original-topic"
+	HeaderOriginalPartition = "gcr-original-partition"
+	HeaderOriginalOffset    = "gcr-original-offset"
+	HeaderConsumerGroup     = "gcr-consumer-group"
+	HeaderFailedAt          = "gcr-failed-at"
+)
+
+// failureHeaders are stripped before a message is retried or replayed.
+var failureHeaders = map[string]bool{
+	HeaderAttempt:           true,
+	HeaderError:             true,
+	HeaderOriginalTopic:     true,
+	HeaderOriginalPartition: true,
+	HeaderOriginalOffset:    true,
+	HeaderConsumerGroup:     true,
+	HeaderFailedAt:          true,
+}
+
+// Handler processes one bus message. A non-nil error sends the message to
+// the stage's retry topic, or straight to its DLQ if the error is Permanent.
+type Handler func(ctx context.Context, msg bus.Message) error
+
+type permanentError struct{ err error }
+
+func (e permanentError) Error() string { return e.err.Error() }
+func (e permanentError) Unwrap() error { return e.err }
+
+// Permanent marks an error as not worth retrying (e.g. undecodable payload).
+func Permanent(err error) error {
+	return permanentError{err: err}
+}
+
+// IsPermanent reports whether err was marked with Permanent.
+func IsPermanent(err error) bool {
+	var p permanentError
+	return errors.As(err, &p)
+}
+
+// Stage is a consumer with manual offset commits: an offset is committed only
+// after Handler succeeded, or after the failed message was durably handed to
+// Topic+".retry" / Topic+".dlq". A crash mid-processing therefore redelivers
+// the message instead of losing it; handlers must be idempotent.
+type Stage struct {
+	Name    string // log prefix, e.g. "SchemaGate"
+	Topic   string
+	GroupID string
+	// Workers is the number of partitions processed concurrently per member.
+	// Messages of one partition always go to the same worker, in order.
+	Workers int
+	// Subscriber consumes the stage topic and its retry topic.
+	Subscriber bus.Subscriber
+	// Publisher publishes failed messages to the retry and DLQ topics.
+	Publisher bus.Publisher
+	Handler   Handler
+	// Order, if set, remembers the offset of the last message applied per
+	// key (e.g. per provider). A retried message is dropped as stale when a
+	// newer message with the same key was applied from the main topic while
+	// it waited, so an old version never overwrites a newer one.
+	Order readmodel.Store
+}
+
+// appliedTTL bounds how long the last applied offset of a key is kept; it
+// must outlast the retry backoffs of a message.
+const appliedTTL = 7 * 24 * time.Hour
+
+// RetryTopic is where failed messages wait for another attempt.
+func (s Stage) RetryTopic() string { return s.Topic + ".retry" }
+
+// DLQTopic is where messages land after the last failed attempt.
+func (s Stage) DLQTopic() string { return s.Topic + ".dlq" }
+
+// Run consumes the stage topic and its retry topic until ctx is cancelled or
+// either consumer fails.
+func (s Stage) Run(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	errc := make(chan error, 2)
+	go func() { errc <- s.consumeMain(ctx) }()
+	go func() { errc <- s.consumeRetry(ctx) }()
+
+	err := <-errc
+	cancel()
+	<-errc
+	return err
+}
+
+func (s Stage) consumeMain(ctx context.Context) error {
+	sub, err := s.Subscriber.Subscribe(ctx, s.Topic, s.GroupID)
+	if err != nil {
+		return err
+	}
+	defer sub.Close()
+
+	s.logger(ctx).Info("consuming", "topic", s.Topic, "group", s.GroupID)
+	lagCtx, stopLag := context.WithCancel(ctx)
+	defer stopLag()
+	go s.reportLag(lagCtx, sub, s.Topic, s.GroupID)
+
+	workers := s.Workers
+	if workers <= 0 {
+		workers = 1
+	}
+	queues := make([]chan bus.Message, workers)
+	var wg sync.WaitGroup
+	for i := range queues {
+		queues[i] = make(chan bus.Message, 16)
+		wg.Add(1)
+		go func(queue <-chan bus.Message) {
+			defer wg.Done()
+			for msg := range queue {
+				if s.handle(ctx, msg, 1) {
+					s.markApplied(ctx, msg)
+				}
+				s.commit(ctx, sub, msg)
+			}
+		}(queues[i])
+	}
+	defer func() {
+		for _, queue := range queues {
+			close(queue)
+		}
+		wg.Wait()
+	}()
+
+	for {
+		// Fetch does not commit; the worker commits once the message is fully processed.
+		msg, err := sub.Fetch(ctx)
+		if err != nil {
+			return err
+		}
+
+		queue := queues[msg.Partition%workers]
+		select {
+		case queue <- msg:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func (s Stage) consumeRetry(ctx context.Context) error {
+	sub, err := s.Subscriber.Subscribe(ctx, s.RetryTopic(), s.GroupID+"-retry")
+	if err != nil {
+		return err
+	}
+	defer sub.Close()
+
+	s.logger(ctx).Info("consuming", "topic", s.RetryTopic(), "group", s.GroupID+"-retry")
+	lagCtx, stopLag := context.WithCancel(ctx)
+	defer stopLag()
+	go s.reportLag(lagCtx, sub, s.RetryTopic(), s.GroupID+"-retry")
+
+	for {
+		msg, err := sub.Fetch(ctx)
+		if err != nil {
+			return err
+		}
+
+		// Wait out the backoff for this attempt; the retry topic is FIFO, so
+		// later messages are never due earlier than this one.
+		attempt := attemptOf(msg)
+		due := msg.Time.Add(retryBackoff(attempt))
+		if wait := time.Until(due); wait > 0 {
+			select {
+			case <-time.After(wait):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+
+		if s.stale(ctx, msg) {
+			s.logger(ctx).Info("dropping stale retry, a newer message for the key was applied",
+				"key", string(msg.Key), "original_offset", msg.Header(HeaderOriginalOffset))
+			metrics.ConsumerMessages.Inc(msg.Topic, s.GroupID, "stale")
+			s.commit(ctx, sub, msg)
+			continue
+		}
+		if s.handle(ctx, msg, attempt+1) {
+			s.markApplied(ctx, msg)
+		}
+		s.commit(ctx, sub, msg)
+	}
+}
+
+// position returns where msg was first written on the stage topic: its own
+// partition and offset, or the original ones carried by a retry.
+func position(msg bus.Message) (partition string, offset int64) {
+	if v := msg.Header(HeaderOriginalPartition); v != "" {
+		offset, _ = strconv.ParseInt(msg.Header(HeaderOriginalOffset), 10, 64)
+		return v, offset
+	}
+	return strconv.Itoa(msg.Partition), msg.Offset
+}
+
+func (s Stage) appliedKey(partition string, key []byte) string {
+	return "applied:" + s.GroupID + ":" + partition + ":" + string(key)
+}
+
+// markApplied records msg as the latest applied message of its key.
+// Messages of one key share a partition and are applied in offset order,
+// except retries, which never move the mark backwards.
+func (s Stage) markApplied(ctx context.Context, msg bus.Message) {
+	if s.Order == nil || len(msg.Key) == 0 {
+		return
+	}
+	partition, offset := position(msg)
+	key := s.appliedKey(partition, msg.Key)
+	if msg.Header(HeaderOriginalOffset) != "" {
+		if last, err := s.Order.Get(ctx, key); err == nil {
+			if n, err := strconv.ParseInt(last, 10, 64); err == nil && n > offset {
+				return
+			}
+		}
+	}
+	if err := s.Order.Set(ctx, key, strconv.FormatInt(offset, 10), appliedTTL); err != nil {
+		s.logger(ctx).Warn("failed to record applied offset", "key", string(msg.Key), "error", err)
+	}
+}
+
+// stale reports whether a newer message with msg's key has been applied.
+// If the store is unavailable the message is processed (fail open).
+func (s Stage) stale(ctx context.Context, msg bus.Message) bool {
+	if s.Order == nil || len(msg.Key) == 0 {
+		return false
+	}
+	partition, offset := position(msg)
+	last, err := s.Order.Get(ctx, s.appliedKey(partition, msg.Key))
+	if err != nil {
+		return false
+	}
+	n, err := strconv.ParseInt(last, 10, 64)
+	return err == nil && n > offset
+}
+
+// handle runs Handler and hands a failed msg on as attempt nextAttempt. It
+// reports whether the handler succeeded.
+func (s Stage) handle(ctx context.Context, msg bus.Message, nextAttempt int) bool {
+	// Continue the producer's trace from the W3C headers, and its log
+	// correlation fields (transaction_id, message_id, bpp_id, ...).
+	ctx = tracing.Extract(ctx, msg.Header(tracing.HeaderTraceParent), msg.Header(tracing.HeaderTraceState))
+	ctx = withLogFields(ctx, msg)
+	ctx, span := tracing.Start(ctx, s.Name+" process", tracing.KindConsumer)
+	span.SetAttr("messaging.destination", msg.Topic)
+	span.SetAttr("messaging.consumer_group", s.GroupID)
+	span.SetAttr("messaging.partition", msg.Partition)
+	span.SetAttr("messaging.offset", msg.Offset)
+	err := s.Handler(ctx, msg)
+	span.RecordError(err)
+	span.End()
+	if err == nil {
+		metrics.ConsumerMessages.Inc(msg.Topic, s.GroupID, "ok")
+		return true
+	}
+	topic := s.fail(ctx, msg, nextAttempt, err)
+	result := "retry"
+	if topic == s.DLQTopic() {
+		result = "dlq"
+	}
+	metrics.ConsumerMessages.Inc(msg.Topic, s.GroupID, result)
+	return false
+}
+
+// reportLag exports the subscription's lag as gcr_consumer_lag until ctx is done.
+func (s Stage) reportLag(ctx context.Context, sub bus.Subscription, topic, group string) {
+	lr, ok := sub.(bus.LagReporter)
+	if !ok {
+		return
+	}
+	ticker := time.NewTicker(10 * time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			metrics.ConsumerLag.Set(float64(lr.Lag()), topic, group)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// fail moves msg to the retry topic, or to the DLQ once attempts are
+// exhausted, and returns the topic it went to. It blocks until the hand-off
+// succeeds so the caller can commit.
+func (s Stage) fail(ctx context.Context, msg bus.Message, attempt int, cause error) string {
+	topic := s.RetryTopic()
+	if IsPermanent(cause) || attempt > maxAttempts() {
+		topic = s.DLQTopic()
+	}
+	s.logger(ctx).Warn("message failed", "key", string(msg.Key), "attempt", attempt, "to", topic, "error", cause)
+
+	out := bus.Message{
+		Key:     msg.Key,
+		Value:   msg.Value,
+		Time:    time.Now(),
+		Headers: withoutFailureHeaders(msg.Headers),
+	}
+	origTopic, origPartition, origOffset := msg.Topic, strconv.Itoa(msg.Partition), strconv.FormatInt(msg.Offset, 10)
+	if v := msg.Header(HeaderOriginalTopic); v != "" {
+		// Already a retry: keep pointing at the first failure.
+		origTopic = v
+		origPartition = msg.Header(HeaderOriginalPartition)
+		origOffset = msg.Header(HeaderOriginalOffset)
+	}
+	out.Headers = append(out.Headers,
+		bus.Header{Key: HeaderAttempt, Value: []byte(strconv.Itoa(attempt))},
+		bus.Header{Key: HeaderError, Value: []byte(cause.Error())},
+		bus.Header{Key: HeaderOriginalTopic, Value: []byte(origTopic)},
+		bus.Header{Key: HeaderOriginalPartition, Value: []byte(origPartition)},
+		bus.Header{Key: HeaderOriginalOffset, Value: []byte(origOffset)},
+		bus.Header{Key: HeaderConsumerGroup, Value: []byte(s.GroupID)},
+		bus.Header{Key: HeaderFailedAt, Value: []byte(time.Now().UTC().Format(time.RFC3339Nano))},
+	)
+
+	for {
+		err := s.Publisher.PublishSync(ctx, topic, out)
+		if err == nil {
+			return topic
+		}
+		s.logger(ctx).Error("failed to publish, retrying", "topic", topic, "error", err)
+		select {
+		case <-time.After(time.Second):
+		case <-ctx.Done():
+			return topic
+		}
+	}
+}
+
+func (s Stage) commit(ctx context.Context, sub bus.Subscription, msg bus.Message) {
+	if ctx.Err() != nil {
+		// Shutting down: leave the offset uncommitted so the message is redelivered.
+		return
+	}
+	if err := sub.Commit(ctx, msg); err != nil {
+		s.logger(ctx).Error("commit failed", "topic", msg.Topic, "partition", msg.Partition, "offset", msg.Offset, "error", err)
+	}
+}
+
+// logger returns the stage's logger; the subsystem is the lower-cased stage
+// name (schemagate, projectors), so LOG_LEVELS can target each stage.
+func (s Stage) logger(ctx context.Context) *slog.Logger {
+	return logging.For(ctx, strings.ToLower(s.Name))
+}
+
+// withLogFields returns ctx with the correlation fields carried in msg's
+// gcr-log-* headers.
+func withLogFields(ctx context.Context, msg bus.Message) context.Context {
+	var kv []string
+	for _, h := range msg.Headers {
+		if name, ok := strings.CutPrefix(h.Key, logging.HeaderPrefix); ok {
+			kv = append(kv, name, string(h.Value))
+		}
+	}
+	if len(kv) == 0 {
+		return ctx
+	}
+	return logging.With(ctx, kv...)
+}
+
+func withoutFailureHeaders(headers []bus.Header) []bus.Header {
+	out := make([]bus.Header, 0, len(headers))
+	for _, h := range headers {
+		if !failureHeaders[h.Key] {
+			out = append(out, h)
+		}
+	}
+	return out
+}
+
+func attemptOf(msg bus.Message) int {
+	if n, err := strconv.Atoi(msg.Header(HeaderAttempt)); err == nil && n > 0 {
+		return n
+	}
+	return 1
+}
+
+// maxAttempts is the number of failed attempts before a message goes to the DLQ.
+func maxAttempts() int {
+	if n, err := strconv.Atoi(getenv("KAFKA_MAX_ATTEMPTS", "5")); err == nil && n > 0 {
+		return n
+	}
+	return 5
+}
+
+// retryBackoff doubles KAFKA_RETRY_BACKOFF per attempt, capped at 5 minutes.
+func retryBackoff(attempt int) time.Duration {
+	base, err := time.ParseDuration(getenv("KAFKA_RETRY_BACKOFF", "5s"))
+	if err != nil || base <= 0 {
+		base = 5 * time.Second
+	}
+	d := base
+	for i := 1; i < attempt && d < 5*time.Minute; i++ {
+		d *= 2
+	}
+	if d > 5*time.Minute {
+		d = 5 * time.Minute
+	}
+	return d
+}
+
+// ReplayDLQ re-publishes up to limit messages from a dead-letter topic to the
+// topic they originally failed on, with failure headers stripped. It stops
+// when no message arrives for idle. With dryRun, messages are only logged and
+// offsets are not committed.
+func ReplayDLQ(ctx context.Context, sub bus.Subscriber, pub bus.Publisher, dlqTopic string, limit int, idle time.Duration, dryRun bool) (int, error) {
+	reader, err := sub.Subscribe(ctx, dlqTopic, "dlq-replay")
+	if err != nil {
+		return 0, err
+	}
+	defer reader.Close()
+
+	replayed := 0
+	for limit <= 0 || replayed < limit {
+		fetchCtx, cancel := context.WithTimeout(ctx, idle)
+		msg, err := reader.Fetch(fetchCtx)
+		cancel()
+		if err != nil {
+			if errors.Is(err, context.DeadlineExceeded) && ctx.Err() == nil {
+				return replayed, nil // drained
+			}
+			return replayed, err
+		}
+
+		target := msg.Header(HeaderOriginalTopic)
+		if target == "" {
+			target = strings.TrimSuffix(dlqTopic, ".dlq")
+		}
+		logging.For(withLogFields(ctx, msg), "replay").Info("replay message",
+			"from", dlqTopic, "offset", msg.Offset, "key", string(msg.Key), "to", target, "error", msg.Header(HeaderError), "dry_run", dryRun)
+		if dryRun {
+			replayed++
+			continue
+		}
+
+		out := bus.Message{
+			Key:     msg.Key,
+			Value:   msg.Value,
+			Time:    time.Now(),
+			Headers: withoutFailureHeaders(msg.Headers),
+		}
+		if err := pub.PublishSync(ctx, target, out); err != nil {
+			return replayed, fmt.Errorf("replay to %s: %w", target, err)
+		}
+		if err := reader.Commit(ctx, msg); err != nil {
+			return replayed, fmt.Errorf("commit %s@%d: %w", dlqTopic, msg.Offset, err)
+		}
+		replayed++
+	}
+	return replayed, nil
+}
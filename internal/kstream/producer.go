@@ -3,26 +3,195 @@ package kstream
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"net/http"
 	"os"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/segmentio/kafka-go"
 
+	"gcr-backend/internal/bus"
+	"gcr-backend/internal/events"
+	"gcr-backend/internal/logging"
+	"gcr-backend/internal/metrics"
 	"gcr-backend/internal/model"
+	"gcr-backend/internal/tracing"
 )
 
-// kafkaWriter constructs a Kafka producer using segmentio/kafka-go library.
-// kafka.Writer provides async message publishing with automatic batching and retries.
-func kafkaWriter(topic string) *kafka.Writer {
-	broker := getenv("KAFKA_BROKER", "kafka:9092")
-	return &kafka.Writer{
-		Addr:         kafka.TCP(broker),           // segmentio/kafka-go: TCP address for Kafka broker
-		Topic:        topic,                        // Target Kafka topic name
-		Balancer:     &kafka.LeastBytes{},         // segmentio/kafka-go: Partition selection strategy
-		RequiredAcks: kafka.RequireOne,            // segmentio/kafka-go: Wait for leader ack only
-		Async:        true,                        // segmentio/kafka-go: Non-blocking writes
-		BatchBytes:   104857600,                   // segmentio/kafka-go: Max batch size (100MB) to handle large messages
+// DeliveryMode selects how Producer.Publish waits for the broker.
+type DeliveryMode string
+
+const (
+	// DeliverySync blocks until the broker acked the batch and returns its error.
+	DeliverySync DeliveryMode = "sync"
+	// DeliveryAsync returns immediately; the outcome is reported to the
+	// completion callback and the delivery stats.
+	DeliveryAsync DeliveryMode = "async"
+)
+
+// DeliveryCallback is called once per batch in async mode (err is nil on success).
+type DeliveryCallback func(topic string, msgs []bus.Message, err error)
+
+// TopicStats counts deliveries per topic.
+type TopicStats struct {
+	Delivered int64  `json:"delivered"`
+	Failed    int64  `json:"failed"`
+	LastError string `json:"last_error,omitempty"`
+}
+
+// Producer owns one long-lived kafka.Writer per topic (and delivery mode),
+// so connections and batches are reused across publishes. It is safe for
+// concurrent use; construct it once in main and inject it.
+type Producer struct {
+	broker     string
+	mode       DeliveryMode
+	onDelivery DeliveryCallback
+
+	mu      sync.Mutex
+	writers map[string]*kafka.Writer
+	stats   map[string]*TopicStats
+}
+
+// NewProducer creates a Producer for KAFKA_BROKER. KAFKA_PRODUCER_MODE
+// selects "sync" (default) or "async" delivery for Publish.
+func NewProducer() *Producer {
+	mode := DeliveryMode(getenv("KAFKA_PRODUCER_MODE", string(DeliverySync)))
+	if mode != DeliveryAsync {
+		mode = DeliverySync
+	}
+	return &Producer{
+		broker:  getenv("KAFKA_BROKER", "kafka:9092"),
+		mode:    mode,
+		writers: map[string]*kafka.Writer{},
+		stats:   map[string]*TopicStats{},
+	}
+}
+
+// OnDelivery registers a completion callback for async deliveries.
+// It must be called before the first publish.
+func (p *Producer) OnDelivery(cb DeliveryCallback) {
+	p.onDelivery = cb
+}
+
+// writer returns the pooled writer for topic, creating it on first use.
+func (p *Producer) writer(topic string, async bool) *kafka.Writer {
+	key := topic
+	if async {
+		key += "#async"
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if w, ok := p.writers[key]; ok {
+		return w
+	}
+
+	// segmentio/kafka-go: kafka.Writer batches and retries internally; one per topic is reused.
+	w := &kafka.Writer{
+		Addr:         kafka.TCP(p.broker),   // segmentio/kafka-go: TCP address for Kafka broker
+		Topic:        topic,                 // Target Kafka topic name
+		Balancer:     &kafka.Hash{},         // segmentio/kafka-go: partition = hash(key), round-robin without key
+		RequiredAcks: kafka.RequireOne,      // segmentio/kafka-go: Wait for leader ack only
+		Async:        async,                 // segmentio/kafka-go: Non-blocking writes report via Completion
+		BatchBytes:   104857600,             // segmentio/kafka-go: Max batch size (100MB) to handle large messages
+		BatchTimeout: 10 * time.Millisecond, // segmentio/kafka-go: Flush small batches quickly
+	}
+	if async {
+		w.Completion = func(msgs []kafka.Message, err error) {
+			p.record(topic, len(msgs), err)
+			if p.onDelivery != nil {
+				out := make([]bus.Message, len(msgs))
+				for i, m := range msgs {
+					out[i] = fromKafka(m)
+				}
+				p.onDelivery(topic, out, err)
+			}
+		}
+	}
+	p.writers[key] = w
+	return w
+}
+
+func (p *Producer) record(topic string, n int, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	st, ok := p.stats[topic]
+	if !ok {
+		st = &TopicStats{}
+		p.stats[topic] = st
+	}
+	if err != nil {
+		metrics.KafkaErrors.Inc("produce", topic)
+		st.Failed += int64(n)
+		st.LastError = err.Error()
+		return
 	}
+	st.Delivered += int64(n)
+}
+
+// Publish writes msgs to topic using the producer's delivery mode.
+func (p *Producer) Publish(ctx context.Context, topic string, msgs ...bus.Message) error {
+	if p.mode == DeliveryAsync {
+		// segmentio/kafka-go: async WriteMessages only fails on invalid input;
+		// delivery errors arrive in Completion.
+		return p.writer(topic, true).WriteMessages(ctx, toKafka(msgs)...)
+	}
+	return p.PublishSync(ctx, topic, msgs...)
+}
+
+// PublishSync writes msgs to topic and waits for the broker ack regardless of
+// the delivery mode. Used where the caller commits offsets afterwards.
+func (p *Producer) PublishSync(ctx context.Context, topic string, msgs ...bus.Message) error {
+	err := p.writer(topic, false).WriteMessages(ctx, toKafka(msgs)...)
+	p.record(topic, len(msgs), err)
+	if err != nil && p.onDelivery != nil {
+		p.onDelivery(topic, msgs, err)
+	}
+	return err
+}
+
+// Stats returns a snapshot of delivery counts per topic.
+func (p *Producer) Stats() map[string]TopicStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	out := make(map[string]TopicStats, len(p.stats))
+	for topic, st := range p.stats {
+		out[topic] = *st
+	}
+	return out
+}
+
+// Close flushes pending async batches and closes every pooled writer.
+// The writers are closed without holding p.mu: closing waits for in-flight
+// batches, whose Completion records stats under p.mu.
+func (p *Producer) Close() error {
+	p.mu.Lock()
+	writers := p.writers
+	p.writers = map[string]*kafka.Writer{}
+	p.mu.Unlock()
+
+	var errs []error
+	for _, w := range writers {
+		if err := w.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// StatsHandler handles GET /admin/kafka/producer
+func (p *Producer) StatsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data": map[string]interface{}{
+			"mode":   p.mode,
+			"topics": p.Stats(),
+		},
+	})
 }
 
 func getenv(key, def string) string {
@@ -32,43 +201,185 @@ func getenv(key, def string) string {
 	return def
 }
 
-// PublishOnSearchIngest sends the raw on_search envelope to the ingest topic so
-// downstream consumers (SchemaGate, Curated Writer) can process it.
-func PublishOnSearchIngest(ctx context.Context, env *model.OnSearchEnvelope) error {
-	w := kafkaWriter("catalog.ingest")
-	defer w.Close()
+// Kafka message headers set on catalog.ingest so consumers can tell the
+// envelope header apart from the per-provider messages of one on_search.
+const (
+	HeaderMessageType   = "gcr-message-type"
+	HeaderProviderIndex = "gcr-provider-index"
+	HeaderProviderCount = "gcr-provider-count"
+
+	MessageTypeEnvelope = "envelope" // context + catalog descriptor, no providers
+	MessageTypeProvider = "provider" // envelope carrying exactly one provider
+)
 
-	data, err := json.Marshal(env)
+// Kafka message headers set on versioned events (see internal/events).
+const (
+	HeaderEventType    = "gcr-event-type"
+	HeaderEventVersion = "gcr-event-version"
+)
+
+// InjectContext sets the W3C traceparent/tracestate headers of the current
+// span and the log correlation fields (gcr-log-transaction_id, ...) of ctx on
+// msgs, so consumers continue both the trace and the correlated logger.
+func InjectContext(ctx context.Context, msgs []bus.Message) {
+	var extra []bus.Header
+	if traceparent, tracestate := tracing.Inject(ctx); traceparent != "" {
+		extra = append(extra, bus.Header{Key: tracing.HeaderTraceParent, Value: []byte(traceparent)})
+		if tracestate != "" {
+			extra = append(extra, bus.Header{Key: tracing.HeaderTraceState, Value: []byte(tracestate)})
+		}
+	}
+	for _, f := range logging.Fields(ctx) {
+		extra = append(extra, bus.Header{Key: logging.HeaderPrefix + f.Key, Value: []byte(f.Value)})
+	}
+	if len(extra) == 0 {
+		return
+	}
+	for i := range msgs {
+		headers := make([]bus.Header, 0, len(msgs[i].Headers)+len(extra))
+		for _, h := range msgs[i].Headers {
+			if h.Key != tracing.HeaderTraceParent && h.Key != tracing.HeaderTraceState && !strings.HasPrefix(h.Key, logging.HeaderPrefix) {
+				headers = append(headers, h)
+			}
+		}
+		msgs[i].Headers = append(headers, extra...)
+	}
+}
+
+// PublishOnSearchIngest fans the on_search envelope out to the ingest topic:
+// one small envelope header plus one message per provider, keyed by
+// bpp_id:provider_id. The hash balancer puts every message of a provider on
+// the same partition, so large sellers are spread across partitions and
+// downstream consumers (SchemaGate, Curated Writer) process providers in parallel.
+func PublishOnSearchIngest(ctx context.Context, pub bus.Publisher, env *model.OnSearchEnvelope) error {
+	msgs, err := OnSearchIngestMessages(env)
 	if err != nil {
 		return err
 	}
+	return pub.Publish(ctx, TopicCatalogIngest, msgs...)
+}
+
+// OnSearchIngestMessages builds the catalog.ingest messages for env without
+// publishing them (e.g. to store them in the edge outbox first).
+func OnSearchIngestMessages(env *model.OnSearchEnvelope) ([]bus.Message, error) {
+	providers := env.Message.Catalog.BPPProviders
+	msgs := make([]bus.Message, 0, len(providers)+1)
+
+	header, err := EnvelopeHeaderMessage(env, len(providers))
+	if err != nil {
+		return nil, err
+	}
+	msgs = append(msgs, header)
+
+	for i, provider := range providers {
+		msg, err := ProviderMessage(env, provider, i)
+		if err != nil {
+			return nil, err
+		}
+		msgs = append(msgs, msg)
+	}
+	return msgs, nil
+}
+
+// EnvelopeHeaderMessage strips providers from env and announces how many
+// follow (providerCount < 0 when streaming and the count is unknown).
+func EnvelopeHeaderMessage(env *model.OnSearchEnvelope, providerCount int) (bus.Message, error) {
+	header := *env
+	header.Message.Catalog.BPPProviders = nil
+
+	data, err := json.Marshal(header)
+	if err != nil {
+		return bus.Message{}, err
+	}
 
-	// segmentio/kafka-go: kafka.Message struct for publishing to Kafka topic.
 	// Key is used for partitioning (same key → same partition for ordering).
-	msg := kafka.Message{
-		Key:   []byte(env.Context.TransactionID),
+	return bus.Message{
+		Key:   []byte(env.Context.BppID + ":" + env.Context.TransactionID),
 		Value: data,
 		Time:  time.Now(),
+		Headers: []bus.Header{
+			{Key: HeaderMessageType, Value: []byte(MessageTypeEnvelope)},
+			{Key: HeaderProviderCount, Value: []byte(strconv.Itoa(providerCount))},
+		},
+	}, nil
+}
+
+// ProviderMessage wraps one provider in a copy of env (whose own provider
+// list is ignored), keyed by provider.
+func ProviderMessage(env *model.OnSearchEnvelope, provider model.Provider, index int) (bus.Message, error) {
+	single := *env
+	single.Message.Catalog.BPPProviders = []model.Provider{provider}
+
+	data, err := json.Marshal(single)
+	if err != nil {
+		return bus.Message{}, err
 	}
-	// segmentio/kafka-go: WriteMessages publishes message to Kafka broker asynchronously.
-	return w.WriteMessages(ctx, msg)
+
+	return bus.Message{
+		Key:   []byte(ProviderKey(env.Context.BppID, provider.ID)),
+		Value: data,
+		Time:  time.Now(),
+		Headers: []bus.Header{
+			{Key: HeaderMessageType, Value: []byte(MessageTypeProvider)},
+			{Key: HeaderProviderIndex, Value: []byte(strconv.Itoa(index))},
+		},
+	}, nil
 }
 
-// PublishSearchRequest persists /search calls on a Kafka topic.
-func PublishSearchRequest(ctx context.Context, payload any) error {
-	w := kafkaWriter("catalog.search.requests")
-	defer w.Close()
+// ProviderKey is the partition key for per-provider ingest messages.
+func ProviderKey(bppID, providerID string) string {
+	return bppID + ":" + providerID
+}
+
+// messageType returns the gcr-message-type header ("" for legacy messages).
+func messageType(msg bus.Message) string {
+	return msg.Header(HeaderMessageType)
+}
 
+// PublishSearchRequest persists /search calls on a Kafka topic.
+func PublishSearchRequest(ctx context.Context, pub bus.Publisher, payload any) error {
 	data, err := json.Marshal(payload)
 	if err != nil {
 		return err
 	}
 
-	msg := kafka.Message{
+	msg := bus.Message{
 		Value: data,
 		Time:  time.Now(),
 	}
-	return w.WriteMessages(ctx, msg)
+	return pub.Publish(ctx, TopicSearchRequests, msg)
 }
 
+// PublishCatalogAccepted publishes a CatalogAccepted event to catalog.accepted.
+// It waits for the broker ack so SchemaGate only commits delivered events.
+// The event is wrapped in a versioned events.Envelope; type and version are
+// also set as headers so tooling can filter without decoding the payload.
+func PublishCatalogAccepted(ctx context.Context, pub bus.Publisher, evt model.CatalogAccepted) error {
+	ctx, span := tracing.Start(ctx, "publish "+TopicCatalogAccepted, tracing.KindProducer)
+	defer span.End()
+
+	var trace *events.TraceContext
+	if traceparent, tracestate := tracing.Inject(ctx); traceparent != "" {
+		trace = &events.TraceContext{TraceParent: traceparent, TraceState: tracestate}
+	}
+	data, err := events.Encode(events.TypeCatalogAccepted, evt, trace)
+	if err != nil {
+		span.RecordError(err)
+		return err
+	}
 
+	msg := bus.Message{
+		Key:   []byte(evt.SellerID + ":" + evt.City + ":" + evt.Category),
+		Value: data,
+		Time:  time.Now(),
+		Headers: []bus.Header{
+			{Key: HeaderEventType, Value: []byte(events.TypeCatalogAccepted)},
+			{Key: HeaderEventVersion, Value: []byte(strconv.Itoa(events.CurrentVersion(events.TypeCatalogAccepted)))},
+		},
+	}
+	msgs := []bus.Message{msg}
+	InjectContext(logging.With(ctx, logging.FieldProviderID, evt.ProviderID), msgs)
+	err = pub.PublishSync(ctx, TopicCatalogAccepted, msgs...)
+	span.RecordError(err)
+	return err
+}
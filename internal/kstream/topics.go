@@ -0,0 +1,112 @@
+package kstream
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+
+	"gcr-backend/internal/logging"
+)
+
+// Topic names used by the pipeline. Retry and dead-letter topics are derived
+// per stage (see Stage.RetryTopic / Stage.DLQTopic).
+const (
+	TopicCatalogIngest   = "catalog.ingest"
+	TopicCatalogAccepted = "catalog.accepted"
+	TopicSearchRequests  = "catalog.search.requests"
+)
+
+// TopicSpec describes how a topic is created.
+type TopicSpec struct {
+	Name              string
+	Partitions        int
+	ReplicationFactor int
+	Retention         time.Duration
+}
+
+// DefaultTopics returns the pipeline topics with settings from env:
+//   - KAFKA_TOPIC_PARTITIONS (default 6), KAFKA_TOPIC_REPLICATION (default 1)
+//   - KAFKA_RETENTION (default 168h) for main and retry topics
+//   - KAFKA_DLQ_RETENTION (default 720h) for dead-letter topics
+func DefaultTopics() []TopicSpec {
+	partitions := getenvInt("KAFKA_TOPIC_PARTITIONS", 6)
+	replication := getenvInt("KAFKA_TOPIC_REPLICATION", 1)
+	retention := getenvDuration("KAFKA_RETENTION", 7*24*time.Hour)
+	dlqRetention := getenvDuration("KAFKA_DLQ_RETENTION", 30*24*time.Hour)
+
+	specs := []TopicSpec{}
+	for _, name := range []string{TopicCatalogIngest, TopicCatalogAccepted} {
+		stage := Stage{Topic: name}
+		specs = append(specs,
+			TopicSpec{Name: name, Partitions: partitions, ReplicationFactor: replication, Retention: retention},
+			TopicSpec{Name: stage.RetryTopic(), Partitions: partitions, ReplicationFactor: replication, Retention: retention},
+			TopicSpec{Name: stage.DLQTopic(), Partitions: 1, ReplicationFactor: replication, Retention: dlqRetention},
+		)
+	}
+	specs = append(specs, TopicSpec{Name: TopicSearchRequests, Partitions: partitions, ReplicationFactor: replication, Retention: retention})
+	return specs
+}
+
+// EnsureTopics creates any missing topics on the cluster controller. Existing
+// topics are left unchanged (partitions and retention are not altered).
+func EnsureTopics(ctx context.Context, specs []TopicSpec) error {
+	broker := getenv("KAFKA_BROKER", "kafka:9092")
+
+	// segmentio/kafka-go: topic creation must go to the controller broker.
+	conn, err := kafka.DialContext(ctx, "tcp", broker)
+	if err != nil {
+		return fmt.Errorf("dial %s: %w", broker, err)
+	}
+	defer conn.Close()
+
+	controller, err := conn.Controller()
+	if err != nil {
+		return fmt.Errorf("find controller: %w", err)
+	}
+	ctrl, err := kafka.DialContext(ctx, "tcp", net.JoinHostPort(controller.Host, strconv.Itoa(controller.Port)))
+	if err != nil {
+		return fmt.Errorf("dial controller: %w", err)
+	}
+	defer ctrl.Close()
+
+	configs := make([]kafka.TopicConfig, 0, len(specs))
+	for _, spec := range specs {
+		configs = append(configs, kafka.TopicConfig{
+			Topic:             spec.Name,
+			NumPartitions:     spec.Partitions,
+			ReplicationFactor: spec.ReplicationFactor,
+			ConfigEntries: []kafka.ConfigEntry{
+				{ConfigName: "retention.ms", ConfigValue: strconv.FormatInt(spec.Retention.Milliseconds(), 10)},
+				{ConfigName: "max.message.bytes", ConfigValue: "104857600"},
+			},
+		})
+	}
+
+	if err := ctrl.CreateTopics(configs...); err != nil && !errors.Is(err, kafka.TopicAlreadyExists) {
+		return err
+	}
+	for _, spec := range specs {
+		logging.For(ctx, "kstream").Info("topic ready",
+			"topic", spec.Name, "partitions", spec.Partitions, "replication", spec.ReplicationFactor, "retention", spec.Retention.String())
+	}
+	return nil
+}
+
+func getenvInt(key string, def int) int {
+	if n, err := strconv.Atoi(getenv(key, "")); err == nil && n > 0 {
+		return n
+	}
+	return def
+}
+
+func getenvDuration(key string, def time.Duration) time.Duration {
+	if d, err := time.ParseDuration(getenv(key, "")); err == nil && d > 0 {
+		return d
+	}
+	return def
+}
@@ -0,0 +1,80 @@
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+)
+
+// Field is one correlation key/value carried in the context.
+type Field struct {
+	Key   string
+	Value string
+}
+
+// Correlation field names used across the pipeline.
+const (
+	FieldRequestID     = "request_id"
+	FieldTransactionID = "transaction_id"
+	FieldMessageID     = "message_id"
+	FieldBppID         = "bpp_id"
+	FieldProviderID    = "provider_id"
+)
+
+// HeaderPrefix prefixes correlation fields in bus message headers
+// (e.g. gcr-log-transaction_id), so consumers log with the edge's fields.
+const HeaderPrefix = "gcr-log-"
+
+// HeaderRequestID is the HTTP request ID header (accepted and returned).
+const HeaderRequestID = "X-Request-Id"
+
+type fieldsKey struct{}
+
+// With returns ctx with the key/value pairs added to its correlation fields
+// (a later value for the same key replaces the earlier one; empty values are skipped).
+func With(ctx context.Context, kv ...string) context.Context {
+	fields := append([]Field(nil), Fields(ctx)...)
+	for i := 0; i+1 < len(kv); i += 2 {
+		key, value := kv[i], kv[i+1]
+		if value == "" {
+			continue
+		}
+		replaced := false
+		for j := range fields {
+			if fields[j].Key == key {
+				fields[j].Value = value
+				replaced = true
+			}
+		}
+		if !replaced {
+			fields = append(fields, Field{Key: key, Value: value})
+		}
+	}
+	return context.WithValue(ctx, fieldsKey{}, fields)
+}
+
+// Fields returns the correlation fields in ctx.
+func Fields(ctx context.Context) []Field {
+	fields, _ := ctx.Value(fieldsKey{}).([]Field)
+	return fields
+}
+
+// Middleware assigns each request a request_id (from X-Request-Id or newly
+// generated), returns it in the response and stores it in the context.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(HeaderRequestID)
+		if id == "" || len(id) > 128 {
+			id = newRequestID()
+		}
+		w.Header().Set(HeaderRequestID, id)
+		next.ServeHTTP(w, r.WithContext(With(r.Context(), FieldRequestID, id)))
+	})
+}
+
+func newRequestID() string {
+	var b [8]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
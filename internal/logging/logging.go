@@ -0,0 +1,152 @@
+// Package logging configures log/slog for the whole service: JSON output,
+// per-subsystem levels, correlation fields (request_id, transaction_id,
+// message_id, bpp_id, provider_id, ...) carried in the context across HTTP
+// and bus messages, and sampling for high-volume per-item logs.
+//
+//	logging.For(ctx, "schemagate").Info("provider accepted", "items", n)
+package logging
+
+import (
+	"context"
+	"io"
+	"log"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gcr-backend/internal/tracing"
+)
+
+var (
+	mu           sync.RWMutex
+	base         slog.Handler = slog.NewJSONHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelDebug})
+	defaultLevel              = slog.LevelInfo
+	levels                    = map[string]slog.Level{}
+	once         sync.Once
+
+	itemSampler = newSampler(100, 100, time.Second)
+)
+
+// Init configures output and levels from the environment and routes the
+// standard library logger through slog. It is safe to call multiple times.
+//
+// Configuration (env):
+//   - LOG_FORMAT: "json" (default) or "text"
+//   - LOG_LEVEL: default level (debug, info, warn, error; default info)
+//   - LOG_LEVELS: per-subsystem overrides, e.g. "schemagate=debug,projectors=warn"
+//   - LOG_SAMPLE_BURST / LOG_SAMPLE_THEREAFTER: per-item logs keep the first
+//     BURST records of each message per second, then every THEREAFTER-th (default 100/100)
+func Init() {
+	once.Do(func() {
+		configure(os.Stderr, os.Getenv("LOG_FORMAT"), os.Getenv("LOG_LEVEL"), os.Getenv("LOG_LEVELS"))
+		itemSampler = newSampler(getenvInt("LOG_SAMPLE_BURST", 100), getenvInt("LOG_SAMPLE_THEREAFTER", 100), time.Second)
+
+		// Anything still using the log package (libraries, main) goes through the same handler.
+		slog.SetDefault(slog.New(levelHandler{inner: base, subsystem: "main"}))
+		log.SetFlags(0)
+	})
+}
+
+func configure(w io.Writer, format, level, perSubsystem string) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	opts := &slog.HandlerOptions{Level: slog.LevelDebug} // levels are applied per subsystem
+	if format == "text" {
+		base = slog.NewTextHandler(w, opts)
+	} else {
+		base = slog.NewJSONHandler(w, opts)
+	}
+	if l, ok := parseLevel(level); ok {
+		defaultLevel = l
+	}
+	levels = map[string]slog.Level{}
+	for _, pair := range strings.Split(perSubsystem, ",") {
+		name, lvl, found := strings.Cut(strings.TrimSpace(pair), "=")
+		if !found {
+			continue
+		}
+		if l, ok := parseLevel(lvl); ok {
+			levels[strings.ToLower(strings.TrimSpace(name))] = l
+		}
+	}
+}
+
+func parseLevel(s string) (slog.Level, bool) {
+	var l slog.Level
+	if s == "" || l.UnmarshalText([]byte(s)) != nil {
+		return 0, false
+	}
+	return l, true
+}
+
+func getenvInt(key string, def int) int {
+	if v, err := strconv.Atoi(os.Getenv(key)); err == nil && v > 0 {
+		return v
+	}
+	return def
+}
+
+// levelFor returns the configured level of subsystem.
+func levelFor(subsystem string) slog.Level {
+	mu.RLock()
+	defer mu.RUnlock()
+	if l, ok := levels[subsystem]; ok {
+		return l
+	}
+	return defaultLevel
+}
+
+// levelHandler applies the subsystem's level on top of the shared handler.
+type levelHandler struct {
+	inner     slog.Handler
+	subsystem string
+}
+
+func (h levelHandler) Enabled(ctx context.Context, l slog.Level) bool {
+	return l >= levelFor(h.subsystem)
+}
+
+func (h levelHandler) Handle(ctx context.Context, r slog.Record) error {
+	return h.inner.Handle(ctx, r)
+}
+
+func (h levelHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return levelHandler{inner: h.inner.WithAttrs(attrs), subsystem: h.subsystem}
+}
+
+func (h levelHandler) WithGroup(name string) slog.Handler {
+	return levelHandler{inner: h.inner.WithGroup(name), subsystem: h.subsystem}
+}
+
+// For returns a logger for subsystem carrying the correlation fields and
+// trace/span IDs found in ctx.
+func For(ctx context.Context, subsystem string) *slog.Logger {
+	mu.RLock()
+	inner := base
+	mu.RUnlock()
+
+	attrs := []slog.Attr{slog.String("subsystem", subsystem)}
+	for _, f := range Fields(ctx) {
+		attrs = append(attrs, slog.String(f.Key, f.Value))
+	}
+	if sc := tracing.SpanContextFrom(ctx); sc.IsValid() {
+		attrs = append(attrs, slog.String("trace_id", sc.TraceID.String()), slog.String("span_id", sc.SpanID.String()))
+	}
+	return slog.New(levelHandler{inner: inner.WithAttrs(attrs), subsystem: subsystem})
+}
+
+// Subsystem returns a logger for code paths without a request context.
+func Subsystem(subsystem string) *slog.Logger {
+	return For(context.Background(), subsystem)
+}
+
+// Sampled wraps l so that records are rate limited per message: the first
+// LOG_SAMPLE_BURST per second pass, then every LOG_SAMPLE_THEREAFTER-th.
+// Use it for per-item logs; warnings and errors are never sampled.
+func Sampled(l *slog.Logger) *slog.Logger {
+	return slog.New(sampledHandler{inner: l.Handler(), s: itemSampler})
+}
@@ -0,0 +1,61 @@
+package logging
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// sampler counts records per message within a time window.
+type sampler struct {
+	burst      int
+	thereafter int
+	window     time.Duration
+
+	mu     sync.Mutex
+	start  time.Time
+	counts map[string]int
+}
+
+func newSampler(burst, thereafter int, window time.Duration) *sampler {
+	return &sampler{burst: burst, thereafter: thereafter, window: window, counts: map[string]int{}}
+}
+
+// allow reports whether the n-th record of msg in the current window passes.
+func (s *sampler) allow(msg string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	if now.Sub(s.start) >= s.window {
+		s.start = now
+		clear(s.counts)
+	}
+	s.counts[msg]++
+	n := s.counts[msg]
+	return n <= s.burst || (n-s.burst)%s.thereafter == 0
+}
+
+type sampledHandler struct {
+	inner slog.Handler
+	s     *sampler
+}
+
+func (h sampledHandler) Enabled(ctx context.Context, l slog.Level) bool {
+	return h.inner.Enabled(ctx, l)
+}
+
+func (h sampledHandler) Handle(ctx context.Context, r slog.Record) error {
+	if r.Level < slog.LevelWarn && !h.s.allow(r.Message) {
+		return nil
+	}
+	return h.inner.Handle(ctx, r)
+}
+
+func (h sampledHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return sampledHandler{inner: h.inner.WithAttrs(attrs), s: h.s}
+}
+
+func (h sampledHandler) WithGroup(name string) slog.Handler {
+	return sampledHandler{inner: h.inner.WithGroup(name), s: h.s}
+}
@@ -0,0 +1,298 @@
+// Package metrics is a small Prometheus instrumentation library: counters,
+// gauges and histograms with labels, exposed in the Prometheus text format
+// (version 0.0.4) on GET /metrics. It has no dependencies so every package
+// can import it.
+package metrics
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefBuckets are the default histogram buckets in seconds (same as the Prometheus client).
+var DefBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// collector is one metric family.
+type collector interface {
+	write(b *strings.Builder)
+}
+
+var (
+	mu         sync.Mutex
+	collectors = map[string]collector{}
+)
+
+func register(name string, c collector) {
+	mu.Lock()
+	defer mu.Unlock()
+	if _, ok := collectors[name]; ok {
+		panic("metrics: duplicate metric " + name)
+	}
+	collectors[name] = c
+}
+
+// labelKey joins label values into a map key.
+func labelKey(values []string) string {
+	return strings.Join(values, "\xff")
+}
+
+// desc is the name, help and label names shared by all metric kinds.
+type desc struct {
+	name   string
+	help   string
+	labels []string
+}
+
+func (d desc) header(b *strings.Builder, kind string) {
+	fmt.Fprintf(b, "# HELP %s %s\n# TYPE %s %s\n", d.name, escapeHelp(d.help), d.name, kind)
+}
+
+func (d desc) check(values []string) {
+	if len(values) != len(d.labels) {
+		panic(fmt.Sprintf("metrics: %s expects %d label values, got %d", d.name, len(d.labels), len(values)))
+	}
+}
+
+// CounterVec is a monotonically increasing value per label combination.
+type CounterVec struct {
+	desc
+	mu     sync.Mutex
+	values map[string]*series
+}
+
+type series struct {
+	labels []string
+	value  float64
+}
+
+// NewCounterVec registers a counter family.
+func NewCounterVec(name, help string, labels ...string) *CounterVec {
+	c := &CounterVec{desc: desc{name, help, labels}, values: map[string]*series{}}
+	register(name, c)
+	return c
+}
+
+// Inc adds 1 to the series for values.
+func (c *CounterVec) Inc(values ...string) { c.Add(1, values...) }
+
+// Add adds v (>= 0) to the series for values.
+func (c *CounterVec) Add(v float64, values ...string) {
+	c.check(values)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s := seriesFor(c.values, values)
+	s.value += v
+}
+
+func (c *CounterVec) write(b *strings.Builder) {
+	c.header(b, "counter")
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, s := range sortedSeries(c.values) {
+		writeSample(b, c.name, c.labels, s.labels, "", "", s.value)
+	}
+}
+
+// GaugeVec is a value that can go up and down per label combination.
+type GaugeVec struct {
+	desc
+	mu     sync.Mutex
+	values map[string]*series
+}
+
+// NewGaugeVec registers a gauge family.
+func NewGaugeVec(name, help string, labels ...string) *GaugeVec {
+	g := &GaugeVec{desc: desc{name, help, labels}, values: map[string]*series{}}
+	register(name, g)
+	return g
+}
+
+// Set sets the series for values to v.
+func (g *GaugeVec) Set(v float64, values ...string) {
+	g.check(values)
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	seriesFor(g.values, values).value = v
+}
+
+// Add adds v (may be negative) to the series for values.
+func (g *GaugeVec) Add(v float64, values ...string) {
+	g.check(values)
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	seriesFor(g.values, values).value += v
+}
+
+func (g *GaugeVec) write(b *strings.Builder) {
+	g.header(b, "gauge")
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for _, s := range sortedSeries(g.values) {
+		writeSample(b, g.name, g.labels, s.labels, "", "", s.value)
+	}
+}
+
+// HistogramVec counts observations into cumulative buckets per label combination.
+type HistogramVec struct {
+	desc
+	buckets []float64
+	mu      sync.Mutex
+	values  map[string]*histSeries
+}
+
+type histSeries struct {
+	labels []string
+	counts []uint64 // per bucket, not cumulative
+	count  uint64
+	sum    float64
+}
+
+// NewHistogramVec registers a histogram family; nil buckets means DefBuckets.
+func NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	if buckets == nil {
+		buckets = DefBuckets
+	}
+	h := &HistogramVec{desc: desc{name, help, labels}, buckets: buckets, values: map[string]*histSeries{}}
+	register(name, h)
+	return h
+}
+
+// Observe records v for the series for values.
+func (h *HistogramVec) Observe(v float64, values ...string) {
+	h.check(values)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	key := labelKey(values)
+	s, ok := h.values[key]
+	if !ok {
+		s = &histSeries{labels: append([]string(nil), values...), counts: make([]uint64, len(h.buckets))}
+		h.values[key] = s
+	}
+	if i := sort.SearchFloat64s(h.buckets, v); i < len(h.buckets) {
+		s.counts[i]++
+	}
+	s.count++
+	s.sum += v
+}
+
+// Since observes the seconds elapsed since start.
+func (h *HistogramVec) Since(start time.Time, values ...string) {
+	h.Observe(time.Since(start).Seconds(), values...)
+}
+
+func (h *HistogramVec) write(b *strings.Builder) {
+	h.header(b, "histogram")
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	keys := make([]string, 0, len(h.values))
+	for k := range h.values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		s := h.values[k]
+		var cum uint64
+		for i, le := range h.buckets {
+			cum += s.counts[i]
+			writeSample(b, h.name+"_bucket", h.labels, s.labels, "le", formatFloat(le), float64(cum))
+		}
+		writeSample(b, h.name+"_bucket", h.labels, s.labels, "le", "+Inf", float64(s.count))
+		writeSample(b, h.name+"_sum", h.labels, s.labels, "", "", s.sum)
+		writeSample(b, h.name+"_count", h.labels, s.labels, "", "", float64(s.count))
+	}
+}
+
+func seriesFor(m map[string]*series, values []string) *series {
+	key := labelKey(values)
+	s, ok := m[key]
+	if !ok {
+		s = &series{labels: append([]string(nil), values...)}
+		m[key] = s
+	}
+	return s
+}
+
+func sortedSeries(m map[string]*series) []*series {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	out := make([]*series, len(keys))
+	for i, k := range keys {
+		out[i] = m[k]
+	}
+	return out
+}
+
+// writeSample writes one line: name{labels[,extra]} value.
+func writeSample(b *strings.Builder, name string, names, values []string, extraName, extraValue string, v float64) {
+	b.WriteString(name)
+	if len(names) > 0 || extraName != "" {
+		b.WriteByte('{')
+		for i, n := range names {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			fmt.Fprintf(b, "%s=\"%s\"", n, escapeLabel(values[i]))
+		}
+		if extraName != "" {
+			if len(names) > 0 {
+				b.WriteByte(',')
+			}
+			fmt.Fprintf(b, "%s=\"%s\"", extraName, extraValue)
+		}
+		b.WriteByte('}')
+	}
+	b.WriteByte(' ')
+	b.WriteString(formatFloat(v))
+	b.WriteByte('\n')
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var (
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+)
+
+func escapeLabel(s string) string { return labelEscaper.Replace(s) }
+func escapeHelp(s string) string  { return helpEscaper.Replace(s) }
+
+// Handler handles GET /metrics in the Prometheus text exposition format.
+func Handler(w http.ResponseWriter, r *http.Request) {
+	mu.Lock()
+	names := make([]string, 0, len(collectors))
+	for name := range collectors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	list := make([]collector, len(names))
+	for i, name := range names {
+		list[i] = collectors[name]
+	}
+	mu.Unlock()
+
+	var b strings.Builder
+	for _, c := range list {
+		c.write(&b)
+	}
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	_, _ = w.Write([]byte(b.String()))
+}
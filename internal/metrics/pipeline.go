@@ -0,0 +1,53 @@
+package metrics
+
+// Pipeline metrics. Label values must stay low-cardinality (route names,
+// reasons, topics, cities, categories), never IDs.
+var (
+	// Edge / ingest
+	IngestRequests = NewCounterVec("gcr_ingest_requests_total",
+		"Seller ingest requests by endpoint and HTTP status.", "endpoint", "status")
+	IngestDuration = NewHistogramVec("gcr_ingest_request_duration_seconds",
+		"Seller ingest request latency by endpoint.", nil, "endpoint")
+	SignatureVerifications = NewCounterVec("gcr_signature_verifications_total",
+		"ONDC Authorization header checks by result (ok|missing|malformed|expired|unknown_key|inactive|invalid).", "result")
+	RegistryLookups = NewCounterVec("gcr_registry_lookups_total",
+		"Subscriber registry lookups by result (hit|negative_hit|miss|not_found|error).", "result")
+	RateLimitRequests = NewCounterVec("gcr_ratelimit_requests_total",
+		"Rate-limited endpoint requests by endpoint and result (allowed|limited).", "endpoint", "result")
+	SubscriberChecks = NewCounterVec("gcr_subscriber_checks_total",
+		"bap_id/bpp_id registry checks by role (bap|bpp) and result (ok|unknown|inactive|uri_mismatch|error).", "role", "result")
+
+	// Internal data and admin APIs
+	AuthRequests = NewCounterVec("gcr_auth_requests_total",
+		"Internal API authentication and authorization by result (ok|missing|invalid|expired|forbidden).", "result")
+
+	// SchemaGate
+	SchemaGateProviders = NewCounterVec("gcr_schemagate_providers_total",
+		"Providers validated by SchemaGate, by result (accepted|rejected).", "result")
+	SchemaGateRejections = NewCounterVec("gcr_schemagate_rejections_total",
+		"SchemaGate rejections by reason.", "reason")
+
+	// Curated writer
+	CuratedWriteDuration = NewHistogramVec("gcr_curated_write_duration_seconds",
+		"Latency of writing one provider to the curated store.", nil)
+
+	// Projectors
+	ProjectorUpdates = NewCounterVec("gcr_projector_updates_total",
+		"Read model updates by projector (index|shard|delta) and result (ok|error).", "projector", "result")
+
+	// Consumers
+	ConsumerMessages = NewCounterVec("gcr_consumer_messages_total",
+		"Messages handled per topic and consumer group, by result (ok|retry|dlq|stale).", "topic", "group", "result")
+	ConsumerLag = NewGaugeVec("gcr_consumer_lag",
+		"Messages behind the end of the topic per consumer group.", "topic", "group")
+
+	// Infrastructure errors
+	RedisErrors = NewCounterVec("gcr_redis_errors_total",
+		"Failed read model store operations by command.", "op")
+	KafkaErrors = NewCounterVec("gcr_kafka_errors_total",
+		"Failed Kafka operations by operation (produce|fetch|commit) and topic.", "op", "topic")
+
+	// Discovery
+	SearchDuration = NewHistogramVec("gcr_discovery_search_duration_seconds",
+		"Buyer /search latency by city and category (\"other\" when not in the index).", nil, "city", "category")
+)
@@ -9,6 +9,9 @@ type CatalogAccepted struct {
 	Timestamp  string `json:"timestamp"`   // commit timestamp (tC)
 	ProviderID string `json:"provider_id"`
 	Domain     string `json:"domain"`
+	// QualityScore is the SchemaGate soft-check score (0..1) for the provider.
+	QualityScore float64 `json:"quality_score"`
+	WarningCount int     `json:"warning_count"`
 }
 
 // SearchRequest models a buyer /search call.
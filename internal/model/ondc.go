@@ -128,4 +128,32 @@ type CategoryDescriptor struct {
 	LongDesc  string `json:"long_desc" validate:"required"`
 }
 
+// AckResponse is the synchronous ONDC response to any network call:
+// {"message": {"ack": {"status": "ACK"|"NACK"}}, "error": {...}}.
+type AckResponse struct {
+	Message AckMessage `json:"message"`
+	Error   *Error     `json:"error,omitempty"`
+}
+
+type AckMessage struct {
+	Ack Ack `json:"ack"`
+}
+
+type Ack struct {
+	Status string `json:"status"` // ACK or NACK
+}
+
+// Error is the ONDC error object attached to a NACK.
+type Error struct {
+	Type    string `json:"type"` // CONTEXT-ERROR, CORE-ERROR, DOMAIN-ERROR, POLICY-ERROR, JSON-SCHEMA-ERROR
+	Code    string `json:"code"`
+	Message string `json:"message,omitempty"`
+}
 
+// NACK builds a negative acknowledgement with an ONDC error.
+func NACK(errType, code, message string) AckResponse {
+	return AckResponse{
+		Message: AckMessage{Ack: Ack{Status: "NACK"}},
+		Error:   &Error{Type: errType, Code: code, Message: message},
+	}
+}
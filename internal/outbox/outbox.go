@@ -0,0 +1,414 @@
+package outbox
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gcr-backend/internal/bus"
+	"gcr-backend/internal/logging"
+)
+
+// PublishFunc delivers messages to a topic and returns once they are acked.
+type PublishFunc func(ctx context.Context, topic string, msgs ...bus.Message) error
+
+// Outbox is a local durable queue for the edge. Payloads are appended (and
+// fsynced) to segment files in the data directory before the edge answers
+// the seller; a relay goroutine drains anything not yet acknowledged to Kafka.
+//
+// Layout in dir:
+//
+//	segment-<first seq>.log  one JSON record per line, append-only
+//	acks.log                 one acknowledged seq per line
+//
+// A segment is deleted once every record in it is acked and a newer segment exists.
+type Outbox struct {
+	dir          string
+	segmentBytes int64
+
+	mu       sync.Mutex
+	nextSeq  uint64
+	active   *os.File
+	activeAt string // active segment path
+	size     int64
+	acks     *os.File
+	pending  map[uint64]location // appended, not acked
+	inflight map[uint64]bool     // being published by the edge right now
+	segments map[string]int      // segment path → unacked record count
+}
+
+type location struct {
+	segment string
+	offset  int64
+}
+
+// record is one outbox entry: a batch of messages for a topic.
+type record struct {
+	Seq       uint64    `json:"seq"`
+	Topic     string    `json:"topic"`
+	CreatedAt time.Time `json:"created_at"`
+	Messages  []message `json:"messages"`
+}
+
+type message struct {
+	Key     []byte            `json:"key,omitempty"`
+	Value   []byte            `json:"value"`
+	Headers map[string][]byte `json:"headers,omitempty"`
+}
+
+// Open loads (or creates) the outbox in dir and rebuilds the pending set
+// from existing segments. segmentBytes bounds the size of one segment file.
+func Open(dir string, segmentBytes int64) (*Outbox, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	o := &Outbox{
+		dir:          dir,
+		segmentBytes: segmentBytes,
+		nextSeq:      1,
+		pending:      map[uint64]location{},
+		inflight:     map[uint64]bool{},
+		segments:     map[string]int{},
+	}
+
+	acked, err := o.loadAcks()
+	if err != nil {
+		return nil, err
+	}
+	if err := o.loadSegments(acked); err != nil {
+		return nil, err
+	}
+
+	o.acks, err = os.OpenFile(filepath.Join(dir, "acks.log"), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	if err := o.rotate(); err != nil {
+		return nil, err
+	}
+	if n := len(o.pending); n > 0 {
+		logging.Subsystem("outbox").Info("pending records recovered", "pending", n, "dir", dir)
+	}
+	return o, nil
+}
+
+func (o *Outbox) loadAcks() (map[uint64]bool, error) {
+	acked := map[uint64]bool{}
+	f, err := os.Open(filepath.Join(o.dir, "acks.log"))
+	if os.IsNotExist(err) {
+		return acked, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if seq, err := strconv.ParseUint(strings.TrimSpace(scanner.Text()), 10, 64); err == nil {
+			acked[seq] = true
+		}
+	}
+	return acked, scanner.Err()
+}
+
+func (o *Outbox) segmentPaths() ([]string, error) {
+	paths, err := filepath.Glob(filepath.Join(o.dir, "segment-*.log"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(paths, func(i, j int) bool { return segmentSeq(paths[i]) < segmentSeq(paths[j]) })
+	return paths, nil
+}
+
+func segmentSeq(path string) uint64 {
+	name := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(path), "segment-"), ".log")
+	seq, _ := strconv.ParseUint(name, 10, 64)
+	return seq
+}
+
+func (o *Outbox) loadSegments(acked map[uint64]bool) error {
+	paths, err := o.segmentPaths()
+	if err != nil {
+		return err
+	}
+	for _, path := range paths {
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		reader := bufio.NewReader(f)
+		var offset int64
+		o.segments[path] = 0
+		for {
+			line, err := reader.ReadBytes('\n')
+			if len(line) > 0 && line[len(line)-1] == '\n' {
+				var rec record
+				if json.Unmarshal(line, &rec) == nil {
+					if rec.Seq >= o.nextSeq {
+						o.nextSeq = rec.Seq + 1
+					}
+					if !acked[rec.Seq] {
+						o.pending[rec.Seq] = location{segment: path, offset: offset}
+						o.segments[path]++
+					}
+				}
+			}
+			// A torn last line (crash mid-write) has no newline and is ignored.
+			offset += int64(len(line))
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				f.Close()
+				return err
+			}
+		}
+		f.Close()
+	}
+	return nil
+}
+
+// rotate starts a new active segment. Callers hold o.mu (or are in Open).
+func (o *Outbox) rotate() error {
+	if o.active != nil {
+		if err := o.active.Close(); err != nil {
+			return err
+		}
+	}
+	path := filepath.Join(o.dir, fmt.Sprintf("segment-%020d.log", o.nextSeq))
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	o.active, o.activeAt, o.size = f, path, info.Size()
+	if _, ok := o.segments[path]; !ok {
+		o.segments[path] = 0
+	}
+	return nil
+}
+
+// Append durably stores msgs for topic and returns the record's seq. The
+// record is marked in flight: the relay leaves it alone until the caller
+// calls Ack (published) or Release (hand over to the relay).
+func (o *Outbox) Append(topic string, msgs []bus.Message) (uint64, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	rec := record{Seq: o.nextSeq, Topic: topic, CreatedAt: time.Now().UTC(), Messages: make([]message, len(msgs))}
+	for i, m := range msgs {
+		headers := map[string][]byte{}
+		for _, h := range m.Headers {
+			headers[h.Key] = h.Value
+		}
+		rec.Messages[i] = message{Key: m.Key, Value: m.Value, Headers: headers}
+	}
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return 0, err
+	}
+	data = append(data, '\n')
+
+	if o.size > 0 && o.size+int64(len(data)) > o.segmentBytes {
+		if err := o.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	offset := o.size
+	if _, err := o.active.Write(data); err != nil {
+		return 0, err
+	}
+	// Durable before the edge acknowledges the seller.
+	if err := o.active.Sync(); err != nil {
+		return 0, err
+	}
+	o.size += int64(len(data))
+	o.nextSeq++
+	o.pending[rec.Seq] = location{segment: o.activeAt, offset: offset}
+	o.segments[o.activeAt]++
+	o.inflight[rec.Seq] = true
+	return rec.Seq, nil
+}
+
+// Ack marks seq as delivered to Kafka.
+func (o *Outbox) Ack(seq uint64) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	loc, ok := o.pending[seq]
+	if !ok {
+		return nil
+	}
+	if _, err := o.acks.WriteString(strconv.FormatUint(seq, 10) + "\n"); err != nil {
+		return err
+	}
+	delete(o.pending, seq)
+	delete(o.inflight, seq)
+	o.segments[loc.segment]--
+	o.compact()
+	return nil
+}
+
+// Release hands an in-flight record over to the relay (publish failed).
+func (o *Outbox) Release(seq uint64) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	delete(o.inflight, seq)
+}
+
+// Pending returns the number of records not yet delivered.
+func (o *Outbox) Pending() int {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return len(o.pending)
+}
+
+// compact deletes fully acked, inactive segments and trims acks.log once
+// nothing is pending. Callers hold o.mu.
+func (o *Outbox) compact() {
+	for path, unacked := range o.segments {
+		if unacked > 0 || path == o.activeAt {
+			continue
+		}
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			logging.Subsystem("outbox").Warn("remove segment failed", "path", path, "error", err)
+			continue
+		}
+		delete(o.segments, path)
+	}
+	if len(o.pending) == 0 && len(o.segments) == 1 && o.segments[o.activeAt] == 0 {
+		// Everything delivered: start fresh so acks.log does not grow forever.
+		if err := o.acks.Truncate(0); err != nil {
+			logging.Subsystem("outbox").Warn("truncate acks failed", "error", err)
+			return
+		}
+		if err := o.active.Truncate(0); err != nil {
+			logging.Subsystem("outbox").Warn("truncate segment failed", "error", err)
+			return
+		}
+		o.size = 0
+	}
+}
+
+// readRecord loads a pending record from its segment.
+func (o *Outbox) readRecord(loc location) (record, error) {
+	var rec record
+	f, err := os.Open(loc.segment)
+	if err != nil {
+		return rec, err
+	}
+	defer f.Close()
+	if _, err := f.Seek(loc.offset, io.SeekStart); err != nil {
+		return rec, err
+	}
+	line, err := bufio.NewReader(f).ReadBytes('\n')
+	if err != nil {
+		return rec, err
+	}
+	err = json.Unmarshal(line, &rec)
+	return rec, err
+}
+
+// Relay drains pending records to Kafka every interval until ctx is cancelled.
+// Records are retried on every pass until publish succeeds.
+func (o *Outbox) Relay(ctx context.Context, publish PublishFunc, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		o.drain(ctx, publish)
+	}
+}
+
+func (o *Outbox) drain(ctx context.Context, publish PublishFunc) {
+	o.mu.Lock()
+	seqs := make([]uint64, 0, len(o.pending))
+	for seq := range o.pending {
+		if !o.inflight[seq] {
+			seqs = append(seqs, seq)
+		}
+	}
+	o.mu.Unlock()
+	if len(seqs) == 0 {
+		return
+	}
+	sort.Slice(seqs, func(i, j int) bool { return seqs[i] < seqs[j] })
+
+	delivered := 0
+	for _, seq := range seqs {
+		o.mu.Lock()
+		loc, ok := o.pending[seq]
+		if ok && o.inflight[seq] {
+			ok = false
+		}
+		if ok {
+			o.inflight[seq] = true
+		}
+		o.mu.Unlock()
+		if !ok {
+			continue
+		}
+
+		rec, err := o.readRecord(loc)
+		if err != nil {
+			logging.For(ctx, "outbox").Error("read record failed", "outbox_seq", seq, "error", err)
+			o.Release(seq)
+			continue
+		}
+		if err := publish(ctx, rec.Topic, rec.busMessages()...); err != nil {
+			// Kafka still down: keep order, try again on the next pass.
+			logging.For(ctx, "outbox").Warn("relay failed", "outbox_seq", seq, "topic", rec.Topic, "pending", len(seqs)-delivered, "error", err)
+			o.Release(seq)
+			return
+		}
+		if err := o.Ack(seq); err != nil {
+			logging.For(ctx, "outbox").Error("ack failed", "outbox_seq", seq, "error", err)
+			o.Release(seq)
+			return
+		}
+		delivered++
+	}
+	logging.For(ctx, "outbox").Info("relayed records", "delivered", delivered)
+}
+
+func (r record) busMessages() []bus.Message {
+	msgs := make([]bus.Message, len(r.Messages))
+	for i, m := range r.Messages {
+		headers := make([]bus.Header, 0, len(m.Headers))
+		for k, v := range m.Headers {
+			headers = append(headers, bus.Header{Key: k, Value: v})
+		}
+		msgs[i] = bus.Message{Key: m.Key, Value: m.Value, Headers: headers, Time: time.Now()}
+	}
+	return msgs
+}
+
+// Close closes the active segment and the acks file.
+func (o *Outbox) Close() error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.active != nil {
+		o.active.Close()
+	}
+	return o.acks.Close()
+}
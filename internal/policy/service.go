@@ -2,19 +2,12 @@ package policy
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"os"
 
-	"github.com/redis/go-redis/v9"
+	"gcr-backend/internal/readmodel"
 )
 
-func getenv(key, def string) string {
-	if v := os.Getenv(key); v != "" {
-		return v
-	}
-	return def
-}
-
 // PolicyStatus represents the authorization status for a buyer×seller×domain×city combination.
 type PolicyStatus string
 
@@ -26,27 +19,21 @@ const (
 
 // Service provides buyer/seller authorization checks.
 type Service struct {
-	rdb *redis.Client
+	store readmodel.Store
 }
 
-// NewService creates a new Policy Service backed by Redis.
-// Uses redis/go-redis/v9 for buyer×seller authorization caching.
-func NewService() *Service {
-	// redis/go-redis/v9: NewClient creates Redis client for Policy Service.
-	// Stores policy status (allowed/denied/unknown) for fast authorization checks.
-	rdb := redis.NewClient(&redis.Options{
-		Addr: getenv("REDIS_ADDR", "redis:6379"),
-	})
-	return &Service{rdb: rdb}
+// NewService creates a new Policy Service on the shared read model store,
+// which caches policy status (allowed/denied/unknown) for fast authorization checks.
+func NewService(store readmodel.Store) *Service {
+	return &Service{store: store}
 }
 
 // CheckPolicy returns the policy status for {buyer, seller, domain, city}.
 func (s *Service) CheckPolicy(ctx context.Context, buyerID, sellerID, domain, city string) (PolicyStatus, error) {
 	key := fmt.Sprintf("policy:%s:%s:%s:%s", buyerID, sellerID, domain, city)
-	// redis/go-redis/v9: Get retrieves policy status from Redis.
-	// redis.Nil indicates key doesn't exist (unknown policy).
-	val, err := s.rdb.Get(ctx, key).Result()
-	if err == redis.Nil {
+	// ErrNotFound indicates key doesn't exist (unknown policy).
+	val, err := s.store.Get(ctx, key)
+	if errors.Is(err, readmodel.ErrNotFound) {
 		return PolicyUnknown, nil
 	}
 	if err != nil {
@@ -66,8 +53,7 @@ func (s *Service) CheckPolicy(ctx context.Context, buyerID, sellerID, domain, ci
 // SetPolicy sets the policy status for {buyer, seller, domain, city}.
 func (s *Service) SetPolicy(ctx context.Context, buyerID, sellerID, domain, city string, status PolicyStatus) error {
 	key := fmt.Sprintf("policy:%s:%s:%s:%s", buyerID, sellerID, domain, city)
-	// redis/go-redis/v9: Set stores policy status (allowed/denied) in Redis.
 	// TTL=0 means no expiration. Used for buyer×seller authorization caching.
-	return s.rdb.Set(ctx, key, string(status), 0).Err()
+	return s.store.Set(ctx, key, string(status), 0)
 }
 
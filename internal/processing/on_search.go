@@ -6,9 +6,7 @@ import (
 	"time"
 
 	"gcr-backend/internal/bloom"
-	"gcr-backend/internal/kstream"
 	"gcr-backend/internal/model"
-	"gcr-backend/internal/storage"
 )
 
 // OnSearchStats is returned to the client so we can see throughput and latency.
@@ -16,17 +14,17 @@ type OnSearchStats struct {
 	Providers int64 `json:"providers"`
 	// DurationMillis is the end-to-end processing time for this on_search call.
 	DurationMillis int64 `json:"duration_ms"`
+	// Delivery is set by the edge: "published" (on Kafka) or "queued" (in the outbox).
+	Delivery string `json:"delivery,omitempty"`
 }
 
-// ProcessOnSearch validates business rules (delegated to storage) and fans
-// out provider-level work in parallel so large catalogs complete quickly.
+// ProcessOnSearch computes the response stats and fans out provider-level
+// work in parallel so large catalogs complete quickly. Publishing to
+// catalog.ingest is the edge handler's job; the curated store is written only
+// by SchemaGate, so every stored record is validated and scored.
 func ProcessOnSearch(ctx context.Context, env *model.OnSearchEnvelope) (*OnSearchStats, error) {
 	start := time.Now()
 
-	// Fire-and-forget publish to Kafka so multiple on_search calls are durably
-	// captured on topic.catalog.on_search.ingest.
-	_ = kstream.PublishOnSearchIngest(ctx, env)
-
 	providers := env.Message.Catalog.BPPProviders
 	if len(providers) == 0 {
 		return &OnSearchStats{Providers: 0, DurationMillis: 0}, nil
@@ -42,7 +40,6 @@ func ProcessOnSearch(ctx context.Context, env *model.OnSearchEnvelope) (*OnSearc
 			defer wg.Done()
 			for p := range jobs {
 				_ = bloom.SeenProvider(ctx, env.Context.Domain+":"+env.Context.City+":"+p.ID)
-				_ = storage.WriteProviderCatalog(ctx, env.Context, p)
 			}
 		}()
 	}
@@ -2,64 +2,71 @@ package projections
 
 import (
 	"context"
-	"encoding/json"
-	"log"
-	"os"
-
-	"github.com/redis/go-redis/v9"
+	"errors"
+	"fmt"
 
+	"gcr-backend/internal/bus"
+	"gcr-backend/internal/events"
 	"gcr-backend/internal/kstream"
+	"gcr-backend/internal/metrics"
 	"gcr-backend/internal/model"
+	"gcr-backend/internal/readmodel"
+	"gcr-backend/internal/tracing"
 )
 
-func getenv(key, def string) string {
-	if v := os.Getenv(key); v != "" {
-		return v
-	}
-	return def
-}
-
 // ConsumeAcceptedTopic runs all three projectors (Index, Shard, Delta) that
-// consume from catalog.accepted and update Redis read models.
-func ConsumeAcceptedTopic(ctx context.Context) error {
-	// redis/go-redis/v9: NewClient creates a Redis client connection.
-	// Used to store read-optimized projections (Index, Shard, Delta) for fast buyer queries.
-	rdb := redis.NewClient(&redis.Options{
-		Addr: getenv("REDIS_ADDR", "redis:6379"),
-	})
-	defer rdb.Close()
-
-	reader := kstream.KafkaReader("catalog.accepted", "projectors-group")
-	defer reader.Close()
-
-	log.Println("Projectors: consuming from catalog.accepted")
+// consume from catalog.accepted and update the read models in store. Offsets
+// are committed only after all projectors succeeded.
+func ConsumeAcceptedTopic(ctx context.Context, sub bus.Subscriber, pub bus.Publisher, store readmodel.Store) error {
+	return kstream.Stage{
+		Name:       "Projectors",
+		Topic:      kstream.TopicCatalogAccepted,
+		GroupID:    "projectors-group",
+		Workers:    1,
+		Subscriber: sub,
+		Publisher:  pub,
+		Order:      store,
+		Handler: func(ctx context.Context, msg bus.Message) error {
+			return project(ctx, store, msg)
+		},
+	}.Run(ctx)
+}
 
-	for {
-		msg, err := reader.ReadMessage(ctx)
-		if err != nil {
-			return err
+// project applies one CatalogAccepted event to all three read models. Any
+// projector error fails the message, so it is retried (and finally sent to
+// catalog.accepted.dlq) instead of only being logged.
+func project(ctx context.Context, store readmodel.Store, msg bus.Message) error {
+	var evt model.CatalogAccepted
+	if _, err := events.Decode(msg.Value, events.TypeCatalogAccepted, &evt); err != nil {
+		if errors.Is(err, events.ErrIncompatible) {
+			// Written by a newer producer: retry, then park in the DLQ for replay after upgrade.
+			return fmt.Errorf("Projectors: %w", err)
 		}
+		return kstream.Permanent(fmt.Errorf("Projectors: failed to decode: %w", err))
+	}
 
-		var evt model.CatalogAccepted
-		if err := json.Unmarshal(msg.Value, &evt); err != nil {
-			log.Printf("Projectors: failed to unmarshal: %v", err)
+	var errs []error
+	for _, p := range []struct {
+		name   string
+		label  string
+		update func(context.Context, readmodel.Store, model.CatalogAccepted) error
+	}{
+		{"Index", "index", UpdateIndex}, // city:category → sellers
+		{"Shard", "shard", UpdateShard}, // seller×city×category snapshot
+		{"Delta", "delta", UpdateDelta}, // short-TTL diff
+	} {
+		pctx, span := tracing.Start(ctx, "projector."+p.label, tracing.KindInternal)
+		span.SetAttr("seller_id", evt.SellerID)
+		err := p.update(pctx, store, evt)
+		span.RecordError(err)
+		span.End()
+		if err != nil {
+			metrics.ProjectorUpdates.Inc(p.label, "error")
+			errs = append(errs, fmt.Errorf("%s Projector: %w", p.name, err))
 			continue
 		}
-
-		// Update Index (city:category → sellers)
-		if err := UpdateIndex(ctx, rdb, evt); err != nil {
-			log.Printf("Index Projector error: %v", err)
-		}
-
-		// Update Full Shard (seller×city×category snapshot)
-		if err := UpdateShard(ctx, rdb, evt); err != nil {
-			log.Printf("Shard Projector error: %v", err)
-		}
-
-		// Update Delta (short-TTL diff)
-		if err := UpdateDelta(ctx, rdb, evt); err != nil {
-			log.Printf("Delta Projector error: %v", err)
-		}
+		metrics.ProjectorUpdates.Inc(p.label, "ok")
 	}
-}
 
+	return errors.Join(errs...)
+}
@@ -4,19 +4,18 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"log"
 	"time"
 
-	"github.com/redis/go-redis/v9"
-
+	"gcr-backend/internal/logging"
 	"gcr-backend/internal/model"
+	"gcr-backend/internal/readmodel"
 )
 
 const deltaTTL = 5 * time.Minute // Short TTL for deltas
 
 // UpdateDelta computes a delta vs previous state and stores it with TTL.
 // If delta is too large or absent, it skips (shard is fallback).
-func UpdateDelta(ctx context.Context, rdb *redis.Client, evt model.CatalogAccepted) error {
+func UpdateDelta(ctx context.Context, store readmodel.Store, evt model.CatalogAccepted) error {
 	key := fmt.Sprintf("delta:%s:%s:%s:%s", evt.SellerID, evt.City, evt.Category, evt.Timestamp)
 
 	delta := map[string]any{
@@ -33,13 +32,13 @@ func UpdateDelta(ctx context.Context, rdb *redis.Client, evt model.CatalogAccept
 		return err
 	}
 
-	// redis/go-redis/v9: Set stores delta JSON with TTL (5 minutes).
+	// SET stores delta JSON with TTL (5 minutes).
 	// Deltas expire automatically to prevent Redis memory bloat. Shard is fallback if delta missing.
-	if err := rdb.Set(ctx, key, data, deltaTTL).Err(); err != nil {
+	if err := store.Set(ctx, key, string(data), deltaTTL); err != nil {
 		return err
 	}
 
-	log.Printf("Delta Projector: updated %s (TTL: %v)", key, deltaTTL)
+	logging.Sampled(logging.For(ctx, "projectors")).Info("delta updated", "projector", "delta", "key", key, "ttl", deltaTTL.String())
 	return nil
 }
 
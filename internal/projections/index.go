@@ -3,32 +3,81 @@ package projections
 import (
 	"context"
 	"fmt"
-	"log"
-
-	"github.com/redis/go-redis/v9"
+	"strconv"
 
+	"gcr-backend/internal/logging"
 	"gcr-backend/internal/model"
+	"gcr-backend/internal/readmodel"
 )
 
-// UpdateIndex updates the Redis Index (city:category → sellers) when a CatalogAccepted event arrives.
-func UpdateIndex(ctx context.Context, rdb *redis.Client, evt model.CatalogAccepted) error {
+// UpdateIndex updates the Index (city:category → sellers) when a CatalogAccepted event arrives.
+func UpdateIndex(ctx context.Context, store readmodel.Store, evt model.CatalogAccepted) error {
 	key := fmt.Sprintf("idx:%s:%s", evt.City, evt.Category)
 
-	// redis/go-redis/v9: SAdd adds member to Redis Set. Used for Index: city:category → sellers.
+	// SADD adds member to a Set. Used for Index: city:category → sellers.
 	// Sets provide O(1) membership checks for fast discovery queries.
-	if err := rdb.SAdd(ctx, key, evt.SellerID).Err(); err != nil {
+	if err := store.SAdd(ctx, key, evt.SellerID); err != nil {
 		return err
 	}
 
-	// redis/go-redis/v9: ZAdd adds member to Redis Sorted Set (ZSET) with score.
+	// ZADD adds member to a Sorted Set (ZSET) with score.
 	// Used for freshness tracking: sellers sorted by update timestamp.
 	freshKey := fmt.Sprintf("freshness:%s:%s", evt.City, evt.Category)
 	score := float64(0)
-	if err := rdb.ZAdd(ctx, freshKey, redis.Z{Score: score, Member: evt.SellerID}).Err(); err != nil {
+	if err := store.ZAdd(ctx, freshKey, readmodel.Z{Score: score, Member: evt.SellerID}); err != nil {
+		return err
+	}
+
+	// Quality ranking: sellers sorted by the mean SchemaGate quality score of
+	// their providers. Discovery uses this to order candidates; warnings never
+	// remove a seller from the index.
+	score, err := sellerQuality(ctx, store, evt)
+	if err != nil {
+		return err
+	}
+	qualityKey := fmt.Sprintf("quality:%s:%s", evt.City, evt.Category)
+	if err := store.ZAdd(ctx, qualityKey, readmodel.Z{Score: score, Member: evt.SellerID}); err != nil {
 		return err
 	}
 
-	log.Printf("Index Projector: updated %s with seller %s", key, evt.SellerID)
+	logging.Sampled(logging.For(ctx, "projectors")).Info("index updated", "projector", "index", "key", key, "seller_id", evt.SellerID)
 	return nil
 }
 
+
+// sellerQuality records the provider's latest score under
+// quality:{city}:{category}:{seller} (provider ID → score) and returns the
+// mean over all the seller's providers there, so one provider's update does
+// not replace the score of the whole seller.
+func sellerQuality(ctx context.Context, store readmodel.Store, evt model.CatalogAccepted) (float64, error) {
+	if evt.ProviderID == "" {
+		return evt.QualityScore, nil
+	}
+	scoresKey := fmt.Sprintf("quality:%s:%s:%s", evt.City, evt.Category, evt.SellerID)
+	providersKey := scoresKey + ":providers"
+	if err := store.HSet(ctx, scoresKey, map[string]string{evt.ProviderID: strconv.FormatFloat(evt.QualityScore, 'f', -1, 64)}); err != nil {
+		return 0, err
+	}
+	if err := store.SAdd(ctx, providersKey, evt.ProviderID); err != nil {
+		return 0, err
+	}
+	providers, err := store.SMembers(ctx, providersKey)
+	if err != nil {
+		return 0, err
+	}
+	values, err := store.HMGet(ctx, scoresKey, providers...)
+	if err != nil {
+		return 0, err
+	}
+	sum, n := 0.0, 0
+	for _, v := range values {
+		if f, err := strconv.ParseFloat(v, 64); err == nil {
+			sum += f
+			n++
+		}
+	}
+	if n == 0 {
+		return evt.QualityScore, nil
+	}
+	return sum / float64(n), nil
+}
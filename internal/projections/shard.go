@@ -4,16 +4,15 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"log"
-
-	"github.com/redis/go-redis/v9"
 
+	"gcr-backend/internal/logging"
 	"gcr-backend/internal/model"
+	"gcr-backend/internal/readmodel"
 )
 
 // UpdateShard builds a ready-to-send /on_search JSON for seller×city×category
-// and stores it in Redis as a Full Shard.
-func UpdateShard(ctx context.Context, rdb *redis.Client, evt model.CatalogAccepted) error {
+// and stores it as a Full Shard.
+func UpdateShard(ctx context.Context, store readmodel.Store, evt model.CatalogAccepted) error {
 	key := fmt.Sprintf("shard:%s:%s:cat:%s", evt.SellerID, evt.City, evt.Category)
 
 	shard := map[string]any{
@@ -29,13 +28,13 @@ func UpdateShard(ctx context.Context, rdb *redis.Client, evt model.CatalogAccept
 		return err
 	}
 
-	// redis/go-redis/v9: Set stores ready-to-send /on_search JSON as string value.
+	// SET stores ready-to-send /on_search JSON as string value.
 	// TTL=0 means no expiration. Used for Full Shard: seller×city×category snapshot.
-	if err := rdb.Set(ctx, key, data, 0).Err(); err != nil {
+	if err := store.Set(ctx, key, string(data), 0); err != nil {
 		return err
 	}
 
-	log.Printf("Shard Projector: updated %s", key)
+	logging.Sampled(logging.For(ctx, "projectors")).Info("shard updated", "projector", "shard", "key", key)
 	return nil
 }
 
@@ -0,0 +1,247 @@
+// Package ratelimit enforces per-subscriber quotas on the ONDC endpoints
+// (seller /on_search, buyer /search). Each subscriber_id × endpoint is a GCRA
+// bucket in the shared read model store, so all edge instances share one
+// limit; when Redis fails, a process-local store takes over so the edge keeps
+// serving (limits then apply per instance).
+package ratelimit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"gcr-backend/internal/logging"
+	"gcr-backend/internal/metrics"
+	"gcr-backend/internal/model"
+	"gcr-backend/internal/readmodel"
+	"gcr-backend/internal/signing"
+)
+
+// Endpoints with quotas.
+const (
+	EndpointOnSearch     = "on_search"
+	EndpointOnSearchBulk = "on_search_bulk"
+	EndpointSearch       = "search"
+)
+
+// DefaultTier applies to subscribers without an explicit tier.
+const DefaultTier = "default"
+
+// Quota allows Rate requests per second on average, with bursts of Burst.
+type Quota struct {
+	Rate  float64 `json:"rate"`
+	Burst int64   `json:"burst"`
+}
+
+// Config maps tiers to per-endpoint quotas and subscribers to tiers.
+//
+//	{"tiers": {"default": {"on_search": {"rate": 20, "burst": 40}},
+//	           "premium": {"on_search": {"rate": 200, "burst": 400}}},
+//	 "subscribers": {"seller.big.example.com": "premium"}}
+type Config struct {
+	Tiers       map[string]map[string]Quota `json:"tiers"`
+	Subscribers map[string]string           `json:"subscribers"`
+}
+
+// DefaultConfig is used without RATE_LIMIT_CONFIG.
+func DefaultConfig() Config {
+	return Config{
+		Tiers: map[string]map[string]Quota{
+			DefaultTier: {
+				EndpointOnSearch:     {Rate: 20, Burst: 40},
+				EndpointOnSearchBulk: {Rate: 1, Burst: 2},
+				EndpointSearch:       {Rate: 50, Burst: 100},
+			},
+		},
+	}
+}
+
+// quota returns the tier and quota of subscriberID on endpoint; ok is false
+// when the endpoint is not limited. Tiers fall back to the default tier per endpoint.
+func (c Config) quota(subscriberID, endpoint string) (tier string, q Quota, ok bool) {
+	tier = c.Subscribers[subscriberID]
+	if tier == "" {
+		tier = DefaultTier
+	}
+	if q, ok = c.Tiers[tier][endpoint]; ok {
+		return tier, q, q.Rate > 0
+	}
+	q, ok = c.Tiers[DefaultTier][endpoint]
+	return tier, q, ok && q.Rate > 0
+}
+
+// Limiter applies the configured quotas.
+type Limiter struct {
+	store    readmodel.Store
+	fallback readmodel.Store
+	cfg      Config
+	mode     string
+	ipHeader string
+}
+
+// New creates a limiter on store.
+//
+// Configuration (env):
+//   - RATE_LIMIT_MODE: enforce (default), log (count only) or off
+//   - RATE_LIMIT_CONFIG: JSON file with tiers and subscriber tiers (see Config)
+//   - RATE_LIMIT_CLIENT_IP_HEADER: header carrying the client address when
+//     behind a proxy (e.g. X-Forwarded-For); unset uses the remote address
+func New(store readmodel.Store) (*Limiter, error) {
+	cfg := DefaultConfig()
+	if path := os.Getenv("RATE_LIMIT_CONFIG"); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		cfg = Config{}
+		if err := json.Unmarshal(data, &cfg); err != nil {
+			return nil, fmt.Errorf("ratelimit: parse %s: %w", path, err)
+		}
+	}
+	mode := os.Getenv("RATE_LIMIT_MODE")
+	switch mode {
+	case "":
+		mode = "enforce"
+	case "off", "log", "enforce":
+	default:
+		return nil, fmt.Errorf("ratelimit: unknown RATE_LIMIT_MODE %q", mode)
+	}
+	return &Limiter{store: store, fallback: readmodel.NewMemory(), cfg: cfg, mode: mode, ipHeader: os.Getenv("RATE_LIMIT_CLIENT_IP_HEADER")}, nil
+}
+
+// Mode returns the configured mode.
+func (l *Limiter) Mode() string {
+	if l == nil {
+		return "off"
+	}
+	return l.mode
+}
+
+// Decision is the outcome of one request.
+type Decision struct {
+	Allowed    bool
+	RetryAfter time.Duration
+	Tier       string
+	Quota      Quota
+}
+
+// Allow counts one request of subscriberID on endpoint.
+func (l *Limiter) Allow(ctx context.Context, endpoint, subscriberID string) Decision {
+	tier, q, limited := l.cfg.quota(subscriberID, endpoint)
+	if !limited {
+		return Decision{Allowed: true, Tier: tier}
+	}
+
+	key := "ratelimit:" + endpoint + ":" + subscriberID
+	interval := time.Duration(float64(time.Second) / q.Rate)
+	burst := max(q.Burst, 1)
+	allowed, retry, err := l.store.GCRA(ctx, key, interval, burst)
+	if err != nil {
+		// Redis outage: limit locally rather than failing or waving everything through.
+		logging.Sampled(logging.For(ctx, "ratelimit")).Warn("store unavailable, using local limiter", "error", err)
+		allowed, retry, _ = l.fallback.GCRA(ctx, key, interval, burst)
+	}
+	return Decision{Allowed: allowed, RetryAfter: retry, Tier: tier, Quota: q}
+}
+
+// Check applies the limit to a request and, when it is exceeded in enforce
+// mode, writes a 429 NACK with Retry-After and returns false. A request
+// already counted by Middleware passes.
+func (l *Limiter) Check(w http.ResponseWriter, r *http.Request, endpoint, subscriberID string) bool {
+	if l == nil || l.mode == "off" {
+		return true
+	}
+	if counted, _ := r.Context().Value(countedKey{}).(bool); counted {
+		return true
+	}
+	d := l.Allow(r.Context(), endpoint, subscriberID)
+	if d.Allowed {
+		metrics.RateLimitRequests.Inc(endpoint, "allowed")
+		return true
+	}
+	metrics.RateLimitRequests.Inc(endpoint, "limited")
+	logging.Sampled(logging.For(r.Context(), "ratelimit")).Info("rate limit exceeded",
+		"endpoint", endpoint, "subscriber_id", subscriberID, "tier", d.Tier, "retry_after", d.RetryAfter.String(), "mode", l.mode)
+	if l.mode != "enforce" {
+		return true
+	}
+
+	// Retry-After is whole seconds, rounded up so an immediate retry is not rejected again.
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(d.RetryAfter.Seconds()))))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusTooManyRequests)
+	_ = json.NewEncoder(w).Encode(model.NACK("POLICY-ERROR", "10000",
+		fmt.Sprintf("rate limit exceeded for %s on %s (tier %s: %g/s, burst %d)", subscriberID, endpoint, d.Tier, d.Quota.Rate, d.Quota.Burst)))
+	return false
+}
+
+type countedKey struct{}
+
+// ClientMiddleware limits a request by client address before its body is
+// read, so a flood costs no parse or digest time. The endpoint is the name
+// of the matched mux route and the bucket is "ip:{address}", which uses the
+// default tier unless the address is listed under subscribers in the config.
+// Nothing here trusts the Authorization header: a forged keyId cannot spend
+// the quota of the subscriber it names.
+func (l *Limiter) ClientMiddleware(next http.Handler) http.Handler {
+	if l == nil || l.mode == "off" {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := mux.CurrentRoute(r)
+		if route == nil || route.GetName() == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if !l.Check(w, r, route.GetName(), "ip:"+l.clientIP(r)) {
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Middleware charges the subscriber whose signature the signing middleware,
+// which must run before this one, has verified. Requests without a verified
+// signer are left to Check in the handler.
+func (l *Limiter) Middleware(next http.Handler) http.Handler {
+	if l == nil || l.mode == "off" {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := mux.CurrentRoute(r)
+		sub, ok := signing.SubscriberFrom(r.Context())
+		if route == nil || route.GetName() == "" || !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if !l.Check(w, r, route.GetName(), sub.SubscriberID) {
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), countedKey{}, true)))
+	})
+}
+
+// clientIP returns the first address of the configured client IP header
+// (set by a trusted proxy), or the connection's remote address.
+func (l *Limiter) clientIP(r *http.Request) string {
+	if l.ipHeader != "" {
+		if v := r.Header.Get(l.ipHeader); v != "" {
+			first, _, _ := strings.Cut(v, ",")
+			return strings.TrimSpace(first)
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
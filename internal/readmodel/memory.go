@@ -0,0 +1,373 @@
+package readmodel
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Memory is an in-process Store for tests and Redis-less dev runs. TTLs are
+// enforced lazily on access. Bloom filters never scale: they are sized once
+// for capacity and error rate, like a NONSCALING RedisBloom filter.
+type Memory struct {
+	mu   sync.Mutex
+	data map[string]*memEntry
+}
+
+type memEntry struct {
+	expiresAt time.Time // zero: no expiry
+
+	str   *string
+	set   map[string]struct{}
+	zset  map[string]float64
+	hash  map[string]string
+	bloom *memBloom
+}
+
+type memBloom struct {
+	bits     []uint64
+	m        uint64 // number of bits
+	k        uint64 // number of hash functions
+	capacity int64
+	items    int64
+}
+
+// NewMemory returns an empty in-memory store.
+func NewMemory() *Memory {
+	return &Memory{data: map[string]*memEntry{}}
+}
+
+// lookup returns the live entry for key (nil if missing or expired).
+// Callers must hold m.mu.
+func (m *Memory) lookup(key string) *memEntry {
+	e, ok := m.data[key]
+	if !ok {
+		return nil
+	}
+	if !e.expiresAt.IsZero() && time.Now().After(e.expiresAt) {
+		delete(m.data, key)
+		return nil
+	}
+	return e
+}
+
+// entry returns the live entry for key, creating an empty one if needed.
+func (m *Memory) entry(key string) *memEntry {
+	if e := m.lookup(key); e != nil {
+		return e
+	}
+	e := &memEntry{}
+	m.data[key] = e
+	return e
+}
+
+func expiry(ttl time.Duration) time.Time {
+	if ttl <= 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(ttl)
+}
+
+// Get implements Store.
+func (m *Memory) Get(_ context.Context, key string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e := m.lookup(key)
+	if e == nil {
+		return "", ErrNotFound
+	}
+	if e.str == nil {
+		return "", ErrWrongType
+	}
+	return *e.str, nil
+}
+
+// Set implements Store.
+func (m *Memory) Set(_ context.Context, key, value string, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.data[key] = &memEntry{str: &value, expiresAt: expiry(ttl)}
+	return nil
+}
+
+// SetNX implements Store.
+func (m *Memory) SetNX(_ context.Context, key, value string, ttl time.Duration) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.lookup(key) != nil {
+		return false, nil
+	}
+	m.data[key] = &memEntry{str: &value, expiresAt: expiry(ttl)}
+	return true, nil
+}
+
+// SetXX implements Store.
+func (m *Memory) SetXX(_ context.Context, key, value string, ttl time.Duration) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.lookup(key) == nil {
+		return false, nil
+	}
+	m.data[key] = &memEntry{str: &value, expiresAt: expiry(ttl)}
+	return true, nil
+}
+
+// Del implements Store.
+func (m *Memory) Del(_ context.Context, keys ...string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, key := range keys {
+		delete(m.data, key)
+	}
+	return nil
+}
+
+// Expire implements Store.
+func (m *Memory) Expire(_ context.Context, key string, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if e := m.lookup(key); e != nil {
+		e.expiresAt = expiry(ttl)
+	}
+	return nil
+}
+
+// SAdd implements Store.
+func (m *Memory) SAdd(_ context.Context, key string, members ...string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e := m.entry(key)
+	if e.set == nil {
+		if e.str != nil || e.zset != nil || e.hash != nil || e.bloom != nil {
+			return ErrWrongType
+		}
+		e.set = map[string]struct{}{}
+	}
+	for _, member := range members {
+		e.set[member] = struct{}{}
+	}
+	return nil
+}
+
+// SMembers implements Store.
+func (m *Memory) SMembers(_ context.Context, key string) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e := m.lookup(key)
+	if e == nil {
+		return []string{}, nil
+	}
+	if e.set == nil {
+		return nil, ErrWrongType
+	}
+	out := make([]string, 0, len(e.set))
+	for member := range e.set {
+		out = append(out, member)
+	}
+	sort.Strings(out)
+	return out, nil
+}
+
+// ZAdd implements Store.
+func (m *Memory) ZAdd(_ context.Context, key string, members ...Z) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e := m.entry(key)
+	if e.zset == nil {
+		if e.str != nil || e.set != nil || e.hash != nil || e.bloom != nil {
+			return ErrWrongType
+		}
+		e.zset = map[string]float64{}
+	}
+	for _, z := range members {
+		e.zset[z.Member] = z.Score
+	}
+	return nil
+}
+
+// ZMScore implements Store.
+func (m *Memory) ZMScore(_ context.Context, key string, members ...string) ([]float64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := make([]float64, len(members))
+	e := m.lookup(key)
+	if e == nil {
+		return out, nil
+	}
+	if e.zset == nil {
+		return nil, ErrWrongType
+	}
+	for i, member := range members {
+		out[i] = e.zset[member]
+	}
+	return out, nil
+}
+
+// HMGet implements Store.
+func (m *Memory) HMGet(_ context.Context, key string, fields ...string) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := make([]string, len(fields))
+	e := m.lookup(key)
+	if e == nil {
+		return out, nil
+	}
+	if e.hash == nil {
+		return nil, ErrWrongType
+	}
+	for i, field := range fields {
+		out[i] = e.hash[field]
+	}
+	return out, nil
+}
+
+// HSet implements Store.
+func (m *Memory) HSet(_ context.Context, key string, values map[string]string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e := m.entry(key)
+	if e.hash == nil {
+		if e.str != nil || e.set != nil || e.zset != nil || e.bloom != nil {
+			return ErrWrongType
+		}
+		e.hash = map[string]string{}
+	}
+	for field, value := range values {
+		e.hash[field] = value
+	}
+	return nil
+}
+
+// BFReserve implements Store. expansion is ignored (filters do not scale).
+func (m *Memory) BFReserve(_ context.Context, key string, errorRate float64, capacity, _ int64) error {
+	if capacity <= 0 || errorRate <= 0 || errorRate >= 1 {
+		return fmt.Errorf("readmodel: invalid BF.RESERVE params (capacity=%d, error_rate=%v)", capacity, errorRate)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.lookup(key) != nil {
+		return nil
+	}
+
+	// Standard sizing: m = -n·ln(p)/ln(2)², k = m/n·ln(2).
+	bits := uint64(math.Ceil(-float64(capacity) * math.Log(errorRate) / (math.Ln2 * math.Ln2)))
+	k := uint64(math.Max(1, math.Round(float64(bits)/float64(capacity)*math.Ln2)))
+	m.data[key] = &memEntry{bloom: &memBloom{
+		bits:     make([]uint64, (bits+63)/64),
+		m:        bits,
+		k:        k,
+		capacity: capacity,
+	}}
+	return nil
+}
+
+// BFAdd implements Store.
+func (m *Memory) BFAdd(_ context.Context, key, item string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e := m.lookup(key)
+	if e == nil || e.bloom == nil {
+		return false, fmt.Errorf("readmodel: bloom filter %s not reserved", key)
+	}
+	f := e.bloom
+	added := false
+	for _, idx := range f.positions(item) {
+		word, mask := idx/64, uint64(1)<<(idx%64)
+		if f.bits[word]&mask == 0 {
+			added = true
+			f.bits[word] |= mask
+		}
+	}
+	if added {
+		f.items++
+	}
+	return added, nil
+}
+
+// BFExists implements Store.
+func (m *Memory) BFExists(_ context.Context, key, item string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e := m.lookup(key)
+	if e == nil || e.bloom == nil {
+		return false, nil
+	}
+	for _, idx := range e.bloom.positions(item) {
+		if e.bloom.bits[idx/64]&(uint64(1)<<(idx%64)) == 0 {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// BFInfo implements Store.
+func (m *Memory) BFInfo(_ context.Context, key string) (BloomInfo, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e := m.lookup(key)
+	if e == nil || e.bloom == nil {
+		return BloomInfo{}, fmt.Errorf("readmodel: bloom filter %s not found", key)
+	}
+	return BloomInfo{
+		Name:      key,
+		Capacity:  e.bloom.capacity,
+		SizeBytes: int64(len(e.bloom.bits) * 8),
+		Filters:   1,
+		Items:     e.bloom.items,
+	}, nil
+}
+
+// GCRA implements Store. The value is the next theoretical arrival time in
+// Unix nanoseconds, expiring once the bucket is full again.
+func (m *Memory) GCRA(_ context.Context, key string, interval time.Duration, burst int64) (bool, time.Duration, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	tat := now
+	if e := m.lookup(key); e != nil {
+		if e.str == nil {
+			return false, 0, ErrWrongType
+		}
+		if n, err := strconv.ParseInt(*e.str, 10, 64); err == nil && time.Unix(0, n).After(now) {
+			tat = time.Unix(0, n)
+		}
+	}
+	newTAT := tat.Add(interval)
+	if retry := newTAT.Sub(now) - interval*time.Duration(burst); retry > 0 {
+		return false, retry, nil
+	}
+	v := strconv.FormatInt(newTAT.UnixNano(), 10)
+	m.data[key] = &memEntry{str: &v, expiresAt: newTAT}
+	return true, 0, nil
+}
+
+// Close implements Store.
+func (m *Memory) Close() error {
+	return nil
+}
+
+// positions derives k bit positions via double hashing (Kirsch–Mitzenmacher).
+func (f *memBloom) positions(item string) []uint64 {
+	h1 := fnv.New64a()
+	_, _ = h1.Write([]byte(item))
+	a := h1.Sum64()
+	h2 := fnv.New64()
+	_, _ = h2.Write([]byte(item))
+	b := h2.Sum64() | 1
+
+	out := make([]uint64, f.k)
+	for i := uint64(0); i < f.k; i++ {
+		out[i] = (a + i*b) % f.m
+	}
+	return out
+}
+
+var (
+	_ Store = (*Redis)(nil)
+	_ Store = (*Memory)(nil)
+)
@@ -0,0 +1,253 @@
+package readmodel
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"gcr-backend/internal/metrics"
+)
+
+// Redis is the Store backed by a redis/go-redis/v9 client. Bloom operations
+// need the RedisBloom module (redis-stack-server).
+type Redis struct {
+	rdb *redis.Client
+}
+
+// NewRedis connects to the Redis server at addr.
+func NewRedis(addr string) *Redis {
+	// redis/go-redis/v9: NewClient is a connection pool shared by every service.
+	return &Redis{rdb: redis.NewClient(&redis.Options{Addr: addr})}
+}
+
+// Get implements Store.
+func (r *Redis) Get(ctx context.Context, key string) (string, error) {
+	val, err := r.rdb.Get(ctx, key).Result()
+	if errors.Is(err, redis.Nil) {
+		return "", ErrNotFound
+	}
+	return val, observe("get", err)
+}
+
+// Set implements Store.
+func (r *Redis) Set(ctx context.Context, key, value string, ttl time.Duration) error {
+	return observe("set", r.rdb.Set(ctx, key, value, ttl).Err())
+}
+
+// SetNX implements Store.
+func (r *Redis) SetNX(ctx context.Context, key, value string, ttl time.Duration) (bool, error) {
+	ok, err := r.rdb.SetNX(ctx, key, value, ttl).Result()
+	return ok, observe("setnx", err)
+}
+
+// SetXX implements Store.
+func (r *Redis) SetXX(ctx context.Context, key, value string, ttl time.Duration) (bool, error) {
+	ok, err := r.rdb.SetXX(ctx, key, value, ttl).Result()
+	return ok, observe("setxx", err)
+}
+
+// Del implements Store.
+func (r *Redis) Del(ctx context.Context, keys ...string) error {
+	return observe("del", r.rdb.Del(ctx, keys...).Err())
+}
+
+// Expire implements Store.
+func (r *Redis) Expire(ctx context.Context, key string, ttl time.Duration) error {
+	return observe("expire", r.rdb.Expire(ctx, key, ttl).Err())
+}
+
+// SAdd implements Store.
+func (r *Redis) SAdd(ctx context.Context, key string, members ...string) error {
+	args := make([]any, len(members))
+	for i, m := range members {
+		args[i] = m
+	}
+	return observe("sadd", r.rdb.SAdd(ctx, key, args...).Err())
+}
+
+// SMembers implements Store.
+func (r *Redis) SMembers(ctx context.Context, key string) ([]string, error) {
+	members, err := r.rdb.SMembers(ctx, key).Result()
+	return members, observe("smembers", err)
+}
+
+// ZAdd implements Store.
+func (r *Redis) ZAdd(ctx context.Context, key string, members ...Z) error {
+	zs := make([]redis.Z, len(members))
+	for i, m := range members {
+		zs[i] = redis.Z{Score: m.Score, Member: m.Member}
+	}
+	return observe("zadd", r.rdb.ZAdd(ctx, key, zs...).Err())
+}
+
+// ZMScore implements Store.
+func (r *Redis) ZMScore(ctx context.Context, key string, members ...string) ([]float64, error) {
+	// redis/go-redis/v9: ZMScore returns 0 for members without a score.
+	scores, err := r.rdb.ZMScore(ctx, key, members...).Result()
+	return scores, observe("zmscore", err)
+}
+
+// HMGet implements Store.
+func (r *Redis) HMGet(ctx context.Context, key string, fields ...string) ([]string, error) {
+	vals, err := r.rdb.HMGet(ctx, key, fields...).Result()
+	if err != nil {
+		return nil, observe("hmget", err)
+	}
+	out := make([]string, len(fields))
+	for i, v := range vals {
+		if s, ok := v.(string); ok {
+			out[i] = s
+		}
+	}
+	return out, nil
+}
+
+// HSet implements Store.
+func (r *Redis) HSet(ctx context.Context, key string, values map[string]string) error {
+	if len(values) == 0 {
+		return nil
+	}
+	return observe("hset", r.rdb.HSet(ctx, key, values).Err())
+}
+
+// BFReserve implements Store.
+func (r *Redis) BFReserve(ctx context.Context, key string, errorRate float64, capacity, expansion int64) error {
+	// RedisBloom (redis/go-redis/v9): BF.RESERVE creates a probabilistic data structure for duplicate detection.
+	args := []any{"BF.RESERVE", key, errorRate, capacity}
+	if expansion > 0 {
+		args = append(args, "EXPANSION", expansion)
+	} else {
+		args = append(args, "NONSCALING")
+	}
+	err := r.rdb.Do(ctx, args...).Err()
+	if err != nil && !strings.Contains(err.Error(), "exists") {
+		return observe("bf.reserve", err)
+	}
+	return nil
+}
+
+// BFAdd implements Store.
+func (r *Redis) BFAdd(ctx context.Context, key, item string) (bool, error) {
+	// RedisBloom (redis/go-redis/v9): BF.ADD returns 1 if item was new, 0 if it probably existed.
+	added, err := redisBool(r.rdb.Do(ctx, "BF.ADD", key, item))
+	return added, observe("bf.add", err)
+}
+
+// BFExists implements Store.
+func (r *Redis) BFExists(ctx context.Context, key, item string) (bool, error) {
+	// RedisBloom (redis/go-redis/v9): BF.EXISTS checks membership without inserting.
+	exists, err := redisBool(r.rdb.Do(ctx, "BF.EXISTS", key, item))
+	return exists, observe("bf.exists", err)
+}
+
+// BFInfo implements Store.
+func (r *Redis) BFInfo(ctx context.Context, key string) (BloomInfo, error) {
+	res, err := r.rdb.Do(ctx, "BF.INFO", key).Result()
+	if err != nil {
+		return BloomInfo{}, observe("bf.info", err)
+	}
+
+	// BF.INFO is a flat label/value array on RESP2 and a map on RESP3.
+	fields := map[string]int64{}
+	switch v := res.(type) {
+	case []any:
+		for i := 0; i+1 < len(v); i += 2 {
+			label, _ := v[i].(string)
+			fields[label] = toInt64(v[i+1])
+		}
+	case map[any]any:
+		for k, val := range v {
+			label, _ := k.(string)
+			fields[label] = toInt64(val)
+		}
+	default:
+		return BloomInfo{}, fmt.Errorf("readmodel: unexpected BF.INFO reply %T", res)
+	}
+
+	return BloomInfo{
+		Name:          key,
+		Capacity:      fields["Capacity"],
+		SizeBytes:     fields["Size"],
+		Filters:       fields["Number of filters"],
+		Items:         fields["Number of items inserted"],
+		ExpansionRate: fields["Expansion rate"],
+	}, nil
+}
+
+// gcraScript keeps the theoretical arrival time (TAT, µs on the Redis clock)
+// of the next request in KEYS[1]. ARGV: interval µs, burst. Returns
+// {allowed 0|1, retry after µs}.
+var gcraScript = redis.NewScript(`
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000000 + tonumber(t[2])
+local interval = tonumber(ARGV[1])
+local tat = tonumber(redis.call('GET', KEYS[1]) or now)
+if tat < now then tat = now end
+local new_tat = tat + interval
+local retry = new_tat - interval * tonumber(ARGV[2]) - now
+if retry > 0 then return {0, retry} end
+redis.call('SET', KEYS[1], string.format('%.0f', new_tat), 'PX', math.ceil((new_tat - now) / 1000))
+return {1, 0}
+`)
+
+// GCRA implements Store with a Lua script, so concurrent edge instances share
+// one limit per key.
+func (r *Redis) GCRA(ctx context.Context, key string, interval time.Duration, burst int64) (bool, time.Duration, error) {
+	// redis/go-redis/v9: Script.Run uses EVALSHA and falls back to EVAL.
+	res, err := gcraScript.Run(ctx, r.rdb, []string{key}, interval.Microseconds(), burst).Int64Slice()
+	if err != nil {
+		return false, 0, observe("gcra", err)
+	}
+	if len(res) != 2 {
+		return false, 0, observe("gcra", fmt.Errorf("readmodel: unexpected GCRA reply %v", res))
+	}
+	return res[0] == 1, time.Duration(res[1]) * time.Microsecond, nil
+}
+
+// Close implements Store.
+func (r *Redis) Close() error {
+	return r.rdb.Close()
+}
+
+// observe counts a failed command in gcr_redis_errors_total and returns err.
+func observe(op string, err error) error {
+	if err != nil {
+		metrics.RedisErrors.Inc(op)
+	}
+	return err
+}
+
+// redisBool reads a BF.ADD/BF.EXISTS reply, which can be either int (0/1) or
+// bool (true/false) depending on Redis version and protocol.
+func redisBool(res *redis.Cmd) (bool, error) {
+	if res.Err() != nil {
+		return false, res.Err()
+	}
+	// Try int first, then bool
+	val, err := res.Int()
+	if err != nil {
+		boolVal, boolErr := res.Bool()
+		if boolErr != nil {
+			return false, fmt.Errorf("readmodel: reply type error (not int or bool): %w", err)
+		}
+		return boolVal, nil
+	}
+	return val == 1, nil
+}
+
+func toInt64(v any) int64 {
+	switch n := v.(type) {
+	case int64:
+		return n
+	case int:
+		return int64(n)
+	case float64:
+		return int64(n)
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,82 @@
+// Package readmodel is the key-value store behind the read side and the
+// ingest-side caches: projections, discovery, policy, Bloom filters,
+// idempotency keys and item hashes.
+//
+// The Redis implementation is used in production; Memory is an in-process
+// substitute for tests and Redis-less runs (READMODEL_BACKEND=memory).
+// Clients are constructed once in main and injected into the services.
+package readmodel
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrNotFound is returned by Get for a missing (or expired) key.
+var ErrNotFound = errors.New("readmodel: key not found")
+
+// ErrWrongType is returned when a key holds a different kind of value.
+var ErrWrongType = errors.New("readmodel: operation against a key holding the wrong kind of value")
+
+// Z is a sorted-set member with its score.
+type Z struct {
+	Score  float64
+	Member string
+}
+
+// BloomInfo mirrors the fields reported by RedisBloom BF.INFO.
+type BloomInfo struct {
+	Name          string `json:"name"`
+	Capacity      int64  `json:"capacity"`
+	SizeBytes     int64  `json:"size_bytes"`
+	Filters       int64  `json:"filters"`
+	Items         int64  `json:"items"`
+	ExpansionRate int64  `json:"expansion_rate"`
+}
+
+// Store is the subset of Redis the services rely on. A ttl of 0 means the
+// key does not expire.
+type Store interface {
+	// Strings
+	Get(ctx context.Context, key string) (string, error)
+	Set(ctx context.Context, key, value string, ttl time.Duration) error
+	// SetNX sets key only if it does not exist and reports whether it did.
+	SetNX(ctx context.Context, key, value string, ttl time.Duration) (bool, error)
+	// SetXX sets key only if it already exists and reports whether it did.
+	SetXX(ctx context.Context, key, value string, ttl time.Duration) (bool, error)
+	Del(ctx context.Context, keys ...string) error
+	Expire(ctx context.Context, key string, ttl time.Duration) error
+
+	// Sets
+	SAdd(ctx context.Context, key string, members ...string) error
+	SMembers(ctx context.Context, key string) ([]string, error)
+
+	// Sorted sets
+	ZAdd(ctx context.Context, key string, members ...Z) error
+	// ZMScore returns one score per member; missing members score 0.
+	ZMScore(ctx context.Context, key string, members ...string) ([]float64, error)
+
+	// Hashes
+	// HMGet returns one value per field; missing fields are "".
+	HMGet(ctx context.Context, key string, fields ...string) ([]string, error)
+	HSet(ctx context.Context, key string, values map[string]string) error
+
+	// Bloom filters
+	// BFReserve creates filter key if it does not exist yet. expansion > 0
+	// lets it scale; expansion == 0 reserves a non-scaling filter.
+	BFReserve(ctx context.Context, key string, errorRate float64, capacity, expansion int64) error
+	// BFAdd inserts item and reports whether it was newly added.
+	BFAdd(ctx context.Context, key, item string) (bool, error)
+	// BFExists reports whether item is probably present, without inserting it.
+	BFExists(ctx context.Context, key, item string) (bool, error)
+	BFInfo(ctx context.Context, key string) (BloomInfo, error)
+
+	// Rate limiting
+	// GCRA atomically applies one request to the generic cell rate limiter at
+	// key: one request per interval on average, bursts of up to burst. It
+	// reports whether the request is allowed and, if not, when to retry.
+	GCRA(ctx context.Context, key string, interval time.Duration, burst int64) (allowed bool, retryAfter time.Duration, err error)
+
+	Close() error
+}
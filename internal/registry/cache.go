@@ -0,0 +1,92 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"gcr-backend/internal/metrics"
+)
+
+// Cached wraps a Client with an in-process TTL cache. Not-found results are
+// cached for a shorter negative TTL so unknown subscribers cannot hammer the
+// registry; when the registry is unreachable, an expired entry is served
+// rather than failing every request.
+type Cached struct {
+	inner       Client
+	ttl         time.Duration
+	negativeTTL time.Duration
+
+	mu      sync.Mutex
+	entries map[Query]cacheEntry
+}
+
+type cacheEntry struct {
+	records []Subscriber // nil: negative entry
+	expires time.Time
+}
+
+// NewCached caches lookups of inner for ttl (negative results for negativeTTL).
+func NewCached(inner Client, ttl, negativeTTL time.Duration) *Cached {
+	return &Cached{inner: inner, ttl: ttl, negativeTTL: negativeTTL, entries: map[Query]cacheEntry{}}
+}
+
+// Lookup returns the cached records for q, querying inner when they expired.
+func (c *Cached) Lookup(ctx context.Context, q Query) ([]Subscriber, error) {
+	now := time.Now()
+	c.mu.Lock()
+	entry, ok := c.entries[q]
+	c.mu.Unlock()
+	if ok && now.Before(entry.expires) {
+		if entry.records == nil {
+			metrics.RegistryLookups.Inc("negative_hit")
+			return nil, ErrNotFound
+		}
+		metrics.RegistryLookups.Inc("hit")
+		return entry.records, nil
+	}
+
+	records, err := c.inner.Lookup(ctx, q)
+	switch {
+	case err == nil:
+		metrics.RegistryLookups.Inc("miss")
+		c.store(q, cacheEntry{records: records, expires: now.Add(c.ttl)})
+		return records, nil
+	case errors.Is(err, ErrNotFound):
+		metrics.RegistryLookups.Inc("not_found")
+		c.store(q, cacheEntry{expires: now.Add(c.negativeTTL)})
+		return nil, ErrNotFound
+	default:
+		metrics.RegistryLookups.Inc("error")
+		if ok && entry.records != nil {
+			return entry.records, nil // stale, registry unavailable
+		}
+		return nil, err
+	}
+}
+
+// LookupKey returns the record of subscriberID with key uniqueKeyID.
+func (c *Cached) LookupKey(ctx context.Context, subscriberID, uniqueKeyID string) (Subscriber, error) {
+	return lookupKey(ctx, c, subscriberID, uniqueKeyID)
+}
+
+// maxCacheEntries bounds the cache; random unknown IDs only add negative entries.
+const maxCacheEntries = 50000
+
+func (c *Cached) store(q Query, entry cacheEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.entries) >= maxCacheEntries {
+		now := time.Now()
+		for k, e := range c.entries {
+			if now.After(e.expires) {
+				delete(c.entries, k)
+			}
+		}
+		if len(c.entries) >= maxCacheEntries {
+			clear(c.entries)
+		}
+	}
+	c.entries[q] = entry
+}
@@ -0,0 +1,141 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"gcr-backend/internal/logging"
+	"gcr-backend/internal/metrics"
+	"gcr-backend/internal/model"
+)
+
+// Subscriber types checked by the edge (sellers) and discovery (buyers).
+const (
+	TypeBAP = "BAP"
+	TypeBPP = "BPP"
+)
+
+// Check errors, besides ErrNotFound for unknown subscribers.
+var (
+	ErrInactive    = errors.New("registry: subscriber not active")
+	ErrURIMismatch = errors.New("registry: subscriber URI does not match registry")
+)
+
+// Checker verifies that the bap_id/bpp_id of a request is an active
+// subscriber for its domain and city, at the URI it claims.
+type Checker struct {
+	client Client
+	mode   string
+}
+
+// NewChecker creates a checker on client. ONDC_SUBSCRIBER_CHECK selects the
+// mode: off (default), log (report only) or enforce.
+func NewChecker(client Client) *Checker {
+	mode := os.Getenv("ONDC_SUBSCRIBER_CHECK")
+	switch mode {
+	case "", "off":
+		mode = "off"
+	case "log", "enforce":
+	default:
+		logging.Subsystem("registry").Warn("unknown ONDC_SUBSCRIBER_CHECK, using enforce", "mode", mode)
+		mode = "enforce"
+	}
+	return &Checker{client: client, mode: mode}
+}
+
+// Mode returns the configured check mode.
+func (c *Checker) Mode() string {
+	if c == nil {
+		return "off"
+	}
+	return c.mode
+}
+
+// Check looks up subscriberID as subscriberType for domain and city and
+// compares its subscriber_url with uri. It returns nil when the subscriber
+// is valid or the check is not enforced.
+func (c *Checker) Check(ctx context.Context, subscriberType, subscriberID, domain, city, uri string) error {
+	if c == nil || c.mode == "off" {
+		return nil
+	}
+	err := c.check(ctx, Query{SubscriberID: subscriberID, Type: subscriberType, Domain: domain, City: city}, uri)
+	metrics.SubscriberChecks.Inc(strings.ToLower(subscriberType), checkResult(err))
+	if err == nil {
+		return nil
+	}
+	logging.For(ctx, "registry").Warn("subscriber check failed",
+		"type", subscriberType, "subscriber_id", subscriberID, "domain", domain, "city", city, "mode", c.mode, "error", err)
+	if c.mode != "enforce" {
+		return nil
+	}
+	return err
+}
+
+func (c *Checker) check(ctx context.Context, q Query, uri string) error {
+	if c.client == nil {
+		return ErrNotFound
+	}
+	records, err := c.client.Lookup(ctx, q)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	var active []Subscriber
+	for _, rec := range records {
+		if rec.Active(now) {
+			active = append(active, rec)
+		}
+	}
+	if len(active) == 0 {
+		return ErrInactive
+	}
+	for _, rec := range active {
+		if sameURI(rec.SubscriberURL, uri) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %s", ErrURIMismatch, uri)
+}
+
+// NACK maps a Check error to the HTTP status and ONDC NACK to return:
+// 401 for unknown, inactive or mismatching subscribers, 503 when the
+// registry could not be reached.
+func NACK(err error) (int, model.AckResponse) {
+	if errors.Is(err, ErrNotFound) || errors.Is(err, ErrInactive) || errors.Is(err, ErrURIMismatch) {
+		return http.StatusUnauthorized, model.NACK("POLICY-ERROR", "10000", err.Error())
+	}
+	return http.StatusServiceUnavailable, model.NACK("CORE-ERROR", "20000", "registry unavailable: "+err.Error())
+}
+
+// sameURI compares two subscriber URLs ignoring scheme/host case and a
+// trailing slash.
+func sameURI(a, b string) bool {
+	ua, errA := url.Parse(strings.TrimSpace(a))
+	ub, errB := url.Parse(strings.TrimSpace(b))
+	if errA != nil || errB != nil {
+		return a == b
+	}
+	return strings.EqualFold(ua.Scheme, ub.Scheme) && strings.EqualFold(ua.Host, ub.Host) &&
+		strings.TrimSuffix(ua.Path, "/") == strings.TrimSuffix(ub.Path, "/")
+}
+
+func checkResult(err error) string {
+	switch {
+	case err == nil:
+		return "ok"
+	case errors.Is(err, ErrNotFound):
+		return "unknown"
+	case errors.Is(err, ErrInactive):
+		return "inactive"
+	case errors.Is(err, ErrURIMismatch):
+		return "uri_mismatch"
+	default:
+		return "error"
+	}
+}
@@ -0,0 +1,49 @@
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// File is a Client backed by a JSON file holding an array of subscriber
+// records in the registry /lookup response format, e.g.
+//
+//	[{"subscriber_id": "seller.example.com", "ukId": "k1", "signing_public_key": "...",
+//	  "status": "SUBSCRIBED", "type": "BPP", "domain": "ONDC:RET10", "city": "std:080"}]
+type File struct {
+	records []Subscriber
+}
+
+// NewFile loads the subscriber records from path.
+func NewFile(path string) (*File, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var records []Subscriber
+	if err := json.Unmarshal(data, &records); err != nil {
+		return nil, fmt.Errorf("registry: parse %s: %w", path, err)
+	}
+	return &File{records: records}, nil
+}
+
+// Lookup returns the records matching q.
+func (f *File) Lookup(ctx context.Context, q Query) ([]Subscriber, error) {
+	var out []Subscriber
+	for _, rec := range f.records {
+		if q.Matches(rec) {
+			out = append(out, rec)
+		}
+	}
+	if len(out) == 0 {
+		return nil, ErrNotFound
+	}
+	return out, nil
+}
+
+// LookupKey returns the record of subscriberID with key uniqueKeyID.
+func (f *File) LookupKey(ctx context.Context, subscriberID, uniqueKeyID string) (Subscriber, error) {
+	return lookupKey(ctx, f, subscriberID, uniqueKeyID)
+}
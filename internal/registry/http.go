@@ -0,0 +1,72 @@
+package registry
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// HTTP is a Client for the ONDC registry /lookup API. The base URL is
+// configurable so a local stub server can stand in for the registry.
+type HTTP struct {
+	baseURL string
+	client  *http.Client
+}
+
+// NewHTTP creates a registry client for baseURL (e.g. https://staging.registry.ondc.org).
+// client should sign requests (signing.Signer.Client), as the registry
+// requires signed lookups.
+func NewHTTP(baseURL string, client *http.Client) *HTTP {
+	return &HTTP{baseURL: strings.TrimSuffix(baseURL, "/"), client: client}
+}
+
+// Lookup POSTs q to {baseURL}/lookup and returns the matching records.
+func (h *HTTP) Lookup(ctx context.Context, q Query) ([]Subscriber, error) {
+	body, err := json.Marshal(q)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.baseURL+"/lookup", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("registry: lookup: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("registry: lookup: status %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+
+	var records []Subscriber
+	if err := json.NewDecoder(resp.Body).Decode(&records); err != nil {
+		return nil, fmt.Errorf("registry: decode lookup response: %w", err)
+	}
+	// Registries differ in which filters they honour; apply them here too.
+	out := records[:0]
+	for _, rec := range records {
+		if q.Matches(rec) {
+			out = append(out, rec)
+		}
+	}
+	if len(out) == 0 {
+		return nil, ErrNotFound
+	}
+	return out, nil
+}
+
+// LookupKey returns the record of subscriberID with key uniqueKeyID.
+func (h *HTTP) LookupKey(ctx context.Context, subscriberID, uniqueKeyID string) (Subscriber, error) {
+	return lookupKey(ctx, h, subscriberID, uniqueKeyID)
+}
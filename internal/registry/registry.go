@@ -0,0 +1,130 @@
+// Package registry looks up ONDC network subscribers (BAPs, BPPs, gateways)
+// and their signing keys. The edge uses it to verify request signatures and,
+// with discovery, to check that bap_id/bpp_id are active subscribers.
+package registry
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// Subscriber status values as returned by the ONDC registry.
+const (
+	StatusSubscribed = "SUBSCRIBED"
+)
+
+// ErrNotFound is returned when no subscriber matches the lookup.
+var ErrNotFound = errors.New("registry: subscriber not found")
+
+// Subscriber is one registry record: a subscriber_id with one of its signing
+// keys (unique_key_id) for a domain and city.
+type Subscriber struct {
+	SubscriberID     string    `json:"subscriber_id"`
+	UniqueKeyID      string    `json:"ukId"`
+	SubscriberURL    string    `json:"subscriber_url"`
+	Type             string    `json:"type"` // BAP, BPP, BG
+	Domain           string    `json:"domain"`
+	City             string    `json:"city"`
+	Country          string    `json:"country"`
+	SigningPublicKey string    `json:"signing_public_key"` // base64 ed25519 (raw 32 bytes or DER)
+	EncrPublicKey    string    `json:"encr_public_key,omitempty"`
+	Status           string    `json:"status"`
+	ValidFrom        time.Time `json:"valid_from"`
+	ValidUntil       time.Time `json:"valid_until"`
+}
+
+// Active reports whether the record is subscribed and within its validity window.
+func (s Subscriber) Active(now time.Time) bool {
+	if s.Status != StatusSubscribed {
+		return false
+	}
+	if !s.ValidFrom.IsZero() && now.Before(s.ValidFrom) {
+		return false
+	}
+	if !s.ValidUntil.IsZero() && now.After(s.ValidUntil) {
+		return false
+	}
+	return true
+}
+
+// Query filters a registry lookup; empty fields match everything.
+type Query struct {
+	SubscriberID string `json:"subscriber_id,omitempty"`
+	UniqueKeyID  string `json:"ukId,omitempty"`
+	Type         string `json:"type,omitempty"`
+	Domain       string `json:"domain,omitempty"`
+	City         string `json:"city,omitempty"`
+	Country      string `json:"country,omitempty"`
+}
+
+// Matches reports whether s satisfies q. A record city or domain of "*"
+// matches any value.
+func (q Query) Matches(s Subscriber) bool {
+	match := func(want, have string) bool {
+		return want == "" || have == "*" || strings.EqualFold(want, have)
+	}
+	return match(q.SubscriberID, s.SubscriberID) && match(q.UniqueKeyID, s.UniqueKeyID) &&
+		match(q.Type, s.Type) && match(q.Domain, s.Domain) && match(q.City, s.City) && match(q.Country, s.Country)
+}
+
+// Client looks up subscriber records. Implementations: File (local JSON file
+// for development and tests) and HTTP (registry /lookup), usually behind Cached.
+type Client interface {
+	// Lookup returns the records matching q, or ErrNotFound if there are none.
+	Lookup(ctx context.Context, q Query) ([]Subscriber, error)
+	// LookupKey returns the record of subscriberID with key uniqueKeyID,
+	// or ErrNotFound.
+	LookupKey(ctx context.Context, subscriberID, uniqueKeyID string) (Subscriber, error)
+}
+
+// lookupKey implements LookupKey on top of Lookup.
+func lookupKey(ctx context.Context, c Client, subscriberID, uniqueKeyID string) (Subscriber, error) {
+	q := Query{SubscriberID: subscriberID, UniqueKeyID: uniqueKeyID}
+	records, err := c.Lookup(ctx, q)
+	if err != nil {
+		return Subscriber{}, err
+	}
+	for _, rec := range records {
+		if q.Matches(rec) {
+			return rec, nil
+		}
+	}
+	return Subscriber{}, ErrNotFound
+}
+
+// FromEnv returns the configured registry client, or nil if none is
+// configured. httpClient is used for the remote registry and should sign
+// requests.
+//
+// Configuration (env):
+//   - ONDC_REGISTRY_URL: registry base URL; lookups go to {url}/lookup
+//   - ONDC_REGISTRY_CACHE_TTL / ONDC_REGISTRY_NEGATIVE_TTL: cache lifetime of
+//     found (default 10m) and not-found (default 1m) lookups
+//   - ONDC_REGISTRY_FILE: without a URL, a local JSON file (default ./config/registry.json)
+func FromEnv(httpClient *http.Client) (Client, error) {
+	if base := os.Getenv("ONDC_REGISTRY_URL"); base != "" {
+		return NewCached(NewHTTP(base, httpClient),
+			getenvDuration("ONDC_REGISTRY_CACHE_TTL", 10*time.Minute),
+			getenvDuration("ONDC_REGISTRY_NEGATIVE_TTL", time.Minute)), nil
+	}
+	path := os.Getenv("ONDC_REGISTRY_FILE")
+	if path == "" {
+		path = "./config/registry.json"
+	}
+	f, err := NewFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func getenvDuration(key string, def time.Duration) time.Duration {
+	if d, err := time.ParseDuration(os.Getenv(key)); err == nil && d > 0 {
+		return d
+	}
+	return def
+}
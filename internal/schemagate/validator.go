@@ -2,10 +2,11 @@ package schemagate
 
 import (
 	"context"
-	"log"
 	"sync"
 
-	"gcr-backend/internal/bloom"
+	"gcr-backend/internal/itemhash"
+	"gcr-backend/internal/logging"
+	"gcr-backend/internal/metrics"
 	"gcr-backend/internal/model"
 )
 
@@ -52,17 +53,68 @@ func ValidateItem(ctx context.Context, item model.Item) (valid bool, rejectReaso
 	return true, ""
 }
 
+// minDescLen is the shortest item long_desc that does not raise a quality warning.
+const minDescLen = 20
+
+// itemQualityChecks is the number of soft checks CheckItemQuality runs per item.
+const itemQualityChecks = 3
+
+// CheckProviderQuality performs provider-level soft checks on an already valid provider.
+// Warnings never cause rejection; they only lower the catalog quality score.
+func CheckProviderQuality(ctx context.Context, provider model.Provider) []Warning {
+	warnings := []Warning{}
+	if len(provider.Descriptor.Images) == 0 {
+		warnings = append(warnings, Warning{
+			Scope:  "provider:" + provider.ID,
+			Reason: "provider.descriptor.images missing",
+		})
+	}
+	return warnings
+}
+
+// CheckItemQuality performs item-level soft checks on an already valid item.
+// Warnings never cause rejection; they only lower the catalog quality score.
+func CheckItemQuality(ctx context.Context, item model.Item, providerID string) []Warning {
+	warnings := []Warning{}
+	scope := "item:" + providerID + ":" + item.ID
+	if len(item.Descriptor.Images) == 0 {
+		warnings = append(warnings, Warning{Scope: scope, Reason: "item.descriptor.images missing"})
+	}
+	if item.Descriptor.ShortDesc == "" {
+		warnings = append(warnings, Warning{Scope: scope, Reason: "item.descriptor.short_desc missing"})
+	}
+	if len(item.Descriptor.LongDesc) < minDescLen {
+		warnings = append(warnings, Warning{Scope: scope, Reason: "item.descriptor.long_desc too short"})
+	}
+	return warnings
+}
+
+// QualityScore returns the share of soft checks that passed, in [0, 1].
+// A provider always contributes one check, plus itemQualityChecks per accepted item.
+func QualityScore(itemCount, warningCount int) float64 {
+	checks := 1 + itemCount*itemQualityChecks
+	if warningCount >= checks {
+		return 0
+	}
+	return float64(checks-warningCount) / float64(checks)
+}
+
 // ProcessCatalog validates all providers and items with parallel processing.
 // - Provider-level: if invalid, discard entire provider
 // - Item-level: if invalid, discard only that item
-// - Item deduplication: use Bloom filter to skip duplicate items
-func ProcessCatalog(ctx context.Context, env *model.OnSearchEnvelope) (validProviders []model.Provider, rejections []Rejection) {
+// - Change detection: count new, changed and unchanged items per provider;
+//   accepted providers keep all their schema-valid items, since the curated
+//   record is read as the complete provider
+// - Quality: accepted providers get a QualityReport, keyed by provider ID
+func ProcessCatalog(ctx context.Context, env *model.OnSearchEnvelope) (validProviders []model.Provider, rejections []Rejection, quality map[string]QualityReport, changes Changes) {
 	validProviders = []model.Provider{}
 	rejections = []Rejection{}
+	quality = map[string]QualityReport{}
+	changes = Changes{}
 
 	providers := env.Message.Catalog.BPPProviders
 	if len(providers) == 0 {
-		return validProviders, rejections
+		return validProviders, rejections, quality, changes
 	}
 
 	// Parallel processing configuration
@@ -104,20 +156,33 @@ func ProcessCatalog(ctx context.Context, env *model.OnSearchEnvelope) (validProv
 
 	// Collect results
 	for result := range results {
+		for _, rej := range result.rejections {
+			metrics.SchemaGateRejections.Inc(rej.Reason)
+		}
 		if result.valid {
+			metrics.SchemaGateProviders.Inc("accepted")
 			validProviders = append(validProviders, result.provider)
+			changes[result.provider.ID] = result.changes
+			quality[result.provider.ID] = QualityReport{
+				ProviderID: result.provider.ID,
+				Score:      QualityScore(len(result.provider.Items), len(result.warnings)),
+				Warnings:   result.warnings,
+			}
 		} else {
+			metrics.SchemaGateProviders.Inc("rejected")
 			rejections = append(rejections, result.rejections...)
 		}
 	}
 
-	return validProviders, rejections
+	return validProviders, rejections, quality, changes
 }
 
 type providerResult struct {
 	provider  model.Provider
 	valid     bool
 	rejections []Rejection
+	warnings  []Warning
+	changes   itemhash.Counts
 }
 
 // processProvider validates a single provider and its items with parallel processing
@@ -137,6 +202,8 @@ func processProvider(ctx context.Context, provider model.Provider, ctxMeta model
 		}
 	}
 
+	providerWarnings := CheckProviderQuality(ctx, provider)
+
 	// Step 2: Process items in parallel (if provider has items)
 	if len(provider.Items) == 0 {
 		// Provider is valid but has no items - still accept it
@@ -144,6 +211,7 @@ func processProvider(ctx context.Context, provider model.Provider, ctxMeta model
 			provider:  provider,
 			valid:     true,
 			rejections: []Rejection{},
+			warnings:  providerWarnings,
 		}
 	}
 
@@ -160,12 +228,16 @@ func processProvider(ctx context.Context, provider model.Provider, ctxMeta model
 		provider:  provider,
 		valid:     true,
 		rejections: rejections,
+		warnings:  append(providerWarnings, validItems.warnings...),
+		changes:   validItems.changes,
 	}
 }
 
 type itemsResult struct {
-	items     []model.Item
+	items     []model.Item // all schema-valid items, changed or not
 	rejections []Rejection
+	warnings  []Warning
+	changes   itemhash.Counts
 }
 
 // processItemsParallel processes items in parallel batches for optimal performance
@@ -174,6 +246,7 @@ func processItemsParallel(ctx context.Context, items []model.Item, ctxMeta model
 		return itemsResult{
 			items:     []model.Item{},
 			rejections: []Rejection{},
+			warnings:  []Warning{},
 		}
 	}
 
@@ -222,21 +295,29 @@ func processItemsParallel(ctx context.Context, items []model.Item, ctxMeta model
 	// Collect results
 	allValidItems := []model.Item{}
 	allRejections := []Rejection{}
+	allWarnings := []Warning{}
+	var allChanges itemhash.Counts
 	for result := range results {
 		allValidItems = append(allValidItems, result.items...)
 		allRejections = append(allRejections, result.rejections...)
+		allWarnings = append(allWarnings, result.warnings...)
+		allChanges.Add(result.changes)
 	}
 
 	return itemsResult{
 		items:     allValidItems,
 		rejections: allRejections,
+		warnings:  allWarnings,
+		changes:   allChanges,
 	}
 }
 
-// processItemBatch processes a batch of items with validation and deduplication
+// processItemBatch processes a batch of items with validation and change detection
 func processItemBatch(ctx context.Context, items []model.Item, ctxMeta model.OnSearchContext, providerID string) itemsResult {
 	validItems := []model.Item{}
 	rejections := []Rejection{}
+	warnings := []Warning{}
+	var changes itemhash.Counts
 
 	for _, item := range items {
 		// Step 1: Validate item schema
@@ -246,26 +327,35 @@ func processItemBatch(ctx context.Context, items []model.Item, ctxMeta model.OnS
 				Scope:  "item:" + providerID + ":" + item.ID,
 				Reason: reason,
 			})
-			log.Printf("SchemaGate: rejected item %s in provider %s: %s", item.ID, providerID, reason)
-			continue
-		}
-
-		// Step 2: Check for duplicates using Bloom filter
-		// Item key format: "domain:city:provider_id:item_id"
-		itemKey := ctxMeta.Domain + ":" + ctxMeta.City + ":" + providerID + ":" + item.ID
-		if bloom.SeenItem(ctx, itemKey) {
-			// Item is a duplicate, skip it but don't reject (it's already in DB)
-			log.Printf("SchemaGate: duplicate item %s in provider %s, skipping", item.ID, providerID)
+			// Per-item: sampled so a bad catalog cannot flood the logs.
+			logging.Sampled(logging.For(ctx, "schemagate")).Info("rejected item",
+				"item_id", item.ID, logging.FieldProviderID, providerID, "reason", reason)
 			continue
 		}
 
-		// Item is valid and not a duplicate
+		warnings = append(warnings, CheckItemQuality(ctx, item, providerID)...)
 		validItems = append(validItems, item)
 	}
 
+	// Step 2: Change detection against the per-item content hash store.
+	// Every valid item is kept (the curated record is the full provider);
+	// the counts decide whether the provider changed at all.
+	for _, status := range itemhash.Compare(ctx, ctxMeta.Domain, ctxMeta.City, providerID, validItems) {
+		switch status {
+		case itemhash.Unchanged:
+			changes.Unchanged++
+		case itemhash.Changed:
+			changes.Changed++
+		default:
+			changes.New++
+		}
+	}
+
 	return itemsResult{
 		items:     validItems,
 		rejections: rejections,
+		warnings:  warnings,
+		changes:   changes,
 	}
 }
 
@@ -274,3 +364,36 @@ type Rejection struct {
 	Scope  string `json:"scope"`  // e.g., "provider:10020084" or "item:12345"
 	Reason string `json:"reason"` // e.g., "provider.descriptor.name missing"
 }
+
+// Warning records a quality issue on an accepted scope (provider/item).
+// Unlike a Rejection, the scope is still written to the curated store.
+type Warning struct {
+	Scope  string `json:"scope"`  // e.g., "item:10020084:12345"
+	Reason string `json:"reason"` // e.g., "item.descriptor.images missing"
+}
+
+// Changes holds the item change counts of each accepted provider.
+type Changes map[string]itemhash.Counts
+
+// Total sums the counts over all providers.
+func (c Changes) Total() itemhash.Counts {
+	var total itemhash.Counts
+	for _, counts := range c {
+		total.Add(counts)
+	}
+	return total
+}
+
+// Modified reports whether providerID has new or changed items. A provider
+// without compared items counts as modified, since nothing shows otherwise.
+func (c Changes) Modified(providerID string) bool {
+	counts := c[providerID]
+	return counts.New+counts.Changed > 0 || counts.Unchanged == 0
+}
+
+// QualityReport summarises the soft checks for one accepted provider.
+type QualityReport struct {
+	ProviderID string    `json:"provider_id"`
+	Score      float64   `json:"score"` // share of passed checks, 0..1
+	Warnings   []Warning `json:"warnings"`
+}
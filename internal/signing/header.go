@@ -0,0 +1,148 @@
+// Package signing implements ONDC request signatures: an ed25519 signature
+// over a BLAKE2b-512 digest of the body, carried in the Authorization header.
+//
+//	Authorization: Signature keyId="{subscriber_id}|{unique_key_id}|ed25519",
+//	  algorithm="ed25519",created="1606970629",expires="1607030629",
+//	  headers="(created) (expires) digest",signature="{base64 signature}"
+//
+// The signed string is
+//
+//	(created): 1606970629
+//	(expires): 1607030629
+//	digest: BLAKE-512={base64 BLAKE2b-512 of the body}
+package signing
+
+import (
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/blake2b"
+)
+
+// Algorithm is the only signature algorithm used on the ONDC network.
+const Algorithm = "ed25519"
+
+// signedHeaders is the headers parameter of every ONDC signature.
+const signedHeaders = "(created) (expires) digest"
+
+// Verification errors (also used as NACK reasons).
+var (
+	ErrMissing    = errors.New("signing: Authorization header missing")
+	ErrMalformed  = errors.New("signing: malformed Authorization header")
+	ErrExpired    = errors.New("signing: signature expired or not yet valid")
+	ErrUnknownKey = errors.New("signing: unknown subscriber key")
+	ErrInactive   = errors.New("signing: subscriber not active")
+	ErrInvalid    = errors.New("signing: signature does not match")
+)
+
+// Params are the parsed fields of a Signature Authorization header.
+type Params struct {
+	SubscriberID string
+	UniqueKeyID  string
+	Algorithm    string
+	Created      int64
+	Expires      int64
+	Headers      string
+	Signature    []byte
+}
+
+// ParseHeader parses a `Signature keyId="...",...` header value.
+func ParseHeader(value string) (Params, error) {
+	rest, ok := strings.CutPrefix(strings.TrimSpace(value), "Signature ")
+	if !ok {
+		return Params{}, ErrMalformed
+	}
+
+	fields := map[string]string{}
+	for _, part := range strings.Split(rest, ",") {
+		key, val, found := strings.Cut(strings.TrimSpace(part), "=")
+		if !found {
+			return Params{}, ErrMalformed
+		}
+		fields[key] = strings.Trim(val, `"`)
+	}
+
+	keyID := strings.Split(fields["keyId"], "|")
+	if len(keyID) != 3 || keyID[0] == "" || keyID[1] == "" {
+		return Params{}, fmt.Errorf("%w: keyId", ErrMalformed)
+	}
+	p := Params{
+		SubscriberID: keyID[0],
+		UniqueKeyID:  keyID[1],
+		Algorithm:    fields["algorithm"],
+		Headers:      fields["headers"],
+	}
+	if p.Algorithm != Algorithm || keyID[2] != Algorithm {
+		return Params{}, fmt.Errorf("%w: algorithm must be %s", ErrMalformed, Algorithm)
+	}
+	if p.Headers != signedHeaders {
+		return Params{}, fmt.Errorf("%w: headers must be %q", ErrMalformed, signedHeaders)
+	}
+
+	var err error
+	if p.Created, err = strconv.ParseInt(fields["created"], 10, 64); err != nil {
+		return Params{}, fmt.Errorf("%w: created", ErrMalformed)
+	}
+	if p.Expires, err = strconv.ParseInt(fields["expires"], 10, 64); err != nil {
+		return Params{}, fmt.Errorf("%w: expires", ErrMalformed)
+	}
+	if p.Signature, err = base64.StdEncoding.DecodeString(fields["signature"]); err != nil || len(p.Signature) != ed25519.SignatureSize {
+		return Params{}, fmt.Errorf("%w: signature", ErrMalformed)
+	}
+	return p, nil
+}
+
+// String formats p as an Authorization header value.
+func (p Params) String() string {
+	return fmt.Sprintf(`Signature keyId="%s|%s|%s",algorithm="%s",created="%d",expires="%d",headers="%s",signature="%s"`,
+		p.SubscriberID, p.UniqueKeyID, Algorithm, Algorithm, p.Created, p.Expires, signedHeaders,
+		base64.StdEncoding.EncodeToString(p.Signature))
+}
+
+// Digest returns the base64 BLAKE2b-512 digest of body.
+func Digest(body []byte) string {
+	sum := blake2b.Sum512(body)
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// SigningString builds the string that is signed for created/expires and
+// the body digest.
+func SigningString(created, expires int64, digest string) string {
+	return fmt.Sprintf("(created): %d\n(expires): %d\ndigest: BLAKE-512=%s", created, expires, digest)
+}
+
+// checkWindow verifies that now (± skew) lies within [created, expires].
+func (p Params) checkWindow(now time.Time, skew time.Duration) error {
+	created, expires := time.Unix(p.Created, 0), time.Unix(p.Expires, 0)
+	if expires.Before(created) || now.Add(skew).Before(created) || now.Add(-skew).After(expires) {
+		return ErrExpired
+	}
+	return nil
+}
+
+// ParsePublicKey decodes a base64 ed25519 public key, either the raw 32 bytes
+// or the DER (SubjectPublicKeyInfo) form published by some registries.
+func ParsePublicKey(b64 string) (ed25519.PublicKey, error) {
+	der, err := base64.StdEncoding.DecodeString(b64)
+	if err != nil {
+		return nil, err
+	}
+	if len(der) == ed25519.PublicKeySize {
+		return ed25519.PublicKey(der), nil
+	}
+	key, err := x509.ParsePKIXPublicKey(der)
+	if err != nil {
+		return nil, err
+	}
+	pub, ok := key.(ed25519.PublicKey)
+	if !ok {
+		return nil, errors.New("signing: not an ed25519 public key")
+	}
+	return pub, nil
+}
@@ -0,0 +1,261 @@
+package signing
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"gcr-backend/internal/logging"
+)
+
+// HeaderGatewayAuthorization carries the signature of a gateway that
+// forwards or serves catalogs on behalf of sellers.
+const HeaderGatewayAuthorization = "X-Gateway-Authorization"
+
+// Key is one of our signing keys as registered under unique_key_id.
+type Key struct {
+	ID         string             `json:"ukId"`
+	PrivateKey ed25519.PrivateKey `json:"-"`
+	ValidFrom  time.Time          `json:"valid_from"`
+	ValidUntil time.Time          `json:"valid_until"`
+}
+
+func (k Key) active(now time.Time) bool {
+	return (k.ValidFrom.IsZero() || !now.Before(k.ValidFrom)) && (k.ValidUntil.IsZero() || now.Before(k.ValidUntil))
+}
+
+// Signer signs outbound requests and responses as subscriberID. Several keys
+// may be active during a rotation; the one with the latest valid_from is used,
+// so a new key takes over at its valid_from while the old one is still registered.
+type Signer struct {
+	subscriberID string
+	keys         []Key
+	ttl          time.Duration
+}
+
+// NewSigner creates a signer; signatures expire ttl after creation.
+func NewSigner(subscriberID string, keys []Key, ttl time.Duration) (*Signer, error) {
+	if subscriberID == "" {
+		return nil, errors.New("signing: subscriber_id required")
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("signing: no signing keys")
+	}
+	return &Signer{subscriberID: subscriberID, keys: keys, ttl: ttl}, nil
+}
+
+// LoadSigner builds the signer from the environment. It returns nil, nil
+// when no keys are configured (outbound messages stay unsigned).
+//
+// Configuration (env):
+//   - ONDC_SUBSCRIBER_ID: our subscriber_id (default gcr)
+//   - ONDC_SIGNING_KEYS_FILE: JSON array of {"ukId", "private_key", "valid_from", "valid_until"}
+//   - ONDC_SIGNING_KEYS: alternatively "ukId=base64key,..." (first listed is preferred)
+//   - ONDC_SIGNATURE_TTL: created → expires window (default 5m)
+//
+// Private keys are base64 ed25519 keys: the 64-byte private key, its 32-byte
+// seed, or PKCS#8 DER.
+func LoadSigner() (*Signer, error) {
+	var keys []Key
+	if path := os.Getenv("ONDC_SIGNING_KEYS_FILE"); path != "" {
+		fileKeys, err := loadKeysFile(path)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, fileKeys...)
+	}
+	if list := os.Getenv("ONDC_SIGNING_KEYS"); list != "" {
+		for _, pair := range strings.Split(list, ",") {
+			id, b64, ok := strings.Cut(strings.TrimSpace(pair), "=")
+			if !ok {
+				return nil, fmt.Errorf("signing: ONDC_SIGNING_KEYS entry %q is not ukId=key", id)
+			}
+			priv, err := ParsePrivateKey(b64)
+			if err != nil {
+				return nil, fmt.Errorf("signing: key %s: %w", id, err)
+			}
+			keys = append(keys, Key{ID: id, PrivateKey: priv})
+		}
+	}
+	if len(keys) == 0 {
+		return nil, nil
+	}
+
+	ttl, err := time.ParseDuration(getenv("ONDC_SIGNATURE_TTL", "5m"))
+	if err != nil || ttl <= 0 {
+		ttl = 5 * time.Minute
+	}
+	return NewSigner(getenv("ONDC_SUBSCRIBER_ID", "gcr"), keys, ttl)
+}
+
+func loadKeysFile(path string) ([]Key, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var records []struct {
+		Key
+		PrivateKey string `json:"private_key"`
+	}
+	if err := json.Unmarshal(data, &records); err != nil {
+		return nil, fmt.Errorf("signing: parse %s: %w", path, err)
+	}
+	keys := make([]Key, 0, len(records))
+	for _, rec := range records {
+		priv, err := ParsePrivateKey(rec.PrivateKey)
+		if err != nil {
+			return nil, fmt.Errorf("signing: key %s: %w", rec.ID, err)
+		}
+		rec.Key.PrivateKey = priv
+		keys = append(keys, rec.Key)
+	}
+	return keys, nil
+}
+
+// ParsePrivateKey decodes a base64 ed25519 private key (64 bytes, 32-byte
+// seed, or PKCS#8 DER).
+func ParsePrivateKey(b64 string) (ed25519.PrivateKey, error) {
+	der, err := base64.StdEncoding.DecodeString(strings.TrimSpace(b64))
+	if err != nil {
+		return nil, err
+	}
+	switch len(der) {
+	case ed25519.PrivateKeySize:
+		return ed25519.PrivateKey(der), nil
+	case ed25519.SeedSize:
+		return ed25519.NewKeyFromSeed(der), nil
+	}
+	key, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		return nil, err
+	}
+	priv, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, errors.New("signing: not an ed25519 private key")
+	}
+	return priv, nil
+}
+
+// activeKey returns the key to sign with at now.
+func (s *Signer) activeKey(now time.Time) (Key, error) {
+	var best Key
+	found := false
+	for _, k := range s.keys {
+		if k.active(now) && (!found || k.ValidFrom.After(best.ValidFrom)) {
+			best, found = k, true
+		}
+	}
+	if !found {
+		return Key{}, errors.New("signing: no signing key valid now")
+	}
+	return best, nil
+}
+
+// Sign returns the Authorization header value for body.
+func (s *Signer) Sign(body []byte) (string, error) {
+	now := time.Now()
+	key, err := s.activeKey(now)
+	if err != nil {
+		return "", err
+	}
+	p := Params{
+		SubscriberID: s.subscriberID,
+		UniqueKeyID:  key.ID,
+		Created:      now.Unix(),
+		Expires:      now.Add(s.ttl).Unix(),
+	}
+	p.Signature = ed25519.Sign(key.PrivateKey, []byte(SigningString(p.Created, p.Expires, Digest(body))))
+	return p.String(), nil
+}
+
+// Transport wraps base (nil means http.DefaultTransport) so that every
+// request carries our Authorization header.
+func (s *Signer) Transport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	if s == nil {
+		return base
+	}
+	return &signingTransport{signer: s, base: base}
+}
+
+// Client returns an HTTP client for calls to other network participants
+// (registry, buyer and seller apps) that signs every request.
+func (s *Signer) Client(timeout time.Duration) *http.Client {
+	return &http.Client{Timeout: timeout, Transport: s.Transport(nil)}
+}
+
+type signingTransport struct {
+	signer *Signer
+	base   http.RoundTripper
+}
+
+func (t *signingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	auth, err := t.signer.Sign(body)
+	if err != nil {
+		return nil, err
+	}
+
+	// RoundTrippers must not modify the caller's request.
+	out := req.Clone(req.Context())
+	out.Body = io.NopCloser(bytes.NewReader(body))
+	out.ContentLength = int64(len(body))
+	out.Header.Set("Authorization", auth)
+	return t.base.RoundTrip(out)
+}
+
+// Middleware signs the responses of next (on_search payloads served to buyer
+// apps): the body is buffered, then sent with Authorization and, as we serve
+// catalogs in the gateway role, X-Gateway-Authorization. A nil signer leaves
+// responses unsigned.
+func (s *Signer) Middleware(next http.Handler) http.Handler {
+	if s == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rec := &bufferedResponse{header: http.Header{}, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+
+		for k, v := range rec.header {
+			w.Header()[k] = v
+		}
+		if auth, err := s.Sign(rec.body.Bytes()); err != nil {
+			logging.For(r.Context(), "signing").Error("response left unsigned", "error", err)
+		} else {
+			w.Header().Set("Authorization", auth)
+			w.Header().Set(HeaderGatewayAuthorization, auth)
+		}
+		w.WriteHeader(rec.status)
+		_, _ = rec.body.WriteTo(w)
+	})
+}
+
+// bufferedResponse captures a handler's response so it can be signed.
+type bufferedResponse struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (b *bufferedResponse) Header() http.Header         { return b.header }
+func (b *bufferedResponse) WriteHeader(status int)      { b.status = status }
+func (b *bufferedResponse) Write(p []byte) (int, error) { return b.body.Write(p) }
@@ -0,0 +1,253 @@
+package signing
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	"golang.org/x/crypto/blake2b"
+
+	"gcr-backend/internal/logging"
+	"gcr-backend/internal/metrics"
+	"gcr-backend/internal/model"
+	"gcr-backend/internal/registry"
+)
+
+// Verification modes (ONDC_AUTH_MODE).
+const (
+	// ModeOff accepts every request without looking at the signature.
+	ModeOff = "off"
+	// ModeLog verifies and logs failures, but still accepts the request.
+	ModeLog = "log"
+	// ModeEnforce rejects unsigned or invalid requests with a 401 NACK.
+	ModeEnforce = "enforce"
+)
+
+// bodyMemoryLimit is how much of a request body is buffered in memory for
+// the digest; larger bodies (bulk catalogs) are spooled to a temp file.
+const bodyMemoryLimit = 8 << 20
+
+// Verifier checks the ONDC Authorization header of incoming requests against
+// the signing keys in the registry.
+type Verifier struct {
+	registry registry.Client
+	mode     string
+	skew     time.Duration
+	realm    string
+}
+
+// NewVerifier creates a verifier on reg (nil means every key is unknown).
+//
+// Configuration (env):
+//   - ONDC_AUTH_MODE: off (default), log or enforce
+//   - ONDC_SIGNATURE_SKEW: allowed clock skew for created/expires (default 30s)
+//   - ONDC_SUBSCRIBER_ID: our subscriber_id, sent as realm in WWW-Authenticate
+func NewVerifier(reg registry.Client) *Verifier {
+	mode := getenv("ONDC_AUTH_MODE", ModeOff)
+	switch mode {
+	case ModeOff, ModeLog, ModeEnforce:
+	default:
+		logging.Subsystem("signing").Warn("unknown ONDC_AUTH_MODE, using enforce", "mode", mode)
+		mode = ModeEnforce
+	}
+	skew, err := time.ParseDuration(getenv("ONDC_SIGNATURE_SKEW", "30s"))
+	if err != nil {
+		skew = 30 * time.Second
+	}
+	return &Verifier{
+		registry: reg,
+		mode:     mode,
+		skew:     skew,
+		realm:    getenv("ONDC_SUBSCRIBER_ID", "gcr"),
+	}
+}
+
+// Mode returns the configured verification mode.
+func (v *Verifier) Mode() string {
+	return v.mode
+}
+
+// Verify checks header against body and returns the signing subscriber.
+func (v *Verifier) Verify(ctx context.Context, header string, body []byte) (registry.Subscriber, error) {
+	p, sub, err := v.verifyHeader(ctx, header, time.Now())
+	if err != nil {
+		return registry.Subscriber{}, err
+	}
+	return sub, v.verifySignature(p, sub, Digest(body))
+}
+
+// verifyHeader parses header and resolves the signing key; everything that
+// can be rejected without reading the body.
+func (v *Verifier) verifyHeader(ctx context.Context, header string, now time.Time) (Params, registry.Subscriber, error) {
+	if header == "" {
+		return Params{}, registry.Subscriber{}, ErrMissing
+	}
+	p, err := ParseHeader(header)
+	if err != nil {
+		return Params{}, registry.Subscriber{}, err
+	}
+	if err := p.checkWindow(now, v.skew); err != nil {
+		return Params{}, registry.Subscriber{}, err
+	}
+	if v.registry == nil {
+		return Params{}, registry.Subscriber{}, ErrUnknownKey
+	}
+	sub, err := v.registry.LookupKey(ctx, p.SubscriberID, p.UniqueKeyID)
+	if errors.Is(err, registry.ErrNotFound) {
+		return Params{}, registry.Subscriber{}, ErrUnknownKey
+	}
+	if err != nil {
+		return Params{}, registry.Subscriber{}, fmt.Errorf("registry lookup: %w", err)
+	}
+	if !sub.Active(now) {
+		return Params{}, registry.Subscriber{}, ErrInactive
+	}
+	return p, sub, nil
+}
+
+func (v *Verifier) verifySignature(p Params, sub registry.Subscriber, digest string) error {
+	key, err := ParsePublicKey(sub.SigningPublicKey)
+	if err != nil {
+		return fmt.Errorf("%w: registry key: %v", ErrUnknownKey, err)
+	}
+	if !ed25519.Verify(key, []byte(SigningString(p.Created, p.Expires, digest)), p.Signature) {
+		return ErrInvalid
+	}
+	return nil
+}
+
+// Middleware verifies the Authorization header of every request. The body is
+// buffered (or spooled to disk when large) for the digest and handed to next
+// unchanged. On success the signing subscriber is available via SubscriberFrom.
+func (v *Verifier) Middleware(next http.Handler) http.Handler {
+	if v == nil || v.mode == ModeOff {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		logger := logging.For(ctx, "signing")
+
+		p, sub, err := v.verifyHeader(ctx, r.Header.Get("Authorization"), time.Now())
+		if err == nil {
+			var digest string
+			var body io.ReadCloser
+			digest, body, err = bufferBody(r.Body)
+			if err != nil {
+				http.Error(w, "failed to read body", http.StatusBadRequest)
+				return
+			}
+			defer body.Close()
+			r.Body = body
+			err = v.verifySignature(p, sub, digest)
+		}
+		metrics.SignatureVerifications.Inc(resultOf(err))
+
+		if err != nil {
+			logger.Warn("signature verification failed", "mode", v.mode, "error", err)
+			if v.mode == ModeEnforce {
+				v.unauthorized(w, err)
+				return
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		ctx = logging.With(context.WithValue(ctx, subscriberKey{}, sub), "subscriber_id", sub.SubscriberID)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// unauthorized writes the ONDC 401 response: a WWW-Authenticate challenge
+// naming the expected headers and a NACK body.
+func (v *Verifier) unauthorized(w http.ResponseWriter, err error) {
+	w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Signature realm="%s",headers="%s"`, v.realm, signedHeaders))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusUnauthorized)
+	_ = json.NewEncoder(w).Encode(model.NACK("POLICY-ERROR", "10001", "Invalid Signature: "+err.Error()))
+}
+
+func resultOf(err error) string {
+	switch {
+	case err == nil:
+		return "ok"
+	case errors.Is(err, ErrMissing):
+		return "missing"
+	case errors.Is(err, ErrMalformed):
+		return "malformed"
+	case errors.Is(err, ErrExpired):
+		return "expired"
+	case errors.Is(err, ErrUnknownKey):
+		return "unknown_key"
+	case errors.Is(err, ErrInactive):
+		return "inactive"
+	default:
+		return "invalid"
+	}
+}
+
+type subscriberKey struct{}
+
+// SubscriberFrom returns the verified signer of the request, if any.
+func SubscriberFrom(ctx context.Context) (registry.Subscriber, bool) {
+	sub, ok := ctx.Value(subscriberKey{}).(registry.Subscriber)
+	return sub, ok
+}
+
+// bufferBody reads body to the end, returning its digest and a reader over
+// the same bytes. Bodies beyond bodyMemoryLimit continue in a temp file that
+// is removed on Close.
+func bufferBody(body io.Reader) (string, io.ReadCloser, error) {
+	h, _ := blake2b.New512(nil)
+	var buf bytes.Buffer
+	_, err := io.CopyN(io.MultiWriter(h, &buf), body, bodyMemoryLimit+1)
+	if errors.Is(err, io.EOF) {
+		return base64.StdEncoding.EncodeToString(h.Sum(nil)), io.NopCloser(&buf), nil
+	}
+	if err != nil {
+		return "", nil, err
+	}
+
+	f, err := os.CreateTemp("", "gcr-signed-body-*")
+	if err != nil {
+		return "", nil, err
+	}
+	spool := &tempFile{f}
+	if _, err := buf.WriteTo(f); err != nil {
+		spool.Close()
+		return "", nil, err
+	}
+	if _, err := io.Copy(io.MultiWriter(h, f), body); err != nil {
+		spool.Close()
+		return "", nil, err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		spool.Close()
+		return "", nil, err
+	}
+	return base64.StdEncoding.EncodeToString(h.Sum(nil)), spool, nil
+}
+
+type tempFile struct {
+	*os.File
+}
+
+func (t *tempFile) Close() error {
+	err := t.File.Close()
+	os.Remove(t.Name())
+	return err
+}
+
+func getenv(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
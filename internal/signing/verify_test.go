@@ -0,0 +1,80 @@
+package signing
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gcr-backend/internal/registry"
+)
+
+func TestVerifyFileRegistry(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	records, _ := json.Marshal([]registry.Subscriber{{
+		SubscriberID:     "seller.example.com",
+		UniqueKeyID:      "k1",
+		Type:             "BPP",
+		Domain:           "ONDC:RET10",
+		City:             "std:080",
+		SigningPublicKey: base64.StdEncoding.EncodeToString(pub),
+		Status:           registry.StatusSubscribed,
+	}})
+	path := filepath.Join(t.TempDir(), "registry.json")
+	if err := os.WriteFile(path, records, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	reg, err := registry.NewFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("ONDC_AUTH_MODE", ModeEnforce)
+	t.Setenv("ONDC_SIGNATURE_SKEW", "30s")
+	v := NewVerifier(reg)
+
+	body := []byte(`{"context":{"bpp_id":"seller.example.com"}}`)
+	sign := func(ukID string, created time.Time, ttl time.Duration, signed []byte) string {
+		p := Params{
+			SubscriberID: "seller.example.com",
+			UniqueKeyID:  ukID,
+			Created:      created.Unix(),
+			Expires:      created.Add(ttl).Unix(),
+		}
+		p.Signature = ed25519.Sign(priv, []byte(SigningString(p.Created, p.Expires, Digest(signed))))
+		return p.String()
+	}
+	now := time.Now()
+
+	tests := []struct {
+		name   string
+		header string
+		body   []byte
+		want   error
+	}{
+		{"valid", sign("k1", now, 5*time.Minute, body), body, nil},
+		{"tampered body", sign("k1", now, 5*time.Minute, body), []byte(`{"context":{"bpp_id":"other.example.com"}}`), ErrInvalid},
+		{"expired", sign("k1", now.Add(-time.Hour), 5*time.Minute, body), body, ErrExpired},
+		{"not yet valid", sign("k1", now.Add(time.Hour), 5*time.Minute, body), body, ErrExpired},
+		{"unknown ukId", sign("k2", now, 5*time.Minute, body), body, ErrUnknownKey},
+		{"missing", "", body, ErrMissing},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sub, err := v.Verify(context.Background(), tt.header, tt.body)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("Verify() error = %v, want %v", err, tt.want)
+			}
+			if tt.want == nil && sub.SubscriberID != "seller.example.com" {
+				t.Fatalf("Verify() subscriber = %q, want seller.example.com", sub.SubscriberID)
+			}
+		})
+	}
+}
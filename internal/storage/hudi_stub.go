@@ -9,15 +9,20 @@ import (
 	"time"
 
 	"gcr-backend/internal/model"
+	"gcr-backend/internal/schemagate"
 )
 
-// WriteProviderCatalog writes curated provider data in a format ready for Apache Hudi ingestion.
-// This is a Phase-1 stub that writes JSONL files. In production, a Spark/Hudi job would:
+// WriteCuratedProvider writes a SchemaGate-accepted provider together with its
+// quality report, so warnings and the quality score live alongside the curated
+// record. SchemaGate is the only writer: every record is validated and scored.
+//
+// This is a Phase-1 stub that writes JSONL files in a format ready for Apache
+// Hudi ingestion. In production, a Spark/Hudi job would:
 // 1. Read these JSONL files (or from MinIO/S3)
 // 2. Convert to Parquet format
 // 3. Write to Hudi MoR (Merge-on-Read) tables with upsert semantics
 // 4. Trino can then query these Hudi tables via SQL (SELECT * FROM hudi.default.providers)
-func WriteProviderCatalog(_ context.Context, ctxMeta model.OnSearchContext, provider model.Provider) error {
+func WriteCuratedProvider(_ context.Context, ctxMeta model.OnSearchContext, provider model.Provider, quality schemagate.QualityReport) error {
 	// Hudi preparation: Write JSONL files that Spark/Hudi will ingest into MoR tables.
 	// Hudi MoR tables support upserts, time travel queries, and incremental processing.
 	dir := "./data/hudi/providers"
@@ -33,15 +38,17 @@ func WriteProviderCatalog(_ context.Context, ctxMeta model.OnSearchContext, prov
 	defer f.Close()
 
 	record := map[string]any{
-		"provider_id": provider.ID,
-		"domain":      ctxMeta.Domain,
-		"city":        ctxMeta.City,
-		"bap_id":      ctxMeta.BapID,
-		"bpp_id":      ctxMeta.BppID,
-		"timestamp":   time.Now().UTC().Format(time.RFC3339Nano),
-		"descriptor":  provider.Descriptor,
-		"categories":  provider.Categories,
-		"items":       provider.Items, // Include filtered items (only valid, non-duplicate items)
+		"provider_id":   provider.ID,
+		"domain":        ctxMeta.Domain,
+		"city":          ctxMeta.City,
+		"bap_id":        ctxMeta.BapID,
+		"bpp_id":        ctxMeta.BppID,
+		"timestamp":     time.Now().UTC().Format(time.RFC3339Nano),
+		"descriptor":    provider.Descriptor,
+		"categories":    provider.Categories,
+		"items":         provider.Items, // All schema-valid items: each record is the complete provider
+		"quality_score": quality.Score,
+		"warnings":      quality.Warnings,
 	}
 
 	data, err := json.Marshal(record)
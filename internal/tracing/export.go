@@ -0,0 +1,307 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Exporter sends ended spans to a tracing backend.
+type Exporter interface {
+	ExportSpans(ctx context.Context, spans []SpanData) error
+	Shutdown(ctx context.Context) error
+}
+
+type nopExporter struct{}
+
+func (nopExporter) ExportSpans(context.Context, []SpanData) error { return nil }
+func (nopExporter) Shutdown(context.Context) error                { return nil }
+
+// batchProcessor buffers spans and exports them every few seconds or when
+// a batch is full. Spans are dropped (and counted) if the buffer overflows,
+// so tracing never blocks the pipeline.
+//
+// The queue is never closed: spans still end in handlers and consumers
+// while the process shuts down, and a send on a closed channel panics.
+// After shutdown, enqueue drops spans instead.
+type batchProcessor struct {
+	exp      Exporter
+	queue    chan SpanData
+	stop     chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+	stopped  atomic.Bool
+	dropped  atomic.Int64
+}
+
+const (
+	batchQueueSize = 4096
+	batchSize      = 512
+	batchInterval  = 5 * time.Second
+)
+
+func newBatchProcessor(exp Exporter) *batchProcessor {
+	b := &batchProcessor{
+		exp:   exp,
+		queue: make(chan SpanData, batchQueueSize),
+		stop:  make(chan struct{}),
+		done:  make(chan struct{}),
+	}
+	go b.run()
+	return b
+}
+
+func (b *batchProcessor) enqueue(span SpanData) {
+	if b.stopped.Load() {
+		return
+	}
+	select {
+	case b.queue <- span:
+	default:
+		if b.dropped.Add(1)%1000 == 1 {
+			slog.Warn("span buffer full", "subsystem", "tracing", "dropped", b.dropped.Load())
+		}
+	}
+}
+
+func (b *batchProcessor) run() {
+	defer close(b.done)
+	ticker := time.NewTicker(batchInterval)
+	defer ticker.Stop()
+
+	batch := make([]SpanData, 0, batchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		if err := b.exp.ExportSpans(ctx, batch); err != nil {
+			slog.Warn("export spans failed", "subsystem", "tracing", "spans", len(batch), "error", err)
+		}
+		cancel()
+		batch = batch[:0]
+	}
+
+	for {
+		select {
+		case span := <-b.queue:
+			batch = append(batch, span)
+			if len(batch) >= batchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		case <-b.stop:
+			// Export what was queued before shutdown, then stop.
+			for {
+				select {
+				case span := <-b.queue:
+					batch = append(batch, span)
+					if len(batch) >= batchSize {
+						flush()
+					}
+				default:
+					flush()
+					return
+				}
+			}
+		}
+	}
+}
+
+func (b *batchProcessor) shutdown(ctx context.Context) {
+	b.stopOnce.Do(func() {
+		b.stopped.Store(true)
+		close(b.stop)
+	})
+	select {
+	case <-b.done:
+	case <-ctx.Done():
+	}
+}
+
+// WriterExporter writes one JSON object per span, for stdout or a local file.
+type WriterExporter struct {
+	mu     sync.Mutex
+	w      io.Writer
+	closer io.Closer // set for files opened by NewFileExporter
+}
+
+// NewWriterExporter returns an exporter writing JSON lines to w (e.g. os.Stdout).
+func NewWriterExporter(w io.Writer) *WriterExporter {
+	return &WriterExporter{w: w}
+}
+
+// NewFileExporter returns an exporter appending JSON lines to path.
+func NewFileExporter(path string) (*WriterExporter, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return &WriterExporter{w: f, closer: f}, nil
+}
+
+// ExportSpans implements Exporter.
+func (e *WriterExporter) ExportSpans(_ context.Context, spans []SpanData) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	enc := json.NewEncoder(e.w)
+	for _, s := range spans {
+		rec := map[string]any{
+			"trace_id":    s.TraceID.String(),
+			"span_id":     s.SpanID.String(),
+			"name":        s.Name,
+			"kind":        s.Kind.String(),
+			"start":       s.Start.UTC().Format(time.RFC3339Nano),
+			"duration_ms": float64(s.End.Sub(s.Start).Microseconds()) / 1000,
+		}
+		if s.ParentID != (SpanID{}) {
+			rec["parent_id"] = s.ParentID.String()
+		}
+		if len(s.Attributes) > 0 {
+			rec["attributes"] = s.Attributes
+		}
+		if s.Err != nil {
+			rec["error"] = s.Err.Error()
+		}
+		if err := enc.Encode(rec); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Shutdown implements Exporter; it closes the file of a file exporter.
+func (e *WriterExporter) Shutdown(context.Context) error {
+	if e.closer != nil {
+		return e.closer.Close()
+	}
+	return nil
+}
+
+func (k SpanKind) String() string {
+	switch k {
+	case KindServer:
+		return "server"
+	case KindClient:
+		return "client"
+	case KindProducer:
+		return "producer"
+	case KindConsumer:
+		return "consumer"
+	default:
+		return "internal"
+	}
+}
+
+// OTLPExporter posts spans to an OpenTelemetry collector using OTLP/HTTP
+// with JSON encoding (POST {endpoint}/v1/traces).
+type OTLPExporter struct {
+	url     string
+	service string
+	client  *http.Client
+}
+
+// NewOTLPExporter returns an exporter for the collector at endpoint
+// (e.g. http://otel-collector:4318).
+func NewOTLPExporter(endpoint, service string) *OTLPExporter {
+	return &OTLPExporter{
+		url:     strings.TrimRight(endpoint, "/") + "/v1/traces",
+		service: service,
+		client:  &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// ExportSpans implements Exporter.
+func (e *OTLPExporter) ExportSpans(ctx context.Context, spans []SpanData) error {
+	otlpSpans := make([]map[string]any, len(spans))
+	for i, s := range spans {
+		span := map[string]any{
+			"traceId":           s.TraceID.String(),
+			"spanId":            s.SpanID.String(),
+			"name":              s.Name,
+			"kind":              int(s.Kind),
+			"startTimeUnixNano": strconv.FormatInt(s.Start.UnixNano(), 10),
+			"endTimeUnixNano":   strconv.FormatInt(s.End.UnixNano(), 10),
+			"attributes":        otlpAttributes(s.Attributes),
+		}
+		if s.ParentID != (SpanID{}) {
+			span["parentSpanId"] = s.ParentID.String()
+		}
+		if s.Err != nil {
+			span["status"] = map[string]any{"code": 2, "message": s.Err.Error()} // STATUS_CODE_ERROR
+		}
+		otlpSpans[i] = span
+	}
+
+	body, err := json.Marshal(map[string]any{
+		"resourceSpans": []any{map[string]any{
+			"resource": map[string]any{
+				"attributes": otlpAttributes(map[string]any{"service.name": e.service}),
+			},
+			"scopeSpans": []any{map[string]any{
+				"scope": map[string]any{"name": "gcr-backend/internal/tracing"},
+				"spans": otlpSpans,
+			}},
+		}},
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("tracing: OTLP collector returned %s", resp.Status)
+	}
+	return nil
+}
+
+// Shutdown implements Exporter.
+func (e *OTLPExporter) Shutdown(context.Context) error {
+	e.client.CloseIdleConnections()
+	return nil
+}
+
+// otlpAttributes converts attributes to OTLP KeyValue objects.
+func otlpAttributes(attrs map[string]any) []any {
+	out := make([]any, 0, len(attrs))
+	for k, v := range attrs {
+		var value map[string]any
+		switch x := v.(type) {
+		case string:
+			value = map[string]any{"stringValue": x}
+		case bool:
+			value = map[string]any{"boolValue": x}
+		case int:
+			value = map[string]any{"intValue": strconv.Itoa(x)}
+		case int64:
+			value = map[string]any{"intValue": strconv.FormatInt(x, 10)}
+		case float64:
+			value = map[string]any{"doubleValue": x}
+		default:
+			value = map[string]any{"stringValue": fmt.Sprint(x)}
+		}
+		out = append(out, map[string]any{"key": k, "value": value})
+	}
+	return out
+}
@@ -0,0 +1,118 @@
+package tracing
+
+import (
+	"context"
+	"encoding/hex"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// W3C trace context header names (HTTP and Kafka).
+const (
+	HeaderTraceParent = "traceparent"
+	HeaderTraceState  = "tracestate"
+)
+
+// TraceParent formats sc as a version 00 traceparent value.
+func (sc SpanContext) TraceParent() string {
+	flags := "00"
+	if sc.Sampled {
+		flags = "01"
+	}
+	return "00-" + sc.TraceID.String() + "-" + sc.SpanID.String() + "-" + flags
+}
+
+// ParseTraceParent parses a traceparent value (and optional tracestate).
+// It returns an invalid SpanContext if traceparent is malformed.
+func ParseTraceParent(traceparent, tracestate string) SpanContext {
+	parts := strings.Split(strings.TrimSpace(traceparent), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" ||
+		len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return SpanContext{}
+	}
+	if parts[0] == "00" && len(parts) != 4 {
+		return SpanContext{}
+	}
+
+	var sc SpanContext
+	if _, err := hex.Decode(sc.TraceID[:], []byte(parts[1])); err != nil {
+		return SpanContext{}
+	}
+	if _, err := hex.Decode(sc.SpanID[:], []byte(parts[2])); err != nil {
+		return SpanContext{}
+	}
+	flags, err := hex.DecodeString(parts[3])
+	if err != nil {
+		return SpanContext{}
+	}
+	sc.Sampled = flags[0]&0x01 == 1
+	sc.TraceState = tracestate
+	return sc
+}
+
+// Inject returns the traceparent/tracestate of the current span in ctx
+// ("" if there is none).
+func Inject(ctx context.Context) (traceparent, tracestate string) {
+	sc := SpanContextFrom(ctx)
+	if !sc.IsValid() {
+		return "", ""
+	}
+	return sc.TraceParent(), sc.TraceState
+}
+
+// Extract returns ctx with the remote parent from traceparent/tracestate.
+func Extract(ctx context.Context, traceparent, tracestate string) context.Context {
+	return ContextWithRemote(ctx, ParseTraceParent(traceparent, tracestate))
+}
+
+// statusRecorder captures the response status for the server span.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (s *statusRecorder) WriteHeader(code int) {
+	s.status = code
+	s.ResponseWriter.WriteHeader(code)
+}
+
+// Unwrap lets http.ResponseController reach Flush on streamed responses.
+func (s *statusRecorder) Unwrap() http.ResponseWriter {
+	return s.ResponseWriter
+}
+
+// Middleware starts a server span per request, continuing an incoming
+// traceparent, and returns the span's traceparent to the caller.
+// gorilla/mux: the span is named after the matched route template.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := r.URL.Path
+		if route := mux.CurrentRoute(r); route != nil {
+			if tpl, err := route.GetPathTemplate(); err == nil {
+				name = tpl
+			}
+		}
+
+		ctx := Extract(r.Context(), r.Header.Get(HeaderTraceParent), r.Header.Get(HeaderTraceState))
+		ctx, span := Start(ctx, r.Method+" "+name, KindServer)
+		defer span.End()
+		span.SetAttr("http.method", r.Method)
+		span.SetAttr("http.route", name)
+
+		if tp, _ := Inject(ctx); tp != "" {
+			w.Header().Set(HeaderTraceParent, tp)
+		}
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r.WithContext(ctx))
+		span.SetAttr("http.status_code", rec.status)
+		if rec.status >= http.StatusInternalServerError {
+			span.RecordError(errStatus(rec.status))
+		}
+	})
+}
+
+type errStatus int
+
+func (e errStatus) Error() string { return http.StatusText(int(e)) }
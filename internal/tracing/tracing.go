@@ -0,0 +1,272 @@
+// Package tracing is a small OpenTelemetry-compatible tracer: spans with
+// W3C trace context (traceparent/tracestate) propagated over HTTP and Kafka
+// headers, batched to a pluggable Exporter (OTLP/HTTP JSON, or JSON lines on
+// stdout/a file for local runs).
+package tracing
+
+import (
+	"context"
+	"encoding/hex"
+	"log/slog"
+	"math/rand/v2"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// SpanKind follows the OpenTelemetry span kinds (OTLP enum values).
+type SpanKind int
+
+const (
+	KindInternal SpanKind = 1
+	KindServer   SpanKind = 2
+	KindClient   SpanKind = 3
+	KindProducer SpanKind = 4
+	KindConsumer SpanKind = 5
+)
+
+// TraceID and SpanID are the W3C trace context identifiers.
+type (
+	TraceID [16]byte
+	SpanID  [8]byte
+)
+
+func (t TraceID) String() string { return hex.EncodeToString(t[:]) }
+func (s SpanID) String() string  { return hex.EncodeToString(s[:]) }
+
+// SpanContext identifies a span across process boundaries.
+type SpanContext struct {
+	TraceID    TraceID
+	SpanID     SpanID
+	Sampled    bool
+	TraceState string
+}
+
+// IsValid reports whether sc carries non-zero IDs.
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID != TraceID{} && sc.SpanID != SpanID{}
+}
+
+// Span is one timed operation. A nil *Span is valid and does nothing, so
+// callers never need to check whether tracing is enabled.
+type Span struct {
+	name   string
+	kind   SpanKind
+	sc     SpanContext
+	parent SpanID
+	start  time.Time
+
+	mu    sync.Mutex
+	attrs map[string]any
+	err   error
+	ended bool
+}
+
+// SpanData is an ended span as handed to exporters.
+type SpanData struct {
+	Name       string
+	Kind       SpanKind
+	TraceID    TraceID
+	SpanID     SpanID
+	ParentID   SpanID
+	Start      time.Time
+	End        time.Time
+	Attributes map[string]any
+	Err        error
+}
+
+type spanKey struct{}
+type remoteKey struct{}
+
+var (
+	exporter    Exporter = nopExporter{}
+	batcher     *batchProcessor
+	sampleRatio = 1.0
+	serviceName = "gcr-api"
+	once        sync.Once
+)
+
+// Init configures the exporter from the environment. It is safe to call
+// multiple times.
+//
+// Configuration (env):
+//   - TRACING_EXPORTER: "none" (default), "stdout", "file" or "otlp"
+//   - TRACING_FILE: JSON lines output for the file exporter (default ./data/traces.jsonl)
+//   - OTEL_EXPORTER_OTLP_ENDPOINT: OTLP/HTTP collector (default http://localhost:4318)
+//   - OTEL_SERVICE_NAME: service.name resource attribute (default gcr-api)
+//   - TRACING_SAMPLE_RATIO: share of new traces that are recorded (default 1)
+func Init() {
+	once.Do(func() {
+		serviceName = getenv("OTEL_SERVICE_NAME", serviceName)
+		if v, err := strconv.ParseFloat(os.Getenv("TRACING_SAMPLE_RATIO"), 64); err == nil && v >= 0 && v <= 1 {
+			sampleRatio = v
+		}
+
+		var exp Exporter
+		switch name := getenv("TRACING_EXPORTER", "none"); name {
+		case "stdout":
+			exp = NewWriterExporter(os.Stdout)
+		case "file":
+			path := getenv("TRACING_FILE", "./data/traces.jsonl")
+			fe, err := NewFileExporter(path)
+			if err != nil {
+				// slog default (configured by internal/logging, which imports this package).
+				slog.Error("tracing disabled: open trace file", "subsystem", "tracing", "path", path, "error", err)
+				return
+			}
+			exp = fe
+		case "otlp":
+			exp = NewOTLPExporter(getenv("OTEL_EXPORTER_OTLP_ENDPOINT", "http://localhost:4318"), serviceName)
+		case "none", "":
+			return
+		default:
+			slog.Error("tracing disabled: unknown TRACING_EXPORTER", "subsystem", "tracing", "exporter", name)
+			return
+		}
+		SetExporter(exp)
+		slog.Info("exporting spans", "subsystem", "tracing", "exporter", getenv("TRACING_EXPORTER", ""), "sample_ratio", sampleRatio)
+	})
+}
+
+// SetExporter installs exp behind a batch processor (e.g. in tests).
+func SetExporter(exp Exporter) {
+	exporter = exp
+	batcher = newBatchProcessor(exp)
+}
+
+// Shutdown flushes buffered spans and closes the exporter.
+func Shutdown(ctx context.Context) error {
+	if batcher == nil {
+		return nil
+	}
+	batcher.shutdown(ctx)
+	return exporter.Shutdown(ctx)
+}
+
+func getenv(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
+
+// Start starts a span as a child of the span (or remote span context) in
+// ctx, or as a new trace root. The span must be ended with End.
+func Start(ctx context.Context, name string, kind SpanKind) (context.Context, *Span) {
+	if batcher == nil {
+		// Tracing disabled: still propagate an incoming context unchanged.
+		return ctx, nil
+	}
+
+	parent := SpanContextFrom(ctx)
+	sc := SpanContext{SpanID: newSpanID()}
+	if parent.IsValid() {
+		sc.TraceID = parent.TraceID
+		sc.Sampled = parent.Sampled
+		sc.TraceState = parent.TraceState
+	} else {
+		sc.TraceID = newTraceID()
+		sc.Sampled = rand.Float64() < sampleRatio
+	}
+
+	span := &Span{name: name, kind: kind, sc: sc, parent: parent.SpanID, start: time.Now()}
+	return context.WithValue(ctx, spanKey{}, span), span
+}
+
+// SpanContextFrom returns the current span context in ctx: the active span,
+// or a remote parent set with ContextWithRemote.
+func SpanContextFrom(ctx context.Context) SpanContext {
+	if span, ok := ctx.Value(spanKey{}).(*Span); ok && span != nil {
+		return span.sc
+	}
+	if sc, ok := ctx.Value(remoteKey{}).(SpanContext); ok {
+		return sc
+	}
+	return SpanContext{}
+}
+
+// ContextWithRemote sets sc (e.g. parsed from a traceparent header) as the
+// parent for spans started from the returned context.
+func ContextWithRemote(ctx context.Context, sc SpanContext) context.Context {
+	if !sc.IsValid() {
+		return ctx
+	}
+	return context.WithValue(ctx, remoteKey{}, sc)
+}
+
+// SetAttr records an attribute (string, bool, int, int64 or float64).
+func (s *Span) SetAttr(key string, value any) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.attrs == nil {
+		s.attrs = map[string]any{}
+	}
+	s.attrs[key] = value
+}
+
+// RecordError marks the span as failed; a nil err is ignored.
+func (s *Span) RecordError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.err = err
+}
+
+// End finishes the span and queues it for export if it is sampled.
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	data := SpanData{
+		Name:       s.name,
+		Kind:       s.kind,
+		TraceID:    s.sc.TraceID,
+		SpanID:     s.sc.SpanID,
+		ParentID:   s.parent,
+		Start:      s.start,
+		End:        time.Now(),
+		Attributes: s.attrs,
+		Err:        s.err,
+	}
+	s.mu.Unlock()
+
+	if s.sc.Sampled && batcher != nil {
+		batcher.enqueue(data)
+	}
+}
+
+func newTraceID() TraceID {
+	var id TraceID
+	for id == (TraceID{}) {
+		putUint64(id[:8], rand.Uint64())
+		putUint64(id[8:], rand.Uint64())
+	}
+	return id
+}
+
+func newSpanID() SpanID {
+	var id SpanID
+	for id == (SpanID{}) {
+		putUint64(id[:], rand.Uint64())
+	}
+	return id
+}
+
+func putUint64(b []byte, v uint64) {
+	for i := 7; i >= 0; i-- {
+		b[i] = byte(v)
+		v >>= 8
+	}
+}
@@ -3,37 +3,74 @@ package trino
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
 	"net/http"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/gorilla/mux"
+
+	"gcr-backend/internal/auth"
+	"gcr-backend/internal/logging"
 )
 
 // Service provides Trino query API
 type Service struct {
-	client *Client
+	client  *Client
+	jobs    *Jobs
+	maxRows int
+	// session properties callers may set per request (TRINO_SESSION_PROPERTIES_ALLOWED)
+	sessionAllowed map[string]bool
 }
 
-// NewService creates a new Trino service
+// NewService creates a new Trino service. Raw queries return at most
+// TRINO_MAX_ROWS rows (default 10000).
 func NewService() *Service {
+	maxRows, err := strconv.Atoi(getEnv("TRINO_MAX_ROWS", "10000"))
+	if err != nil || maxRows <= 0 {
+		maxRows = 10000
+	}
+	client := NewClient()
+	jobs, err := NewJobs(client)
+	if err != nil {
+		logging.Subsystem("trino").Error("query jobs disabled", "error", err)
+	}
+	allowed := map[string]bool{}
+	for _, name := range strings.Split(getEnv("TRINO_SESSION_PROPERTIES_ALLOWED", ""), ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			allowed[name] = true
+		}
+	}
 	return &Service{
-		client: NewClient(),
+		client:         client,
+		jobs:           jobs,
+		maxRows:        maxRows,
+		sessionAllowed: allowed,
 	}
 }
 
-// RegisterRoutes registers Trino API routes
-func (s *Service) RegisterRoutes(r *mux.Router) {
+// Client returns the service's Trino client.
+func (s *Service) Client() *Client { return s.client }
+
+// RegisterRoutes registers Trino API routes. All of them need the reader
+// role; raw SQL needs analyst. The catalog endpoints (/providers, /items,
+// /stats) are served by catalogquery.
+func (s *Service) RegisterRoutes(r *mux.Router, authn *auth.Authenticator) {
 	api := r.PathPrefix("/api/trino").Subrouter()
+	api.Use(authn.Require(auth.RoleReader))
 	api.HandleFunc("/health", s.HealthCheck).Methods("GET")
-	api.HandleFunc("/query", s.ExecuteQuery).Methods("POST")
-	api.HandleFunc("/providers", s.GetProviders).Methods("GET")
-	api.HandleFunc("/providers/{provider_id}", s.GetProvider).Methods("GET")
-	api.HandleFunc("/items", s.GetItems).Methods("GET")
-	api.HandleFunc("/stats", s.GetStats).Methods("GET")
+	api.Handle("/query", authn.Require(auth.RoleAnalyst)(http.HandlerFunc(s.ExecuteQuery))).Methods("POST")
+
+	// Asynchronous jobs for long-running queries (raw SQL, so analysts only).
+	jobs := api.PathPrefix("/jobs").Subrouter()
+	jobs.Use(authn.Require(auth.RoleAnalyst))
+	jobs.HandleFunc("", s.SubmitJob).Methods("POST")
+	jobs.HandleFunc("/{job_id}", s.GetJob).Methods("GET")
+	jobs.HandleFunc("/{job_id}", s.CancelJob).Methods("DELETE")
+	jobs.HandleFunc("/{job_id}/results", s.GetJobResults).Methods("GET")
+	jobs.HandleFunc("/{job_id}/download", s.DownloadJob).Methods("GET")
 }
 
 // HealthCheckResponse represents health check response
@@ -62,9 +99,24 @@ func (s *Service) HealthCheck(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-// QueryRequest represents a SQL query request
+// QueryRequest represents a SQL query request. MaxRows lowers the row
+// limit below the configured maximum; the embedded Options override the
+// configured catalog, schema and source and add client tags and session
+// properties (only those in TRINO_SESSION_PROPERTIES_ALLOWED).
 type QueryRequest struct {
-	SQL string `json:"sql"`
+	SQL     string `json:"sql"`
+	MaxRows int    `json:"max_rows,omitempty"`
+	Options
+}
+
+// checkOptions rejects session properties callers may not set.
+func (s *Service) checkOptions(opts Options) error {
+	for name := range opts.Session {
+		if !s.sessionAllowed[name] {
+			return fmt.Errorf("session property %q may not be set per request", name)
+		}
+	}
+	return nil
 }
 
 // QueryResponse represents a query response
@@ -96,12 +148,37 @@ func (s *Service) ExecuteQuery(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	auth.Audit(r.Context(), "trino_query", "sql", req.SQL)
+
+	maxRows := s.maxRows
+	if req.MaxRows > 0 && req.MaxRows < maxRows {
+		maxRows = req.MaxRows
+	}
+	sql, err := ReadOnly(req.SQL, maxRows)
+	if err == nil {
+		err = s.checkOptions(req.Options)
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(QueryResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
 	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
 	defer cancel()
 
-	result, err := s.client.ExecuteQuery(ctx, req.SQL)
+	// NDJSON/CSV stream rows as Trino produces them; JSON buffers the result.
+	if format := queryFormat(r); format != FormatJSON {
+		s.streamQuery(ctx, w, s.client.NewSession(req.Options), sql, format)
+		return
+	}
+
+	result, err := s.client.NewSession(req.Options).ExecuteQuery(ctx, sql)
 	if err != nil {
-		log.Printf("Trino query error: %v", err)
+		logging.For(ctx, "trino").Error("query failed", "error", err)
 		w.WriteHeader(http.StatusInternalServerError)
 		json.NewEncoder(w).Encode(QueryResponse{
 			Success: false,
@@ -129,194 +206,174 @@ func (s *Service) ExecuteQuery(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-// GetProviders returns all providers
-func (s *Service) GetProviders(w http.ResponseWriter, r *http.Request) {
-	limit := 100
-	if l := r.URL.Query().Get("limit"); l != "" {
-		if parsed, err := strconv.Atoi(l); err == nil && parsed > 0 && parsed <= 1000 {
-			limit = parsed
-		}
-	}
+// queryError answers a query that could not be built or run with 500.
+func (s *Service) queryError(w http.ResponseWriter, ctx context.Context, err error) {
+	logging.For(ctx, "trino").Error("query failed", "error", err)
+	w.WriteHeader(http.StatusInternalServerError)
+	json.NewEncoder(w).Encode(QueryResponse{
+		Success: false,
+		Error:   err.Error(),
+	})
+}
 
-	offset := 0
-	if o := r.URL.Query().Get("offset"); o != "" {
-		if parsed, err := strconv.Atoi(o); err == nil && parsed >= 0 {
-			offset = parsed
-		}
+// SubmitJob starts a query job: POST /api/trino/jobs {"sql": "..."} → 202 with the job.
+func (s *Service) SubmitJob(w http.ResponseWriter, r *http.Request) {
+	if s.jobs == nil {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]interface{}{"success": false, "error": "query jobs are not available"})
+		return
+	}
+	var req QueryRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.SQL == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]interface{}{"success": false, "error": "Invalid request: sql is required"})
+		return
+	}
+	auth.Audit(r.Context(), "trino_job", "sql", req.SQL)
+	if err := s.checkOptions(req.Options); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]interface{}{"success": false, "error": err.Error()})
+		return
 	}
 
-	sql := fmt.Sprintf(`
-		SELECT 
-			provider_id,
-			domain,
-			city,
-			bpp_id,
-			timestamp,
-			descriptor->>'name' as provider_name,
-			json_array_length(items) as items_count
-		FROM hudi.default.providers
-		ORDER BY timestamp DESC
-		LIMIT %d OFFSET %d
-	`, limit, offset)
-
-	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
-	defer cancel()
-
-	data, err := s.client.Query(ctx, sql)
+	p, _ := auth.PrincipalFrom(r.Context())
+	status, err := s.jobs.Submit(r.Context(), p.Subject, req.SQL, req.Options)
 	if err != nil {
-		log.Printf("Trino query error: %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(QueryResponse{
-			Success: false,
-			Error:   err.Error(),
-		})
+		s.jobError(w, r.Context(), err)
 		return
 	}
-
-	json.NewEncoder(w).Encode(QueryResponse{
-		Success: true,
-		Data:    data,
-	})
+	w.Header().Set("Location", "/api/trino/jobs/"+status.ID)
+	writeJSON(w, http.StatusAccepted, map[string]interface{}{"success": true, "data": status})
 }
 
-// GetProvider returns a specific provider by ID
-func (s *Service) GetProvider(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	providerID := vars["provider_id"]
-
-	sql := fmt.Sprintf(`
-		SELECT *
-		FROM hudi.default.providers
-		WHERE provider_id = '%s'
-		ORDER BY timestamp DESC
-		LIMIT 1
-	`, providerID)
-
-	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
-	defer cancel()
-
-	data, err := s.client.Query(ctx, sql)
-	if err != nil {
-		log.Printf("Trino query error: %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(QueryResponse{
-			Success: false,
-			Error:   err.Error(),
-		})
+// GetJob returns a job's state and Trino progress: GET /api/trino/jobs/{job_id}.
+func (s *Service) GetJob(w http.ResponseWriter, r *http.Request) {
+	if s.jobs == nil {
+		s.jobError(w, r.Context(), ErrJobNotFound)
 		return
 	}
-
-	if len(data) == 0 {
-		w.WriteHeader(http.StatusNotFound)
-		json.NewEncoder(w).Encode(QueryResponse{
-			Success: false,
-			Error:   "Provider not found",
-		})
+	p, _ := auth.PrincipalFrom(r.Context())
+	status, err := s.jobs.Get(mux.Vars(r)["job_id"], p.Subject, p.Has(auth.RoleAdmin))
+	if err != nil {
+		s.jobError(w, r.Context(), err)
 		return
 	}
-
-	json.NewEncoder(w).Encode(QueryResponse{
-		Success: true,
-		Data:    data,
-	})
+	writeJSON(w, http.StatusOK, map[string]interface{}{"success": true, "data": status})
 }
 
-// GetItems returns items with optional filters
-func (s *Service) GetItems(w http.ResponseWriter, r *http.Request) {
-	limit := 100
-	if l := r.URL.Query().Get("limit"); l != "" {
-		if parsed, err := strconv.Atoi(l); err == nil && parsed > 0 && parsed <= 1000 {
-			limit = parsed
+// GetJobResults returns one page of a job's rows: GET /api/trino/jobs/{job_id}/results?page=0.
+func (s *Service) GetJobResults(w http.ResponseWriter, r *http.Request) {
+	if s.jobs == nil {
+		s.jobError(w, r.Context(), ErrJobNotFound)
+		return
+	}
+	page := 0
+	if v := r.URL.Query().Get("page"); v != "" {
+		parsed, err := strconv.Atoi(v)
+		if err != nil || parsed < 0 {
+			writeJSON(w, http.StatusBadRequest, map[string]interface{}{"success": false, "error": "page must be a non-negative integer"})
+			return
 		}
+		page = parsed
 	}
 
-	providerID := r.URL.Query().Get("provider_id")
-	categoryID := r.URL.Query().Get("category_id")
-	city := r.URL.Query().Get("city")
-
-	// Build WHERE clause
-	where := []string{}
-	if providerID != "" {
-		where = append(where, fmt.Sprintf("provider_id = '%s'", providerID))
+	p, _ := auth.PrincipalFrom(r.Context())
+	status, rows, err := s.jobs.Page(mux.Vars(r)["job_id"], p.Subject, p.Has(auth.RoleAdmin), page)
+	if err != nil {
+		s.jobError(w, r.Context(), err)
+		return
 	}
-	if categoryID != "" {
-		where = append(where, fmt.Sprintf("json_extract_scalar(item, '$.category_id') = '%s'", categoryID))
+	data := make([]map[string]interface{}, 0, len(rows))
+	for _, row := range rows {
+		rowMap := make(map[string]interface{}, len(status.Columns))
+		for i, col := range status.Columns {
+			if i < len(row) {
+				rowMap[col.Name] = row[i]
+			}
+		}
+		data = append(data, rowMap)
 	}
-	if city != "" {
-		where = append(where, fmt.Sprintf("city = '%s'", city))
+	resp := map[string]interface{}{
+		"success": true,
+		"data":    data,
+		"page":    page,
+		"pages":   status.Pages,
+		"state":   status.State,
 	}
-
-	whereClause := ""
-	if len(where) > 0 {
-		whereClause = "WHERE " + strings.Join(where, " AND ")
+	if page+1 < status.Pages || !status.done() {
+		resp["next_page"] = page + 1
 	}
+	writeJSON(w, http.StatusOK, resp)
+}
 
-	sql := fmt.Sprintf(`
-		SELECT 
-			provider_id,
-			city,
-			domain,
-			json_extract_scalar(item, '$.id') as item_id,
-			json_extract_scalar(item, '$.descriptor.name') as item_name,
-			json_extract_scalar(item, '$.category_id') as category_id,
-			json_extract_scalar(item, '$.price.value') as price_value,
-			json_extract_scalar(item, '$.price.currency') as price_currency
-		FROM hudi.default.providers
-		CROSS JOIN UNNEST(json_extract(items, '$')) AS t(item)
-		%s
-		LIMIT %d
-	`, whereClause, limit)
-
-	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
-	defer cancel()
-
-	data, err := s.client.Query(ctx, sql)
+// DownloadJob streams all rows of a finished job: GET /api/trino/jobs/{job_id}/download?format=ndjson|csv.
+func (s *Service) DownloadJob(w http.ResponseWriter, r *http.Request) {
+	if s.jobs == nil {
+		s.jobError(w, r.Context(), ErrJobNotFound)
+		return
+	}
+	id := mux.Vars(r)["job_id"]
+	p, _ := auth.PrincipalFrom(r.Context())
+	admin := p.Has(auth.RoleAdmin)
+	status, err := s.jobs.Get(id, p.Subject, admin)
+	if err == nil && status.State != JobFinished {
+		err = ErrJobNotFinished
+	}
 	if err != nil {
-		log.Printf("Trino query error: %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(QueryResponse{
-			Success: false,
-			Error:   err.Error(),
-		})
+		s.jobError(w, r.Context(), err)
 		return
 	}
 
-	json.NewEncoder(w).Encode(QueryResponse{
-		Success: true,
-		Data:    data,
-	})
+	format := queryFormat(r)
+	if format == FormatCSV {
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.Header().Set("Content-Disposition", `attachment; filename="`+id+`.csv"`)
+	} else {
+		format = FormatNDJSON
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		w.Header().Set("Content-Disposition", `attachment; filename="`+id+`.ndjson"`)
+	}
+	if err := s.jobs.Download(id, p.Subject, admin, w, format); err != nil {
+		// Headers are sent; the truncated download is all we can signal.
+		logging.For(r.Context(), "trino").Error("job download failed", "job_id", id, "error", err)
+	}
 }
 
-// GetStats returns statistics about the data
-func (s *Service) GetStats(w http.ResponseWriter, r *http.Request) {
-	sql := `
-		SELECT 
-			COUNT(DISTINCT provider_id) as total_providers,
-			COUNT(*) as total_records,
-			SUM(json_array_length(items)) as total_items,
-			COUNT(DISTINCT domain) as total_domains,
-			COUNT(DISTINCT city) as total_cities,
-			MAX(timestamp) as latest_update
-		FROM hudi.default.providers
-	`
-
-	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
-	defer cancel()
-
-	data, err := s.client.Query(ctx, sql)
+// CancelJob cancels a running job, or deletes the results of an ended one:
+// DELETE /api/trino/jobs/{job_id}.
+func (s *Service) CancelJob(w http.ResponseWriter, r *http.Request) {
+	if s.jobs == nil {
+		s.jobError(w, r.Context(), ErrJobNotFound)
+		return
+	}
+	p, _ := auth.PrincipalFrom(r.Context())
+	status, err := s.jobs.Cancel(mux.Vars(r)["job_id"], p.Subject, p.Has(auth.RoleAdmin))
 	if err != nil {
-		log.Printf("Trino query error: %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(QueryResponse{
-			Success: false,
-			Error:   err.Error(),
-		})
+		s.jobError(w, r.Context(), err)
 		return
 	}
+	auth.Audit(r.Context(), "trino_job_cancel", "job_id", status.ID)
+	writeJSON(w, http.StatusOK, map[string]interface{}{"success": true, "data": status})
+}
 
-	json.NewEncoder(w).Encode(QueryResponse{
-		Success: true,
-		Data:    data,
-	})
+// jobError maps job errors to HTTP statuses.
+func (s *Service) jobError(w http.ResponseWriter, ctx context.Context, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, ErrNotReadOnly):
+		status = http.StatusBadRequest
+	case errors.Is(err, ErrJobNotFound), errors.Is(err, ErrPageOutOfRange):
+		status = http.StatusNotFound
+	case errors.Is(err, ErrJobNotFinished), errors.Is(err, ErrPageNotReady):
+		status = http.StatusConflict
+	case errors.Is(err, ErrTooManyJobs):
+		w.Header().Set("Retry-After", "30")
+		status = http.StatusTooManyRequests
+	default:
+		logging.For(ctx, "trino").Error("query job request failed", "error", err)
+	}
+	writeJSON(w, status, map[string]interface{}{"success": false, "error": err.Error()})
 }
 
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
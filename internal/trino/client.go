@@ -2,9 +2,7 @@ package trino
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
-	"io"
 	"net/http"
 	"os"
 	"strings"
@@ -16,6 +14,7 @@ type Client struct {
 	baseURL    string
 	httpClient *http.Client
 	user       string
+	defaults   Options
 }
 
 // QueryResult represents a Trino query result
@@ -43,6 +42,20 @@ type Stats struct {
 	TotalRows       int64         `json:"totalRows"`
 	TotalBytes      int64         `json:"totalBytes"`
 	CompletedSplits int64         `json:"completedSplits"`
+
+	// Progress, as reported by Trino while the query runs.
+	Scheduled          bool    `json:"scheduled"`
+	ProgressPercentage float64 `json:"progressPercentage,omitempty"`
+	Nodes              int     `json:"nodes"`
+	TotalSplits        int64   `json:"totalSplits"`
+	QueuedSplits       int64   `json:"queuedSplits"`
+	RunningSplits      int64   `json:"runningSplits"`
+	ProcessedRows      int64   `json:"processedRows"`
+	ProcessedBytes     int64   `json:"processedBytes"`
+	ElapsedTimeMillis  int64   `json:"elapsedTimeMillis"`
+	QueuedTimeMillis   int64   `json:"queuedTimeMillis"`
+	CPUTimeMillis      int64   `json:"cpuTimeMillis"`
+	PeakMemoryBytes    int64   `json:"peakMemoryBytes"`
 }
 
 // NewClient creates a new Trino client
@@ -59,119 +72,66 @@ func NewClient() *Client {
 	
 	user := getEnv("TRINO_USER", "admin")
 
+	// Bounds each protocol request (one page), not the whole query.
+	timeout, err := time.ParseDuration(getEnv("TRINO_REQUEST_TIMEOUT", "30s"))
+	if err != nil || timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+
+	// Default catalog/schema of unqualified table names (the API queries use
+	// "providers"), source, client tags and session properties ("k=v,...").
+	defaults := Options{
+		Catalog: getEnv("TRINO_CATALOG", "hudi"),
+		Schema:  getEnv("TRINO_SCHEMA", "default"),
+		Source:  getEnv("TRINO_SOURCE", "gcr-backend"),
+		Session: parseKV(getEnv("TRINO_SESSION", "")),
+	}
+	if tags := getEnv("TRINO_CLIENT_TAGS", ""); tags != "" {
+		defaults.ClientTags = strings.Split(tags, ",")
+	}
+
 	return &Client{
-		baseURL: trinoURL,
-		user:    user,
+		baseURL:  trinoURL,
+		user:     user,
+		defaults: defaults,
 		httpClient: &http.Client{
-			Timeout: 30 * time.Second,
+			Timeout: timeout,
 		},
 	}
 }
 
-// ExecuteQuery executes a SQL query and returns results
-func (c *Client) ExecuteQuery(ctx context.Context, sql string) (*QueryResult, error) {
-	// Trino expects SQL in POST body (not query parameter)
-	queryURL := fmt.Sprintf("%s/v1/statement", c.baseURL)
-	
-	// Create request with SQL in body
-	req, err := http.NewRequestWithContext(ctx, "POST", queryURL, strings.NewReader(sql))
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
-	}
+// Defaults returns the configured query options (TRINO_CATALOG, TRINO_SCHEMA,
+// TRINO_SOURCE, TRINO_CLIENT_TAGS, TRINO_SESSION).
+func (c *Client) Defaults() Options {
+	return Options{}.merge(c.defaults)
+}
 
-	req.Header.Set("X-Trino-User", c.user)
-	req.Header.Set("X-Trino-Catalog", "memory") // Use memory catalog for now (Hudi needs setup)
-	req.Header.Set("X-Trino-Schema", "default")
-	req.Header.Set("Content-Type", "text/plain")
+// ExecuteQuery executes a SQL query with the default options and returns all
+// of its results. Use Rows for results that should not be held in memory.
+func (c *Client) ExecuteQuery(ctx context.Context, sql string) (*QueryResult, error) {
+	return c.NewSession(Options{}).ExecuteQuery(ctx, sql)
+}
 
-	// Execute query - Trino returns async response with nextUri
-	resp, err := c.httpClient.Do(req)
+// ExecuteQuery executes a SQL query in the session and returns all of its results.
+func (s *Session) ExecuteQuery(ctx context.Context, sql string) (*QueryResult, error) {
+	rows, err := s.Rows(ctx, sql)
 	if err != nil {
-		return nil, fmt.Errorf("failed to execute query: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("trino error (status %d): %s", resp.StatusCode, string(body))
-	}
-
-	// Trino returns async response - parse initial response
-	var trinoResponse struct {
-		ID      string `json:"id"`
-		NextURI string `json:"nextUri"`
-		Stats   Stats  `json:"stats"`
-		Data    []Row  `json:"data"`
-		Columns []Column `json:"columns"`
-		Error   *struct {
-			Message string `json:"message"`
-		} `json:"error"`
-	}
-
-	if err := json.NewDecoder(resp.Body).Decode(&trinoResponse); err != nil {
-		return nil, fmt.Errorf("failed to decode response: %w", err)
+		return nil, err
 	}
+	defer rows.Close()
 
-	// If there's an error, return it
-	if trinoResponse.Error != nil {
-		return nil, fmt.Errorf("trino query error: %s", trinoResponse.Error.Message)
+	var data []Row
+	for rows.Next() {
+		data = append(data, rows.Batch()...)
 	}
-
-	// Poll nextUri until query completes
-	nextURI := trinoResponse.NextURI
-	for nextURI != "" {
-		req, err := http.NewRequestWithContext(ctx, "GET", nextURI, nil)
-		if err != nil {
-			return nil, fmt.Errorf("failed to create request: %w", err)
-		}
-
-		resp, err := c.httpClient.Do(req)
-		if err != nil {
-			return nil, fmt.Errorf("failed to poll query: %w", err)
-		}
-		defer resp.Body.Close()
-
-		if resp.StatusCode != http.StatusOK {
-			body, _ := io.ReadAll(resp.Body)
-			return nil, fmt.Errorf("trino error (status %d): %s", resp.StatusCode, string(body))
-		}
-
-		var pollResponse struct {
-			NextURI string `json:"nextUri"`
-			Stats   Stats  `json:"stats"`
-			Data    []Row  `json:"data"`
-			Columns []Column `json:"columns"`
-			Error   *struct {
-				Message string `json:"message"`
-			} `json:"error"`
-		}
-
-		if err := json.NewDecoder(resp.Body).Decode(&pollResponse); err != nil {
-			return nil, fmt.Errorf("failed to decode poll response: %w", err)
-		}
-
-		// Accumulate data
-		if len(pollResponse.Data) > 0 {
-			trinoResponse.Data = append(trinoResponse.Data, pollResponse.Data...)
-		}
-		if len(pollResponse.Columns) > 0 {
-			trinoResponse.Columns = pollResponse.Columns
-		}
-		trinoResponse.Stats = pollResponse.Stats
-
-		// Check for errors
-		if pollResponse.Error != nil {
-			return nil, fmt.Errorf("trino query error: %s", pollResponse.Error.Message)
-		}
-
-		// If no nextUri, query is complete
-		nextURI = pollResponse.NextURI
+	if err := rows.Err(); err != nil {
+		return nil, err
 	}
 
 	return &QueryResult{
-		Columns: trinoResponse.Columns,
-		Data:    trinoResponse.Data,
-		Stats:   trinoResponse.Stats,
+		Columns: rows.Columns(),
+		Data:    data,
+		Stats:   rows.Stats(),
 	}, nil
 }
 
@@ -0,0 +1,431 @@
+package trino
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"gcr-backend/internal/logging"
+)
+
+// Job states.
+const (
+	JobQueued    = "QUEUED"
+	JobRunning   = "RUNNING"
+	JobFinished  = "FINISHED"
+	JobFailed    = "FAILED"
+	JobCancelled = "CANCELLED"
+)
+
+// Job errors.
+var (
+	ErrJobNotFound    = errors.New("trino: job not found")
+	ErrTooManyJobs    = errors.New("trino: too many running jobs")
+	ErrJobNotFinished = errors.New("trino: job has not finished")
+	ErrPageNotReady   = errors.New("trino: page not available yet")
+	ErrPageOutOfRange = errors.New("trino: page out of range")
+)
+
+// JobStatus is the state of an asynchronous query job as returned by the API.
+type JobStatus struct {
+	ID         string     `json:"id"`
+	State      string     `json:"state"`
+	QueryID    string     `json:"trino_query_id,omitempty"`
+	SQL        string     `json:"sql"`
+	Options    Options    `json:"options"`
+	Columns    []Column   `json:"columns,omitempty"`
+	Stats      Stats      `json:"stats"`
+	Rows       int64      `json:"rows"`
+	Pages      int        `json:"pages"` // pages ready to fetch
+	PageSize   int        `json:"page_size"`
+	Error      string     `json:"error,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+}
+
+func (s JobStatus) done() bool {
+	return s.State == JobFinished || s.State == JobFailed || s.State == JobCancelled
+}
+
+type job struct {
+	mu        sync.Mutex
+	status    JobStatus
+	owner     string
+	cancel    context.CancelFunc
+	cancelled bool
+	path      string
+	offsets   []int64 // file offset of the first row of each page
+	written   int64   // bytes of complete rows in the file
+}
+
+func (jb *job) snapshot() JobStatus {
+	jb.mu.Lock()
+	defer jb.mu.Unlock()
+	return jb.status
+}
+
+// Jobs runs long analytical queries in the background. Rows are spooled to
+// an NDJSON file per job (one JSON array per row) so they can be fetched page
+// by page while the query runs, or downloaded once it has finished. Jobs live
+// in memory: results expire ttl after the job ends and do not survive a restart.
+type Jobs struct {
+	client     *Client
+	dir        string
+	pageSize   int
+	maxRows    int
+	maxRunning int
+	timeout    time.Duration
+	ttl        time.Duration
+
+	mu      sync.Mutex
+	jobs    map[string]*job
+	running int
+}
+
+// NewJobs creates the job runner and starts its expiry loop.
+//
+// Configuration (env):
+//   - TRINO_JOBS_DIR: result spool directory (default ./data/trino-jobs)
+//   - TRINO_JOB_TTL: how long results are kept after a job ends (default 1h)
+//   - TRINO_JOB_TIMEOUT: maximum run time of a job (default 30m)
+//   - TRINO_JOB_PAGE_SIZE: rows per result page (default 1000)
+//   - TRINO_JOB_MAX_ROWS: row cap of a job (default 1000000)
+//   - TRINO_JOB_MAX_RUNNING: concurrently running jobs (default 4)
+func NewJobs(client *Client) (*Jobs, error) {
+	j := &Jobs{
+		client:     client,
+		dir:        getEnv("TRINO_JOBS_DIR", "./data/trino-jobs"),
+		pageSize:   getEnvInt("TRINO_JOB_PAGE_SIZE", 1000),
+		maxRows:    getEnvInt("TRINO_JOB_MAX_ROWS", 1000000),
+		maxRunning: getEnvInt("TRINO_JOB_MAX_RUNNING", 4),
+		timeout:    getEnvDuration("TRINO_JOB_TIMEOUT", 30*time.Minute),
+		ttl:        getEnvDuration("TRINO_JOB_TTL", time.Hour),
+		jobs:       map[string]*job{},
+	}
+	if err := os.MkdirAll(j.dir, 0o755); err != nil {
+		return nil, err
+	}
+	// Results of a previous process are unreachable (jobs are in memory).
+	stale, _ := filepath.Glob(filepath.Join(j.dir, "*.ndjson"))
+	for _, path := range stale {
+		os.Remove(path)
+	}
+	go j.expireLoop()
+	return j, nil
+}
+
+// Submit checks sql with ReadOnly and starts it with opts as a job owned by
+// owner. The job outlives ctx but keeps its logging and trace context.
+func (j *Jobs) Submit(ctx context.Context, owner, sql string, opts Options) (JobStatus, error) {
+	sql, err := ReadOnly(sql, j.maxRows)
+	if err != nil {
+		return JobStatus{}, err
+	}
+
+	j.mu.Lock()
+	if j.running >= j.maxRunning {
+		j.mu.Unlock()
+		return JobStatus{}, ErrTooManyJobs
+	}
+	j.running++
+	id := newJobID()
+	session := j.client.NewSession(opts)
+	jobCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), j.timeout)
+	jb := &job{
+		owner:  owner,
+		cancel: cancel,
+		path:   filepath.Join(j.dir, id+".ndjson"),
+		status: JobStatus{ID: id, State: JobQueued, SQL: sql, Options: session.Options(), PageSize: j.pageSize, CreatedAt: time.Now().UTC()},
+	}
+	j.jobs[id] = jb
+	j.mu.Unlock()
+
+	go j.run(logging.With(jobCtx, "job_id", id), jb, session, sql)
+	return jb.snapshot(), nil
+}
+
+// run executes the query of jb and spools its rows.
+func (j *Jobs) run(ctx context.Context, jb *job, session *Session, sql string) {
+	logger := logging.For(ctx, "trino")
+	defer func() {
+		jb.cancel()
+		j.mu.Lock()
+		j.running--
+		j.mu.Unlock()
+	}()
+
+	err := j.spool(ctx, jb, session, sql)
+
+	jb.mu.Lock()
+	defer jb.mu.Unlock()
+	now := time.Now().UTC()
+	expires := now.Add(j.ttl)
+	jb.status.FinishedAt, jb.status.ExpiresAt = &now, &expires
+	jb.status.Pages = int((jb.status.Rows + int64(j.pageSize) - 1) / int64(j.pageSize))
+	switch {
+	case jb.cancelled:
+		jb.status.State = JobCancelled
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		jb.status.State, jb.status.Error = JobFailed, fmt.Sprintf("job timed out after %s", j.timeout)
+	case err != nil:
+		jb.status.State, jb.status.Error = JobFailed, err.Error()
+	default:
+		jb.status.State = JobFinished
+	}
+	logger.Info("query job ended", "state", jb.status.State, "rows", jb.status.Rows, "error", jb.status.Error)
+}
+
+func (j *Jobs) spool(ctx context.Context, jb *job, session *Session, sql string) error {
+	f, err := os.Create(jb.path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	bw := bufio.NewWriter(f)
+
+	rows, err := session.Rows(ctx, sql)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	jb.mu.Lock()
+	jb.status.State, jb.status.QueryID, jb.status.Stats = JobRunning, rows.ID(), rows.Stats()
+	jb.mu.Unlock()
+	rows.OnStats = func(st Stats) {
+		jb.mu.Lock()
+		jb.status.Stats = st
+		jb.mu.Unlock()
+	}
+
+	var pos, count int64
+	var offsets []int64
+	for rows.Next() {
+		for _, row := range rows.Batch() {
+			line, err := json.Marshal(row)
+			if err != nil {
+				return err
+			}
+			if count%int64(j.pageSize) == 0 {
+				offsets = append(offsets, pos)
+			}
+			bw.Write(line)
+			bw.WriteByte('\n')
+			pos += int64(len(line)) + 1
+			count++
+		}
+		if err := bw.Flush(); err != nil {
+			return err
+		}
+
+		jb.mu.Lock()
+		jb.offsets = append(jb.offsets[:0], offsets...)
+		jb.written = pos
+		jb.status.Rows = count
+		jb.status.Columns = rows.Columns()
+		jb.status.Pages = int(count / int64(j.pageSize)) // complete pages only while running
+		jb.mu.Unlock()
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	jb.mu.Lock()
+	jb.status.Columns = rows.Columns()
+	jb.mu.Unlock()
+	return nil
+}
+
+// Get returns the job id if owner may see it (admins see every job).
+func (j *Jobs) Get(id, owner string, admin bool) (JobStatus, error) {
+	jb, err := j.lookup(id, owner, admin)
+	if err != nil {
+		return JobStatus{}, err
+	}
+	return jb.snapshot(), nil
+}
+
+func (j *Jobs) lookup(id, owner string, admin bool) (*job, error) {
+	j.mu.Lock()
+	jb, ok := j.jobs[id]
+	j.mu.Unlock()
+	if !ok || (!admin && jb.owner != owner) {
+		return nil, ErrJobNotFound
+	}
+	return jb, nil
+}
+
+// Page returns result page n (0-based) of a job. Complete pages can be read
+// while the job is still running.
+func (j *Jobs) Page(id, owner string, admin bool, n int) (JobStatus, []Row, error) {
+	jb, err := j.lookup(id, owner, admin)
+	if err != nil {
+		return JobStatus{}, nil, err
+	}
+	jb.mu.Lock()
+	status := jb.status
+	if n < 0 || n >= status.Pages {
+		jb.mu.Unlock()
+		if status.done() {
+			return status, nil, ErrPageOutOfRange
+		}
+		return status, nil, ErrPageNotReady
+	}
+	start, end := jb.offsets[n], jb.written
+	if n+1 < len(jb.offsets) {
+		end = jb.offsets[n+1]
+	}
+	jb.mu.Unlock()
+
+	f, err := os.Open(jb.path)
+	if err != nil {
+		return status, nil, err
+	}
+	defer f.Close()
+	buf := make([]byte, end-start)
+	if _, err := f.ReadAt(buf, start); err != nil && !errors.Is(err, io.EOF) {
+		return status, nil, err
+	}
+	rows := make([]Row, 0, j.pageSize)
+	for _, line := range bytes.Split(bytes.TrimSuffix(buf, []byte("\n")), []byte("\n")) {
+		var row Row
+		if err := json.Unmarshal(line, &row); err != nil {
+			return status, nil, err
+		}
+		rows = append(rows, row)
+	}
+	return status, rows, nil
+}
+
+// Download writes all rows of a finished job to w as NDJSON objects or CSV.
+func (j *Jobs) Download(id, owner string, admin bool, w io.Writer, format string) error {
+	jb, err := j.lookup(id, owner, admin)
+	if err != nil {
+		return err
+	}
+	status := jb.snapshot()
+	if status.State != JobFinished {
+		return ErrJobNotFinished
+	}
+	f, err := os.Open(jb.path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	cw := csv.NewWriter(w)
+	enc := json.NewEncoder(w)
+	if format == FormatCSV {
+		header := make([]string, len(status.Columns))
+		for i, col := range status.Columns {
+			header[i] = col.Name
+		}
+		cw.Write(header)
+	}
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 64<<10), 64<<20)
+	for sc.Scan() {
+		var row Row
+		if err := json.Unmarshal(sc.Bytes(), &row); err != nil {
+			return err
+		}
+		if format == FormatCSV {
+			cw.Write(csvRecord(row))
+			continue
+		}
+		obj := make(map[string]interface{}, len(status.Columns))
+		for i, col := range status.Columns {
+			if i < len(row) {
+				obj[col.Name] = row[i]
+			}
+		}
+		if err := enc.Encode(obj); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return sc.Err()
+}
+
+// Cancel stops a running job (cancelling the query in Trino); the job is
+// kept as CANCELLED until it expires. An ended job is removed with its results.
+func (j *Jobs) Cancel(id, owner string, admin bool) (JobStatus, error) {
+	jb, err := j.lookup(id, owner, admin)
+	if err != nil {
+		return JobStatus{}, err
+	}
+	jb.mu.Lock()
+	if !jb.status.done() {
+		jb.cancelled = true
+		jb.mu.Unlock()
+		jb.cancel()
+		return jb.snapshot(), nil
+	}
+	status := jb.status
+	jb.mu.Unlock()
+	j.remove(id, jb)
+	return status, nil
+}
+
+func (j *Jobs) remove(id string, jb *job) {
+	j.mu.Lock()
+	delete(j.jobs, id)
+	j.mu.Unlock()
+	os.Remove(jb.path)
+}
+
+// expireLoop removes ended jobs whose results have expired.
+func (j *Jobs) expireLoop() {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	for now := range ticker.C {
+		j.mu.Lock()
+		var expired []string
+		for id, jb := range j.jobs {
+			if st := jb.snapshot(); st.ExpiresAt != nil && now.After(*st.ExpiresAt) {
+				expired = append(expired, id)
+			}
+		}
+		j.mu.Unlock()
+		for _, id := range expired {
+			j.mu.Lock()
+			jb := j.jobs[id]
+			j.mu.Unlock()
+			if jb != nil {
+				j.remove(id, jb)
+			}
+		}
+	}
+}
+
+func newJobID() string {
+	var b [12]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+func getEnvInt(key string, def int) int {
+	if n, err := strconv.Atoi(os.Getenv(key)); err == nil && n > 0 {
+		return n
+	}
+	return def
+}
+
+func getEnvDuration(key string, def time.Duration) time.Duration {
+	if d, err := time.ParseDuration(os.Getenv(key)); err == nil && d > 0 {
+		return d
+	}
+	return def
+}
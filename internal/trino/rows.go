@@ -0,0 +1,192 @@
+package trino
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"gcr-backend/internal/logging"
+)
+
+// queryResults is one response of the Trino client protocol (/v1/statement).
+type queryResults struct {
+	ID      string   `json:"id"`
+	NextURI string   `json:"nextUri"`
+	Columns []Column `json:"columns"`
+	Data    []Row    `json:"data"`
+	Stats   Stats    `json:"stats"`
+	Error   *struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// Rows iterates over the results of a running query one page (batch) at a
+// time, fetching the next page from Trino only when asked for it:
+//
+//	rows, err := client.Rows(ctx, sql)
+//	if err != nil { ... }
+//	defer rows.Close()
+//	for rows.Next() {
+//		for _, row := range rows.Batch() { ... }
+//	}
+//	if err := rows.Err(); err != nil { ... }
+//
+// Close cancels the query in Trino if it has not finished.
+type Rows struct {
+	session *Session
+	ctx     context.Context
+	id      string
+	nextURI string
+	columns []Column
+	stats   Stats
+	batch   []Row
+	pending []Row // data already in the initial response
+	err     error
+
+	// OnStats, if set, is called with the statistics of every page,
+	// including pages without rows (progress while the query is queued or running).
+	OnStats func(Stats)
+}
+
+// Rows starts sql with the default options and returns an iterator over its
+// results. If ctx is cancelled, Next stops with ctx's error and Close cancels
+// the query.
+func (c *Client) Rows(ctx context.Context, sql string) (*Rows, error) {
+	return c.NewSession(Options{}).Rows(ctx, sql)
+}
+
+// Rows starts sql in the session and returns an iterator over its results.
+func (s *Session) Rows(ctx context.Context, sql string) (*Rows, error) {
+	// Trino expects SQL in POST body (not query parameter)
+	req, err := http.NewRequestWithContext(ctx, "POST", fmt.Sprintf("%s/v1/statement", s.client.baseURL), strings.NewReader(sql))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	s.setHeaders(req.Header)
+	req.Header.Set("Content-Type", "text/plain")
+
+	page, err := s.fetch(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute query: %w", err)
+	}
+	r := &Rows{session: s, ctx: ctx, id: page.ID}
+	r.apply(page)
+	if r.err != nil {
+		return nil, r.err
+	}
+	r.pending = page.Data
+	return r, nil
+}
+
+// Next fetches the next non-empty batch of rows. It returns false when the
+// query has finished or failed; check Err.
+func (r *Rows) Next() bool {
+	r.batch = nil
+	for r.err == nil {
+		if len(r.pending) > 0 {
+			r.batch, r.pending = r.pending, nil
+			return true
+		}
+		if r.nextURI == "" {
+			return false
+		}
+		req, err := http.NewRequestWithContext(r.ctx, "GET", r.nextURI, nil)
+		if err != nil {
+			r.err = fmt.Errorf("failed to create request: %w", err)
+			return false
+		}
+		page, err := r.session.fetch(req)
+		if err != nil {
+			if ctxErr := r.ctx.Err(); ctxErr != nil {
+				err = ctxErr
+			}
+			r.err = fmt.Errorf("failed to poll query: %w", err)
+			return false
+		}
+		r.apply(page)
+		r.pending = page.Data
+	}
+	return false
+}
+
+// apply records the state of page.
+func (r *Rows) apply(page *queryResults) {
+	r.nextURI = page.NextURI
+	if len(page.Columns) > 0 {
+		r.columns = page.Columns
+	}
+	r.stats = page.Stats
+	if r.OnStats != nil {
+		r.OnStats(page.Stats)
+	}
+	if page.Error != nil {
+		r.err = fmt.Errorf("trino query error: %s", page.Error.Message)
+	}
+}
+
+// Batch returns the rows fetched by the last call to Next.
+func (r *Rows) Batch() []Row { return r.batch }
+
+// Columns returns the result columns (known once the first batch arrived).
+func (r *Rows) Columns() []Column { return r.columns }
+
+// Stats returns the query statistics of the latest page.
+func (r *Rows) Stats() Stats { return r.stats }
+
+// ID returns the Trino query ID.
+func (r *Rows) ID() string { return r.id }
+
+// Err returns the error that stopped Next, if any.
+func (r *Rows) Err() error { return r.err }
+
+// Close releases the query. An unfinished query is cancelled in Trino with
+// DELETE on its nextUri, so it stops using cluster resources.
+func (r *Rows) Close() error {
+	if r.nextURI == "" {
+		return nil
+	}
+	uri := r.nextURI
+	r.nextURI = ""
+
+	// r.ctx may be the reason we are closing early; cancel on a fresh deadline.
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(r.ctx), 5*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, "DELETE", uri, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := r.session.client.httpClient.Do(req)
+	if err != nil {
+		logging.For(ctx, "trino").Warn("query cancel failed", "query_id", r.id, "error", err)
+		return err
+	}
+	resp.Body.Close()
+	logging.For(ctx, "trino").Info("query cancelled", "query_id", r.id, "status", resp.StatusCode)
+	return nil
+}
+
+// fetch sends req and decodes one protocol response, applying its session
+// headers. The body is closed before returning so polling does not hold
+// connections.
+func (s *Session) fetch(req *http.Request) (*queryResults, error) {
+	resp, err := s.client.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	s.update(resp.Header)
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return nil, fmt.Errorf("trino error (status %d): %s", resp.StatusCode, string(body))
+	}
+	var page queryResults
+	if err := json.NewDecoder(resp.Body).Decode(&page); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	return &page, nil
+}
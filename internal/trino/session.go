@@ -0,0 +1,177 @@
+package trino
+
+import (
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Options select where and how a query runs. Empty fields fall back to the
+// client's configured defaults; Session and ClientTags add to them.
+type Options struct {
+	Catalog    string            `json:"catalog,omitempty"`
+	Schema     string            `json:"schema,omitempty"`
+	Source     string            `json:"source,omitempty"`
+	ClientTags []string          `json:"client_tags,omitempty"`
+	Session    map[string]string `json:"session,omitempty"` // session properties
+}
+
+// merge returns o with empty fields taken from def.
+func (o Options) merge(def Options) Options {
+	out := def
+	if o.Catalog != "" {
+		out.Catalog = o.Catalog
+	}
+	if o.Schema != "" {
+		out.Schema = o.Schema
+	}
+	if o.Source != "" {
+		out.Source = o.Source
+	}
+	out.ClientTags = append(append([]string(nil), def.ClientTags...), o.ClientTags...)
+	out.Session = make(map[string]string, len(def.Session)+len(o.Session))
+	for k, v := range def.Session {
+		out.Session[k] = v
+	}
+	for k, v := range o.Session {
+		out.Session[k] = v
+	}
+	return out
+}
+
+// Session is a sequence of statements that share Trino session state: the
+// X-Trino-Set-* / Clear-* headers of each response (from USE, SET SESSION,
+// PREPARE, START TRANSACTION, ...) are applied to the following statements,
+// as the Trino CLI does.
+type Session struct {
+	client *Client
+
+	mu            sync.Mutex
+	opts          Options
+	path          string
+	prepared      map[string]string
+	transactionID string
+}
+
+// NewSession starts a session with opts over the client defaults.
+func (c *Client) NewSession(opts Options) *Session {
+	return &Session{client: c, opts: opts.merge(c.defaults), prepared: map[string]string{}}
+}
+
+// Options returns the current catalog, schema, source, tags and properties.
+func (s *Session) Options() Options {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.opts.merge(Options{})
+}
+
+// setHeaders adds the session state to a statement request.
+func (s *Session) setHeaders(h http.Header) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	h.Set("X-Trino-User", s.client.user)
+	if s.opts.Source != "" {
+		h.Set("X-Trino-Source", s.opts.Source)
+	}
+	if s.opts.Catalog != "" {
+		h.Set("X-Trino-Catalog", s.opts.Catalog)
+	}
+	if s.opts.Schema != "" {
+		h.Set("X-Trino-Schema", s.opts.Schema)
+	}
+	if s.path != "" {
+		h.Set("X-Trino-Path", s.path)
+	}
+	if len(s.opts.ClientTags) > 0 {
+		h.Set("X-Trino-Client-Tags", strings.Join(s.opts.ClientTags, ","))
+	}
+	for _, k := range sortedKeys(s.opts.Session) {
+		h.Add("X-Trino-Session", k+"="+url.QueryEscape(s.opts.Session[k]))
+	}
+	for _, name := range sortedKeys(s.prepared) {
+		h.Add("X-Trino-Prepared-Statement", url.QueryEscape(name)+"="+url.QueryEscape(s.prepared[name]))
+	}
+	if s.transactionID != "" {
+		h.Set("X-Trino-Transaction-Id", s.transactionID)
+	} else {
+		h.Set("X-Trino-Transaction-Id", "NONE")
+	}
+}
+
+// update applies the session changes announced in a response.
+func (s *Session) update(h http.Header) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if v := h.Get("X-Trino-Set-Catalog"); v != "" {
+		s.opts.Catalog = v
+	}
+	if v := h.Get("X-Trino-Set-Schema"); v != "" {
+		s.opts.Schema = v
+	}
+	if v := h.Get("X-Trino-Set-Path"); v != "" {
+		s.path = v
+	}
+	for _, kv := range headerValues(h, "X-Trino-Set-Session") {
+		if k, v, ok := strings.Cut(kv, "="); ok {
+			if unescaped, err := url.QueryUnescape(v); err == nil {
+				v = unescaped
+			}
+			s.opts.Session[strings.TrimSpace(k)] = v
+		}
+	}
+	for _, k := range headerValues(h, "X-Trino-Clear-Session") {
+		delete(s.opts.Session, k)
+	}
+	for _, kv := range headerValues(h, "X-Trino-Added-Prepare") {
+		if name, stmt, ok := strings.Cut(kv, "="); ok {
+			name, _ = url.QueryUnescape(name)
+			stmt, _ = url.QueryUnescape(stmt)
+			s.prepared[name] = stmt
+		}
+	}
+	for _, name := range headerValues(h, "X-Trino-Deallocated-Prepare") {
+		name, _ = url.QueryUnescape(name)
+		delete(s.prepared, name)
+	}
+	if v := h.Get("X-Trino-Started-Transaction-Id"); v != "" {
+		s.transactionID = v
+	}
+	if h.Get("X-Trino-Clear-Transaction-Id") != "" {
+		s.transactionID = ""
+	}
+}
+
+// headerValues returns the comma-separated values of every key header.
+func headerValues(h http.Header, key string) []string {
+	var out []string
+	for _, line := range h.Values(key) {
+		for _, v := range strings.Split(line, ",") {
+			if v = strings.TrimSpace(v); v != "" {
+				out = append(out, v)
+			}
+		}
+	}
+	return out
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// parseKV parses "k=v,k=v" (TRINO_SESSION).
+func parseKV(s string) map[string]string {
+	out := map[string]string{}
+	for _, pair := range strings.Split(s, ",") {
+		if k, v, ok := strings.Cut(strings.TrimSpace(pair), "="); ok && k != "" {
+			out[k] = v
+		}
+	}
+	return out
+}
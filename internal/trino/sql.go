@@ -0,0 +1,248 @@
+package trino
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Literal returns s as a Trino string literal, doubling embedded single quotes.
+func Literal(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+// Build substitutes the ? placeholders of query with args as SQL literals.
+// Strings are quoted with Literal; integers, floats, booleans, nil and
+// time.Time are rendered as the matching Trino literals. Placeholders inside
+// quoted strings, identifiers and comments are left alone.
+func Build(query string, args ...any) (string, error) {
+	var b strings.Builder
+	n := 0
+	for _, tok := range tokenize(query) {
+		if tok.kind != tokPunct || tok.text != "?" {
+			b.WriteString(tok.text)
+			continue
+		}
+		if n >= len(args) {
+			return "", fmt.Errorf("trino: %d placeholders but %d args", n+1, len(args))
+		}
+		lit, err := literalOf(args[n])
+		if err != nil {
+			return "", err
+		}
+		b.WriteString(lit)
+		n++
+	}
+	if n != len(args) {
+		return "", fmt.Errorf("trino: %d placeholders but %d args", n, len(args))
+	}
+	return b.String(), nil
+}
+
+func literalOf(v any) (string, error) {
+	switch v := v.(type) {
+	case nil:
+		return "NULL", nil
+	case string:
+		return Literal(v), nil
+	case int:
+		return strconv.Itoa(v), nil
+	case int64:
+		return strconv.FormatInt(v, 10), nil
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case time.Time:
+		return "TIMESTAMP " + Literal(v.UTC().Format("2006-01-02 15:04:05.000")), nil
+	}
+	return "", fmt.Errorf("trino: unsupported SQL argument type %T", v)
+}
+
+// ErrNotReadOnly is returned by ReadOnly for statements other than a single query.
+var ErrNotReadOnly = errors.New("trino: only a single SELECT or WITH query is allowed")
+
+// blockedKeywords may not appear (outside literals and quoted identifiers) in
+// a raw query: DDL, DML, session and procedure statements.
+var blockedKeywords = map[string]bool{
+	"INSERT": true, "UPDATE": true, "DELETE": true, "MERGE": true, "TRUNCATE": true,
+	"CREATE": true, "DROP": true, "ALTER": true, "COMMENT": true, "REFRESH": true,
+	"GRANT": true, "REVOKE": true, "DENY": true, "CALL": true, "EXECUTE": true,
+	"PREPARE": true, "DEALLOCATE": true, "SET": true, "RESET": true, "USE": true,
+	"START": true, "COMMIT": true, "ROLLBACK": true, "ANALYZE": true,
+}
+
+// ReadOnly checks that query is one read-only SELECT or WITH statement and
+// caps its result at maxRows: a missing top-level LIMIT is appended and a
+// larger (or ALL) one is lowered. It returns the query to execute.
+func ReadOnly(query string, maxRows int) (string, error) {
+	toks := tokenize(query)
+
+	// Comments become spaces (so they cannot glue words together); one
+	// trailing semicolon is dropped and anything after it is a second statement.
+	var code []token
+	for i, tok := range toks {
+		if tok.kind == tokComment {
+			code = append(code, token{kind: tokSpace, text: " "})
+			continue
+		}
+		if tok.kind == tokPunct && tok.text == ";" {
+			for _, rest := range toks[i+1:] {
+				if rest.kind != tokSpace && rest.kind != tokComment {
+					return "", fmt.Errorf("%w: multiple statements", ErrNotReadOnly)
+				}
+			}
+			break
+		}
+		code = append(code, tok)
+	}
+
+	first := ""
+	depth, limitAt, fetch := 0, -1, false
+	for i, tok := range code {
+		switch {
+		case tok.kind == tokPunct && tok.text == "(":
+			depth++
+		case tok.kind == tokPunct && tok.text == ")":
+			depth--
+		case tok.kind == tokWord:
+			word := strings.ToUpper(tok.text)
+			if first == "" {
+				first = word
+			}
+			if blockedKeywords[word] {
+				return "", fmt.Errorf("%w: %s is not allowed", ErrNotReadOnly, word)
+			}
+			if depth == 0 && word == "LIMIT" {
+				limitAt = i
+			}
+			if depth == 0 && word == "FETCH" {
+				fetch = true
+			}
+		}
+	}
+	if first != "SELECT" && first != "WITH" {
+		return "", ErrNotReadOnly
+	}
+	if maxRows <= 0 {
+		return join(code), nil
+	}
+	if fetch {
+		return "", fmt.Errorf("%w: use LIMIT instead of FETCH", ErrNotReadOnly)
+	}
+
+	capped := strconv.Itoa(maxRows)
+	if limitAt < 0 {
+		return strings.TrimRightFunc(join(code), unicode.IsSpace) + " LIMIT " + capped, nil
+	}
+	for i := limitAt + 1; i < len(code); i++ {
+		if code[i].kind == tokSpace {
+			continue
+		}
+		if n, err := strconv.Atoi(code[i].text); err == nil && n <= maxRows {
+			break
+		}
+		if code[i].kind == tokNumber || strings.EqualFold(code[i].text, "ALL") {
+			code[i].text = capped
+			break
+		}
+		return "", fmt.Errorf("%w: LIMIT must be a number", ErrNotReadOnly)
+	}
+	return join(code), nil
+}
+
+type tokenKind int
+
+const (
+	tokSpace tokenKind = iota
+	tokWord
+	tokNumber
+	tokString  // '...'
+	tokQuoted  // "..."
+	tokComment // -- ... or /* ... */
+	tokPunct
+)
+
+type token struct {
+	kind tokenKind
+	text string
+}
+
+// tokenize splits SQL into tokens; concatenating them gives back the input.
+// Unterminated strings, identifiers and comments run to the end of the input.
+func tokenize(sql string) []token {
+	var toks []token
+	for i := 0; i < len(sql); {
+		start := i
+		c := sql[i]
+		var kind tokenKind
+		switch {
+		case c == '\'' || c == '"':
+			kind = tokString
+			if c == '"' {
+				kind = tokQuoted
+			}
+			i++
+			for i < len(sql) {
+				if sql[i] == c {
+					if i+1 < len(sql) && sql[i+1] == c { // doubled quote is an escape
+						i += 2
+						continue
+					}
+					i++
+					break
+				}
+				i++
+			}
+		case c == '-' && strings.HasPrefix(sql[i:], "--"):
+			kind = tokComment
+			if end := strings.IndexByte(sql[i:], '\n'); end >= 0 {
+				i += end
+			} else {
+				i = len(sql)
+			}
+		case c == '/' && strings.HasPrefix(sql[i:], "/*"):
+			kind = tokComment
+			if end := strings.Index(sql[i+2:], "*/"); end >= 0 {
+				i += end + 4
+			} else {
+				i = len(sql)
+			}
+		case isSpace(c):
+			kind = tokSpace
+			for i < len(sql) && isSpace(sql[i]) {
+				i++
+			}
+		case isWordStart(c):
+			kind = tokWord
+			for i < len(sql) && (isWordStart(sql[i]) || isDigit(sql[i])) {
+				i++
+			}
+		case isDigit(c):
+			kind = tokNumber
+			for i < len(sql) && (isDigit(sql[i]) || sql[i] == '.') {
+				i++
+			}
+		default:
+			kind = tokPunct
+			i++
+		}
+		toks = append(toks, token{kind: kind, text: sql[start:i]})
+	}
+	return toks
+}
+
+func join(toks []token) string {
+	var b strings.Builder
+	for _, t := range toks {
+		b.WriteString(t.text)
+	}
+	return b.String()
+}
+
+func isSpace(c byte) bool     { return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f' }
+func isDigit(c byte) bool     { return c >= '0' && c <= '9' }
+func isWordStart(c byte) bool { return c == '_' || c >= 0x80 || (c|0x20 >= 'a' && c|0x20 <= 'z') }
@@ -0,0 +1,140 @@
+package trino
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestReadOnly(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   string
+		maxRows int
+		want    string
+		wantErr bool
+	}{
+		// Statements
+		{name: "select", query: "SELECT 1", maxRows: 100, want: "SELECT 1 LIMIT 100"},
+		{name: "with", query: "WITH x AS (SELECT 1 AS a) SELECT a FROM x", maxRows: 100, want: "WITH x AS (SELECT 1 AS a) SELECT a FROM x LIMIT 100"},
+		{name: "lowercase", query: "select 1", maxRows: 100, want: "select 1 LIMIT 100"},
+		{name: "trailing semicolon", query: "SELECT 1;  -- done\n", maxRows: 100, want: "SELECT 1 LIMIT 100"},
+		{name: "show", query: "SHOW TABLES", maxRows: 100, wantErr: true},
+		{name: "delete", query: "DELETE FROM providers", maxRows: 100, wantErr: true},
+		{name: "with delete", query: "WITH x AS (SELECT 1) DELETE FROM providers", maxRows: 100, wantErr: true},
+		{name: "set session", query: "SET SESSION query_max_run_time = '1h'", maxRows: 100, wantErr: true},
+
+		// Multiple statements
+		{name: "second statement", query: "SELECT 1; DROP TABLE providers", maxRows: 100, wantErr: true},
+		{name: "second statement mixed case", query: "sElEcT 1;dRoP TABLE providers", maxRows: 100, wantErr: true},
+		{name: "statement after comment", query: "SELECT 1 /* x */; -- y\nDROP TABLE providers", maxRows: 100, wantErr: true},
+		{name: "empty second statement", query: "SELECT 1;;", maxRows: 100, wantErr: true},
+		{name: "semicolon in line comment", query: "SELECT 1 -- ; DROP TABLE providers", maxRows: 100, want: "SELECT 1 LIMIT 100"},
+		{name: "semicolon in block comment", query: "SELECT /* ; DROP TABLE providers; */ 1", maxRows: 100, want: "SELECT   1 LIMIT 100"},
+		{name: "unterminated comment", query: "SELECT 1 /* ; DROP TABLE providers", maxRows: 100, want: "SELECT 1 LIMIT 100"},
+		{name: "semicolon in string", query: "SELECT ';DROP TABLE providers' AS s", maxRows: 100, want: "SELECT ';DROP TABLE providers' AS s LIMIT 100"},
+		{name: "semicolon in quoted identifier", query: `SELECT 1 AS "a;DROP TABLE providers"`, maxRows: 100, want: `SELECT 1 AS "a;DROP TABLE providers" LIMIT 100`},
+		{name: "escaped quote in string", query: "SELECT 'it''s; DROP TABLE providers' AS s", maxRows: 100, want: "SELECT 'it''s; DROP TABLE providers' AS s LIMIT 100"},
+		{name: "string closes before semicolon", query: "SELECT 'it'''; DROP TABLE providers", maxRows: 100, wantErr: true},
+		{name: "comment glues keyword", query: "SELECT 1/**/;DROP TABLE providers", maxRows: 100, wantErr: true},
+
+		// Blocked keywords
+		{name: "keyword in string", query: "SELECT * FROM t WHERE note = 'delete me'", maxRows: 100, want: "SELECT * FROM t WHERE note = 'delete me' LIMIT 100"},
+		{name: "keyword in quoted identifier", query: `SELECT "update", "Drop" FROM t`, maxRows: 100, want: `SELECT "update", "Drop" FROM t LIMIT 100`},
+		{name: "keyword in comment", query: "SELECT 1 -- insert\n", maxRows: 100, want: "SELECT 1 LIMIT 100"},
+		{name: "bare keyword as column", query: "SELECT update FROM t", maxRows: 100, wantErr: true},
+		{name: "keyword in subquery", query: "SELECT * FROM (DELETE FROM t)", maxRows: 100, wantErr: true},
+		{name: "keyword prefix is a word", query: "SELECT updated_at FROM t", maxRows: 100, want: "SELECT updated_at FROM t LIMIT 100"},
+
+		// LIMIT capping
+		{name: "smaller limit kept", query: "SELECT * FROM t LIMIT 10", maxRows: 100, want: "SELECT * FROM t LIMIT 10"},
+		{name: "equal limit kept", query: "SELECT * FROM t LIMIT 100", maxRows: 100, want: "SELECT * FROM t LIMIT 100"},
+		{name: "larger limit lowered", query: "SELECT * FROM t LIMIT 5000", maxRows: 100, want: "SELECT * FROM t LIMIT 100"},
+		{name: "limit all lowered", query: "SELECT * FROM t limit all", maxRows: 100, want: "SELECT * FROM t limit 100"},
+		{name: "offset before limit", query: "SELECT * FROM t OFFSET 10 LIMIT 5000", maxRows: 100, want: "SELECT * FROM t OFFSET 10 LIMIT 100"},
+		{name: "subquery limit only", query: "SELECT * FROM (SELECT * FROM t LIMIT 5000) s", maxRows: 100, want: "SELECT * FROM (SELECT * FROM t LIMIT 5000) s LIMIT 100"},
+		{name: "subquery and outer limit", query: "SELECT * FROM (SELECT * FROM t LIMIT 5000) s LIMIT 50", maxRows: 100, want: "SELECT * FROM (SELECT * FROM t LIMIT 5000) s LIMIT 50"},
+		{name: "nested subqueries", query: "SELECT * FROM (SELECT * FROM (SELECT * FROM t LIMIT 1) a LIMIT 2) b LIMIT 5000", maxRows: 100, want: "SELECT * FROM (SELECT * FROM (SELECT * FROM t LIMIT 1) a LIMIT 2) b LIMIT 100"},
+		{name: "limit in string", query: "SELECT 'LIMIT 5' AS s", maxRows: 100, want: "SELECT 'LIMIT 5' AS s LIMIT 100"},
+		{name: "limit in comment", query: "SELECT 1 -- LIMIT 5", maxRows: 100, want: "SELECT 1 LIMIT 100"},
+		{name: "limit placeholder", query: "SELECT * FROM t LIMIT ?", maxRows: 100, wantErr: true},
+		{name: "limit expression", query: "SELECT * FROM t LIMIT (5000)", maxRows: 100, wantErr: true},
+		{name: "fetch", query: "SELECT * FROM t FETCH FIRST 5000 ROWS ONLY", maxRows: 100, wantErr: true},
+		{name: "fetch in subquery", query: "SELECT * FROM (SELECT * FROM t FETCH FIRST 5 ROWS ONLY) s", maxRows: 100, want: "SELECT * FROM (SELECT * FROM t FETCH FIRST 5 ROWS ONLY) s LIMIT 100"},
+		{name: "no cap", query: "SELECT * FROM t LIMIT 5000", maxRows: 0, want: "SELECT * FROM t LIMIT 5000"},
+		{name: "no cap fetch", query: "SELECT * FROM t FETCH FIRST 5 ROWS ONLY", maxRows: 0, want: "SELECT * FROM t FETCH FIRST 5 ROWS ONLY"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ReadOnly(tt.query, tt.maxRows)
+			if tt.wantErr {
+				if !errors.Is(err, ErrNotReadOnly) {
+					t.Fatalf("ReadOnly(%q) = %q, %v; want ErrNotReadOnly", tt.query, got, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ReadOnly(%q) error: %v", tt.query, err)
+			}
+			if got != tt.want {
+				t.Fatalf("ReadOnly(%q) = %q, want %q", tt.query, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuild(t *testing.T) {
+	ts := time.Date(2025, 12, 18, 19, 11, 18, 876_000_000, time.FixedZone("IST", 5*3600+1800))
+	tests := []struct {
+		name    string
+		query   string
+		args    []any
+		want    string
+		wantErr bool
+	}{
+		{name: "string", query: "SELECT * FROM t WHERE id = ?", args: []any{"p1"}, want: "SELECT * FROM t WHERE id = 'p1'"},
+		{name: "quote escaping", query: "SELECT * FROM t WHERE id = ?", args: []any{"x' OR '1'='1"}, want: "SELECT * FROM t WHERE id = 'x'' OR ''1''=''1'"},
+		{name: "backslash is literal", query: "SELECT ?", args: []any{`a\'b`}, want: `SELECT 'a\''b'`},
+		{name: "placeholder in string", query: "SELECT '?' AS q, ? AS v", args: []any{"a"}, want: "SELECT '?' AS q, 'a' AS v"},
+		{name: "placeholder in escaped string", query: "SELECT 'it''s ?' AS q, ?", args: []any{1}, want: "SELECT 'it''s ?' AS q, 1"},
+		{name: "placeholder in quoted identifier", query: `SELECT "?" FROM t WHERE a = ?`, args: []any{"x"}, want: `SELECT "?" FROM t WHERE a = 'x'`},
+		{name: "placeholder in comments", query: "SELECT ? -- ?\n/* ? */", args: []any{true}, want: "SELECT true -- ?\n/* ? */"},
+		{name: "argument with placeholder", query: "SELECT ?, ?", args: []any{"?", "b"}, want: "SELECT '?', 'b'"},
+		{name: "types", query: "SELECT ?, ?, ?, ?, ?", args: []any{nil, 7, int64(-8), 1.5, false}, want: "SELECT NULL, 7, -8, 1.5, false"},
+		{name: "time", query: "SELECT ?", args: []any{ts}, want: "SELECT TIMESTAMP '2025-12-18 13:41:18.876'"},
+		{name: "too few args", query: "SELECT ?, ?", args: []any{1}, wantErr: true},
+		{name: "too many args", query: "SELECT ?", args: []any{1, 2}, wantErr: true},
+		{name: "unsupported type", query: "SELECT ?", args: []any{[]string{"a"}}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Build(tt.query, tt.args...)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("Build(%q) = %q, want error", tt.query, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Build(%q) error: %v", tt.query, err)
+			}
+			if got != tt.want {
+				t.Fatalf("Build(%q) = %q, want %q", tt.query, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTokenizeRoundTrip(t *testing.T) {
+	for _, sql := range []string{
+		"SELECT 'a''b', \"c\"\"d\" FROM t -- x\n/* y */ WHERE n = 1.5;",
+		"SELECT 'unterminated",
+		"SELECT \"unterminated",
+		"SELECT 1 /* unterminated",
+		"SELECT ünïcode FROM t",
+	} {
+		if got := join(tokenize(sql)); got != sql {
+			t.Errorf("join(tokenize(%q)) = %q", sql, got)
+		}
+	}
+}
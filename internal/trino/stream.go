@@ -0,0 +1,131 @@
+package trino
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"gcr-backend/internal/logging"
+)
+
+// Streaming formats of /api/trino/query (?format= or Accept).
+const (
+	FormatJSON   = "json" // default: buffered {"success", "data", "stats"} envelope
+	FormatNDJSON = "ndjson"
+	FormatCSV    = "csv"
+)
+
+// Trailers sent after a streamed result, since the status is already 200
+// when the rows start flowing.
+const (
+	TrailerState = "X-Trino-State"
+	TrailerRows  = "X-Trino-Rows"
+	TrailerError = "X-Trino-Error"
+)
+
+// queryFormat picks the response format of a query request.
+func queryFormat(r *http.Request) string {
+	switch f := r.URL.Query().Get("format"); f {
+	case FormatJSON, FormatNDJSON, FormatCSV:
+		return f
+	}
+	accept := r.Header.Get("Accept")
+	switch {
+	case strings.Contains(accept, "application/x-ndjson"):
+		return FormatNDJSON
+	case strings.Contains(accept, "text/csv"):
+		return FormatCSV
+	}
+	return FormatJSON
+}
+
+// streamQuery runs sql and writes its rows as they arrive, one batch at a
+// time: NDJSON objects keyed by column, or CSV with a header line. Errors
+// after the first byte are reported in the X-Trino-Error trailer (and, for
+// NDJSON, as a final {"error": ...} line). A client that disconnects cancels
+// the query in Trino.
+func (s *Service) streamQuery(ctx context.Context, w http.ResponseWriter, session *Session, sql, format string) {
+	rows, err := session.Rows(ctx, sql)
+	if err != nil {
+		s.queryError(w, ctx, err)
+		return
+	}
+	defer rows.Close()
+
+	w.Header().Set("Trailer", strings.Join([]string{TrailerState, TrailerRows, TrailerError}, ", "))
+	if format == FormatCSV {
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	} else {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+	}
+	w.WriteHeader(http.StatusOK)
+	rc := http.NewResponseController(w)
+
+	enc := json.NewEncoder(w)
+	cw := csv.NewWriter(w)
+	count := 0
+	for rows.Next() {
+		cols := rows.Columns()
+		if format == FormatCSV && count == 0 {
+			header := make([]string, len(cols))
+			for i, col := range cols {
+				header[i] = col.Name
+			}
+			cw.Write(header)
+		}
+		for _, row := range rows.Batch() {
+			if format == FormatCSV {
+				cw.Write(csvRecord(row))
+			} else {
+				obj := make(map[string]interface{}, len(cols))
+				for i, col := range cols {
+					if i < len(row) {
+						obj[col.Name] = row[i]
+					}
+				}
+				enc.Encode(obj)
+			}
+			count++
+		}
+		cw.Flush()
+		_ = rc.Flush()
+	}
+	if format == FormatCSV && count == 0 && len(rows.Columns()) > 0 {
+		header := make([]string, 0, len(rows.Columns()))
+		for _, col := range rows.Columns() {
+			header = append(header, col.Name)
+		}
+		cw.Write(header)
+		cw.Flush()
+	}
+
+	w.Header().Set(TrailerRows, strconv.Itoa(count))
+	w.Header().Set(TrailerState, rows.Stats().State)
+	if err := rows.Err(); err != nil {
+		logging.For(ctx, "trino").Error("streamed query failed", "query_id", rows.ID(), "rows", count, "error", err)
+		w.Header().Set(TrailerError, err.Error())
+		if format == FormatNDJSON {
+			enc.Encode(map[string]string{"error": err.Error()})
+		}
+	}
+}
+
+// csvRecord renders a row for CSV: strings as they are, NULL as empty and
+// everything else (numbers, arrays, maps) as JSON.
+func csvRecord(row Row) []string {
+	out := make([]string, len(row))
+	for i, v := range row {
+		switch v := v.(type) {
+		case nil:
+		case string:
+			out[i] = v
+		default:
+			b, _ := json.Marshal(v)
+			out[i] = string(b)
+		}
+	}
+	return out
+}